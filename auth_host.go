@@ -0,0 +1,157 @@
+package connectplugin
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
+)
+
+const (
+	// HostSecretHeader carries the host secret on host→plugin calls.
+	HostSecretHeader = "X-Plugin-Host-Secret"
+
+	// HostSecretEnv passes the host secret to a plugin in the environment
+	// (e.g. from an orchestrator secret).
+	HostSecretEnv = "PLUGIN_HOST_SECRET"
+
+	// HostSecretFDEnv names an inherited file descriptor the host secret is
+	// read from. ProcessStrategy uses it so the secret stays out of the
+	// plugin's environment.
+	HostSecretFDEnv = "PLUGIN_HOST_SECRET_FD"
+)
+
+// hostOnlyProcedures are the plugin RPCs only the host may call: they
+// assign the plugin's identity or change what it serves. GetPluginInfo and
+// GetHealth stay open for discovery and probing.
+var hostOnlyProcedures = map[string]bool{
+	connectpluginv1connect.PluginIdentitySetRuntimeIdentityProcedure: true,
+	connectpluginv1connect.PluginControlShutdownProcedure:            true,
+	connectpluginv1connect.PluginControlDrainProcedure:               true,
+	connectpluginv1connect.PluginControlPauseProcedure:               true,
+	connectpluginv1connect.PluginControlResumeProcedure:              true,
+	connectpluginv1connect.PluginControlReconfigureProcedure:         true,
+}
+
+// GenerateHostSecret creates a random secret to provision to a plugin at
+// launch.
+func GenerateHostSecret() (string, error) {
+	return generateToken()
+}
+
+// HostSecretFromEnv returns the host secret provisioned at launch, read from
+// the file descriptor in PLUGIN_HOST_SECRET_FD or from PLUGIN_HOST_SECRET.
+// Both variables are cleared so the secret is not passed on to child
+// processes. Returns "" if no secret was provisioned.
+func HostSecretFromEnv() (string, error) {
+	fdValue := os.Getenv(HostSecretFDEnv)
+	secret := os.Getenv(HostSecretEnv)
+	os.Unsetenv(HostSecretFDEnv)
+	os.Unsetenv(HostSecretEnv)
+
+	if fdValue == "" {
+		return secret, nil
+	}
+
+	fd, err := strconv.Atoi(fdValue)
+	if err != nil || fd < 0 {
+		return "", fmt.Errorf("invalid %s", HostSecretFDEnv)
+	}
+	f := os.NewFile(uintptr(fd), "host-secret")
+	if f == nil {
+		return "", fmt.Errorf("invalid %s", HostSecretFDEnv)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, 4096))
+	if err != nil {
+		return "", fmt.Errorf("read host secret: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// HostAuthInterceptor authenticates host→plugin RPCs on the plugin side.
+//
+// SetRuntimeIdentity and the PluginControl RPCs that change the plugin
+// (Shutdown, Drain, Pause, Resume, Reconfigure) must carry the secret the
+// host provisioned at launch in X-Plugin-Host-Secret. Without it, anyone who
+// can reach the plugin could take over its identity or shut it down.
+type HostAuthInterceptor struct {
+	secret []byte
+}
+
+// NewHostAuthInterceptor creates an interceptor that requires the given
+// host secret on host-only RPCs. With an empty secret, host-only RPCs are
+// rejected.
+func NewHostAuthInterceptor(secret string) *HostAuthInterceptor {
+	return &HostAuthInterceptor{secret: []byte(secret)}
+}
+
+// WrapUnary authenticates unary RPCs.
+func (i *HostAuthInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		if err := i.authenticate(req.Spec().Procedure, req.Header().Get(HostSecretHeader)); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient is a no-op: the interceptor only validates incoming requests.
+func (i *HostAuthInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler authenticates streaming RPCs.
+func (i *HostAuthInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if err := i.authenticate(conn.Spec().Procedure, conn.RequestHeader().Get(HostSecretHeader)); err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+// authenticate checks the host secret for host-only procedures.
+func (i *HostAuthInterceptor) authenticate(procedure, secret string) error {
+	if !hostOnlyProcedures[procedure] {
+		return nil
+	}
+	if len(i.secret) == 0 {
+		return connect.NewError(connect.CodeUnauthenticated,
+			fmt.Errorf("no host secret provisioned"))
+	}
+	if secret == "" {
+		return connect.NewError(connect.CodeUnauthenticated,
+			fmt.Errorf("%s header required", HostSecretHeader))
+	}
+	// Use constant-time comparison to prevent timing attacks
+	if subtle.ConstantTimeCompare(i.secret, []byte(secret)) != 1 {
+		return connect.NewError(connect.CodeUnauthenticated,
+			fmt.Errorf("invalid host secret"))
+	}
+	return nil
+}
+
+// WithHostSecret returns a client option that presents the host secret on
+// host→plugin calls, for use with NewPluginIdentityClient and
+// NewPluginControlClient. An empty secret adds nothing.
+func WithHostSecret(secret string) connect.ClientOption {
+	return connect.WithInterceptors(connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if secret != "" {
+				req.Header().Set(HostSecretHeader, secret)
+			}
+			return next(ctx, req)
+		}
+	}))
+}
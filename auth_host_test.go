@@ -0,0 +1,217 @@
+package connectplugin
+
+import (
+	"context"
+	"io"
+	"net"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+func TestHostAuth_ServePluginRequiresSecret(t *testing.T) {
+	secret, _ := GenerateHostSecret()
+	endpoint, done := startServePlugin(t, &PluginServeConfig{
+		ClientConfig: ClientConfig{
+			HostURL:  "http://127.0.0.1:1",
+			SelfID:   "logger-plugin",
+			Metadata: servePluginMetadata(),
+		},
+		HostSecret: secret,
+		StopCh:     make(chan struct{}),
+	})
+	ctx := context.Background()
+
+	// Identity and shutdown are rejected without the secret
+	err := NewPluginIdentityClient(endpoint, nil).SetRuntimeIdentity(ctx, "logger-plugin-evil", "token", "")
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("Expected SetRuntimeIdentity Unauthenticated, got %v", err)
+	}
+	_, err = NewPluginControlClient(endpoint, nil, WithHostSecret("wrong")).Shutdown(ctx, 0, "attack")
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("Expected Shutdown Unauthenticated, got %v", err)
+	}
+
+	// Discovery and health stay open
+	if _, err := NewPluginIdentityClient(endpoint, nil).GetPluginInfo(ctx); err != nil {
+		t.Errorf("Expected GetPluginInfo without secret, got %v", err)
+	}
+	if _, err := NewPluginControlClient(endpoint, nil).GetHealth(ctx); err != nil {
+		t.Errorf("Expected GetHealth without secret, got %v", err)
+	}
+
+	// The host's secret is accepted
+	if _, err := NewPluginControlClient(endpoint, nil, WithHostSecret(secret)).Shutdown(ctx, 1, "test"); err != nil {
+		t.Fatalf("Shutdown with secret failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ServePlugin returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected ServePlugin to return after Shutdown")
+	}
+}
+
+func TestHostAuth_ServePluginWithoutSecret(t *testing.T) {
+	t.Setenv(HostSecretEnv, "")
+	ctx := context.Background()
+
+	for _, insecure := range []bool{false, true} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+		endpoint := "http://" + ln.Addr().String()
+		go ServePlugin(&PluginServeConfig{
+			ClientConfig: ClientConfig{
+				HostURL:  "http://127.0.0.1:1",
+				SelfID:   "logger-plugin",
+				Metadata: servePluginMetadata(),
+			},
+			Listener:             ln,
+			BaseURL:              endpoint,
+			InsecureSkipHostAuth: insecure,
+			StopCh:               make(chan struct{}),
+		})
+
+		err = NewPluginIdentityClient(endpoint, nil).SetRuntimeIdentity(ctx, "logger-plugin-evil", "token", "")
+		if insecure {
+			// The identity is accepted; only the host registration fails
+			if connect.CodeOf(err) == connect.CodeUnauthenticated {
+				t.Errorf("Expected SetRuntimeIdentity accepted with InsecureSkipHostAuth, got %v", err)
+			}
+			continue
+		}
+		if connect.CodeOf(err) != connect.CodeUnauthenticated {
+			t.Errorf("Expected SetRuntimeIdentity Unauthenticated without a secret, got %v", err)
+		}
+		_, err = NewPluginControlClient(endpoint, nil, WithHostSecret("guess")).Shutdown(ctx, 0, "attack")
+		if connect.CodeOf(err) != connect.CodeUnauthenticated {
+			t.Errorf("Expected Shutdown Unauthenticated without a secret, got %v", err)
+		}
+		if _, err := NewPluginControlClient(endpoint, nil).GetHealth(ctx); err != nil {
+			t.Errorf("Expected GetHealth without secret, got %v", err)
+		}
+	}
+}
+
+func TestHostAuth_PlatformPresentsSecret(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	host := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	secret, _ := GenerateHostSecret()
+	endpoint, _ := startServePlugin(t, &PluginServeConfig{
+		ClientConfig: ClientConfig{
+			HostURL:  host.URL,
+			SelfID:   "logger-plugin",
+			Metadata: servePluginMetadata(),
+		},
+		HostSecret: secret,
+		StopCh:     make(chan struct{}),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Without the secret the plugin refuses its identity
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: endpoint}); err == nil {
+		t.Fatal("Expected AddPlugin without host secret to fail")
+	}
+
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: endpoint, HostSecret: secret}); err != nil {
+		t.Fatalf("AddPlugin with host secret failed: %v", err)
+	}
+}
+
+func TestHostAuth_InMemoryControl(t *testing.T) {
+	result, err := NewInMemoryStrategy(nil).Launch(context.Background(), PluginSpec{
+		Name:        "secured",
+		Plugin:      &testPlugin{},
+		ImplFactory: func() any { return nil },
+		HostSecret:  "s3cret",
+	})
+	if err != nil {
+		t.Fatalf("Launch failed: %v", err)
+	}
+	t.Cleanup(result.Cleanup)
+
+	ctx := context.Background()
+	anonymous := NewPluginControlClient(result.Endpoint, result.HTTPClient)
+	if _, err := anonymous.GetHealth(ctx); err != nil {
+		t.Errorf("Expected GetHealth without secret to succeed, got %v", err)
+	}
+	if err := anonymous.Pause(ctx, "attack"); connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("Expected Pause Unauthenticated, got %v", err)
+	}
+	if _, err := anonymous.Shutdown(ctx, 1, "attack"); connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("Expected Shutdown Unauthenticated, got %v", err)
+	}
+
+	host := NewPluginControlClient(result.Endpoint, result.HTTPClient, WithHostSecret("s3cret"))
+	if err := host.Pause(ctx, "maintenance"); err != nil {
+		t.Fatalf("Pause with secret failed: %v", err)
+	}
+	if _, err := host.Shutdown(ctx, 1, "test"); err != nil {
+		t.Fatalf("Shutdown with secret failed: %v", err)
+	}
+}
+
+func TestHostSecretFromEnv(t *testing.T) {
+	t.Setenv(HostSecretEnv, "s3cret")
+
+	secret, err := HostSecretFromEnv()
+	if err != nil {
+		t.Fatalf("HostSecretFromEnv failed: %v", err)
+	}
+	if secret != "s3cret" {
+		t.Errorf("Expected s3cret, got %q", secret)
+	}
+
+	// The secret is not passed on to child processes
+	if secret, _ := HostSecretFromEnv(); secret != "" {
+		t.Errorf("Expected secret cleared from environment, got %q", secret)
+	}
+}
+
+func TestProcessStrategy_PassesHostSecretOnFD(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("extra files are not inherited on Windows")
+	}
+
+	cmd := exec.Command("true")
+	pipe, err := passHostSecret(cmd, "s3cret")
+	if err != nil {
+		t.Fatalf("passHostSecret failed: %v", err)
+	}
+	defer pipe.Close()
+
+	if len(cmd.ExtraFiles) != 1 || cmd.ExtraFiles[0] != pipe {
+		t.Fatalf("Expected secret pipe inherited as fd 3, got %v", cmd.ExtraFiles)
+	}
+	if len(cmd.Env) != 1 || cmd.Env[0] != HostSecretFDEnv+"=3" {
+		t.Errorf("Expected %s=3, got %v", HostSecretFDEnv, cmd.Env)
+	}
+	for _, env := range cmd.Env {
+		if strings.Contains(env, "s3cret") {
+			t.Errorf("Expected secret kept out of the environment, got %q", env)
+		}
+	}
+
+	data, err := io.ReadAll(pipe)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(data) != "s3cret" {
+		t.Errorf("Expected s3cret on the pipe, got %q", data)
+	}
+}
@@ -0,0 +1,103 @@
+package connectplugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+const (
+	// RuntimeIDHeader carries the runtime ID assigned to a plugin during handshake.
+	RuntimeIDHeader = "X-Plugin-Runtime-ID"
+
+	// runtimeAuthProvider is the AuthContext.Provider value set by RuntimeAuthInterceptor.
+	runtimeAuthProvider = "runtime"
+)
+
+// RuntimeAuthInterceptor authenticates Phase 2 host RPCs (service registry,
+// plugin lifecycle) using the runtime identity issued by a HandshakeServer.
+//
+// Requests must carry X-Plugin-Runtime-ID and "Authorization: Bearer <runtime_token>".
+// On success the verified runtime ID is stored in AuthContext.Identity, so
+// handlers never need to trust the raw header.
+type RuntimeAuthInterceptor struct {
+	handshake *HandshakeServer
+}
+
+// NewRuntimeAuthInterceptor creates an interceptor that validates runtime
+// tokens against the given handshake server.
+func NewRuntimeAuthInterceptor(handshake *HandshakeServer) *RuntimeAuthInterceptor {
+	return &RuntimeAuthInterceptor{handshake: handshake}
+}
+
+// WrapUnary authenticates unary RPCs.
+func (i *RuntimeAuthInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		auth, err := i.handshake.authenticateRuntime(req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(WithAuthContext(ctx, auth), req)
+	}
+}
+
+// WrapStreamingClient is a no-op: the interceptor only validates incoming requests.
+func (i *RuntimeAuthInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler authenticates streaming RPCs (e.g. WatchService).
+func (i *RuntimeAuthInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		auth, err := i.handshake.authenticateRuntime(conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(WithAuthContext(ctx, auth), conn)
+	}
+}
+
+// authenticateRuntime validates the runtime ID and bearer token in the headers.
+// Returns the verified auth context, or a CodeUnauthenticated error.
+func (h *HandshakeServer) authenticateRuntime(header http.Header) (*AuthContext, error) {
+	runtimeID := header.Get(RuntimeIDHeader)
+	if runtimeID == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated,
+			fmt.Errorf("%s header required", RuntimeIDHeader))
+	}
+
+	authHeader := header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return nil, connect.NewError(connect.CodeUnauthenticated,
+			fmt.Errorf("Authorization: Bearer <token> required"))
+	}
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+
+	if !h.ValidateToken(runtimeID, token) {
+		return nil, connect.NewError(connect.CodeUnauthenticated,
+			fmt.Errorf("invalid runtime token for %s", runtimeID))
+	}
+
+	return &AuthContext{
+		Identity: runtimeID,
+		Provider: runtimeAuthProvider,
+	}, nil
+}
+
+// authenticatedRuntimeID returns the verified runtime ID stored by
+// RuntimeAuthInterceptor. Returns CodeUnauthenticated if the request was
+// not authenticated with a runtime token.
+func authenticatedRuntimeID(ctx context.Context) (string, error) {
+	auth := GetAuthContext(ctx)
+	if auth == nil || auth.Provider != runtimeAuthProvider || auth.Identity == "" {
+		return "", connect.NewError(connect.CodeUnauthenticated,
+			fmt.Errorf("runtime authentication required"))
+	}
+	return auth.Identity, nil
+}
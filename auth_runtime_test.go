@@ -0,0 +1,159 @@
+package connectplugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
+)
+
+// runtimeContext returns a context carrying a verified runtime identity,
+// as RuntimeAuthInterceptor would produce.
+func runtimeContext(runtimeID string) context.Context {
+	return WithAuthContext(context.Background(), &AuthContext{
+		Identity: runtimeID,
+		Provider: runtimeAuthProvider,
+	})
+}
+
+// startRuntimeAuthHost serves the lifecycle and registry handlers behind
+// RuntimeAuthInterceptor.
+func startRuntimeAuthHost(t *testing.T, handshake *HandshakeServer, lifecycle *LifecycleServer, registry *ServiceRegistry) *httptest.Server {
+	t.Helper()
+
+	runtimeAuth := connect.WithInterceptors(NewRuntimeAuthInterceptor(handshake))
+	mux := http.NewServeMux()
+	mux.Handle(LifecycleServerHandler(lifecycle, runtimeAuth))
+	mux.Handle(ServiceRegistryHandler(registry, runtimeAuth))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRuntimeAuth_ValidToken(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	server := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	runtimeID := "logger-x7k2"
+	token, _ := generateToken()
+	registerTestToken(handshake, runtimeID, token)
+
+	client := connectpluginv1connect.NewServiceRegistryClient(http.DefaultClient, server.URL)
+	req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+		ServiceType:  "logger",
+		Version:      "1.0.0",
+		EndpointPath: "/logger.v1.Logger/",
+	})
+	req.Header().Set(RuntimeIDHeader, runtimeID)
+	req.Header().Set("Authorization", "Bearer "+token)
+
+	if _, err := client.RegisterService(context.Background(), req); err != nil {
+		t.Fatalf("RegisterService failed: %v", err)
+	}
+
+	provider, err := registry.GetProviderByRuntimeID(runtimeID)
+	if err != nil {
+		t.Fatalf("Expected provider registered under verified runtime ID: %v", err)
+	}
+	if provider.RuntimeID != runtimeID {
+		t.Errorf("Expected runtime ID %s, got %s", runtimeID, provider.RuntimeID)
+	}
+}
+
+func TestRuntimeAuth_RejectsUnauthenticated(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	server := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	victimID := "victim-a1b2"
+	victimToken, _ := generateToken()
+	registerTestToken(handshake, victimID, victimToken)
+
+	attackerID := "attacker-c3d4"
+	attackerToken, _ := generateToken()
+	registerTestToken(handshake, attackerID, attackerToken)
+
+	tests := []struct {
+		name      string
+		runtimeID string
+		auth      string
+	}{
+		{"no headers", "", ""},
+		{"missing token", victimID, ""},
+		{"wrong prefix", victimID, "Token " + victimToken},
+		{"invalid token", victimID, "Bearer wrong-token"},
+		{"another plugin's token", victimID, "Bearer " + attackerToken},
+	}
+
+	client := connectpluginv1connect.NewPluginLifecycleClient(http.DefaultClient, server.URL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+				State: connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+			})
+			if tt.runtimeID != "" {
+				req.Header().Set(RuntimeIDHeader, tt.runtimeID)
+			}
+			if tt.auth != "" {
+				req.Header().Set("Authorization", tt.auth)
+			}
+
+			_, err := client.ReportHealth(context.Background(), req)
+			if connect.CodeOf(err) != connect.CodeUnauthenticated {
+				t.Errorf("Expected Unauthenticated, got %v", err)
+			}
+		})
+	}
+
+	if lifecycle.GetHealthState(victimID) != nil {
+		t.Error("Expected victim health to be untouched")
+	}
+}
+
+func TestRuntimeAuth_Streaming(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	server := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	runtimeID := "watcher-e5f6"
+	token, _ := generateToken()
+	registerTestToken(handshake, runtimeID, token)
+
+	client := connectpluginv1connect.NewServiceRegistryClient(http.DefaultClient, server.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Without a token the stream fails
+	stream, err := client.WatchService(ctx, connect.NewRequest(&connectpluginv1.WatchServiceRequest{ServiceType: "logger"}))
+	if err != nil {
+		t.Fatalf("WatchService failed: %v", err)
+	}
+	if stream.Receive() {
+		t.Fatal("Expected unauthenticated stream to fail")
+	}
+	if connect.CodeOf(stream.Err()) != connect.CodeUnauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", stream.Err())
+	}
+
+	// With a valid token the initial event is delivered
+	req := connect.NewRequest(&connectpluginv1.WatchServiceRequest{ServiceType: "logger"})
+	req.Header().Set(RuntimeIDHeader, runtimeID)
+	req.Header().Set("Authorization", "Bearer "+token)
+	stream, err = client.WatchService(ctx, req)
+	if err != nil {
+		t.Fatalf("WatchService failed: %v", err)
+	}
+	if !stream.Receive() {
+		t.Fatalf("Expected initial event: %v", stream.Err())
+	}
+}
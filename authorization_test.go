@@ -1,7 +1,6 @@
 package connectplugin
 
 import (
-	"context"
 	"testing"
 
 	"connectrpc.com/connect"
@@ -28,9 +27,8 @@ func TestServiceAuthorization_AllowedService(t *testing.T) {
 		EndpointPath: "/cache.v1.Cache/",
 		Metadata:     map[string]string{},
 	})
-	req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
 
-	_, err := registry.RegisterService(context.Background(), req)
+	_, err := registry.RegisterService(runtimeContext(runtimeID), req)
 	if err != nil {
 		t.Errorf("Authorized service registration should succeed: %v", err)
 	}
@@ -52,9 +50,8 @@ func TestServiceAuthorization_UnauthorizedService(t *testing.T) {
 		EndpointPath: "/secrets.v1.Secrets/",
 		Metadata:     map[string]string{},
 	})
-	req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
 
-	_, err := registry.RegisterService(context.Background(), req)
+	_, err := registry.RegisterService(runtimeContext(runtimeID), req)
 	if err == nil {
 		t.Error("Unauthorized service registration should fail")
 	}
@@ -92,9 +89,8 @@ func TestServiceAuthorization_NoRestrictionsAllowsAny(t *testing.T) {
 			EndpointPath: "/" + svcType + ".v1." + svcType + "/",
 			Metadata:     map[string]string{},
 		})
-		req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
 
-		_, err := registry.RegisterService(context.Background(), req)
+		_, err := registry.RegisterService(runtimeContext(runtimeID), req)
 		if err != nil {
 			t.Errorf("Unrestricted plugin should be able to register %s: %v", svcType, err)
 		}
@@ -118,9 +114,8 @@ func TestServiceAuthorization_MultipleAllowedServices(t *testing.T) {
 			EndpointPath: "/" + svcType + ".v1/",
 			Metadata:     map[string]string{},
 		})
-		req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
 
-		_, err := registry.RegisterService(context.Background(), req)
+		_, err := registry.RegisterService(runtimeContext(runtimeID), req)
 		if err != nil {
 			t.Errorf("Service %s should be allowed: %v", svcType, err)
 		}
@@ -133,9 +128,8 @@ func TestServiceAuthorization_MultipleAllowedServices(t *testing.T) {
 		EndpointPath: "/secrets.v1/",
 		Metadata:     map[string]string{},
 	})
-	req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
 
-	_, err := registry.RegisterService(context.Background(), req)
+	_, err := registry.RegisterService(runtimeContext(runtimeID), req)
 	if err == nil {
 		t.Error("Unauthorized service should be denied")
 	}
@@ -156,9 +150,8 @@ func TestServiceAuthorization_EmptyAllowedList(t *testing.T) {
 		EndpointPath: "/cache.v1/",
 		Metadata:     map[string]string{},
 	})
-	req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
 
-	_, err := registry.RegisterService(context.Background(), req)
+	_, err := registry.RegisterService(runtimeContext(runtimeID), req)
 	if err == nil {
 		t.Error("Empty allowed list should deny all services")
 	}
@@ -0,0 +1,379 @@
+package connectplugin
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+const (
+	// CallerContextHeader carries the host-signed caller context that the
+	// ServiceRouter attaches to proxied calls.
+	CallerContextHeader = "X-Plugin-Caller-Context"
+
+	// OriginHeader carries the originating end-user AuthContext asserted by
+	// a calling plugin. The router verifies the caller and folds it into the
+	// signed caller context; providers should never read it directly.
+	OriginHeader = "X-Plugin-Origin"
+
+	// CallerKeyEnv is the environment variable ProcessStrategy uses to pass
+	// the router's caller context verification key (base64) to plugins.
+	CallerKeyEnv = "CALLER_CONTEXT_KEY"
+
+	// callerContextTTL bounds how long a caller context without a deadline
+	// stays valid.
+	callerContextTTL = 5 * time.Minute
+)
+
+// CallerInfo describes who is calling a provider through the ServiceRouter.
+// It is signed by the host, so providers can trust it once verified.
+type CallerInfo struct {
+	// RuntimeID is the authenticated runtime ID of the calling plugin.
+	RuntimeID string `json:"runtime_id"`
+
+	// SelfID is the calling plugin's declared self ID.
+	SelfID string `json:"self_id,omitempty"`
+
+	// Origin is the end user or tenant the call chain started from,
+	// as asserted by the first plugin in the chain. Nil if none.
+	Origin *AuthContext `json:"origin,omitempty"`
+
+	// OriginAsserted is set when the calling plugin asserted Origin itself
+	// rather than carrying it over in a verified upstream caller context.
+	// The router can't verify an asserted origin: any authenticated plugin
+	// can claim any end user or tenant, so providers should only trust it
+	// from callers they trust to authenticate end users.
+	OriginAsserted bool `json:"origin_asserted,omitempty"`
+
+	// Deadline is the caller's remaining deadline. Zero if none.
+	Deadline time.Time `json:"deadline,omitempty"`
+
+	// Audience is the runtime ID of the provider the context was issued
+	// for. Providers reject contexts issued for another provider, so a
+	// context can't be replayed against a different plugin.
+	Audience string `json:"audience"`
+
+	// ServiceType is the service type the call was routed to.
+	ServiceType string `json:"service_type"`
+
+	// IssuedAt is when the router signed the context.
+	IssuedAt time.Time `json:"issued_at"`
+
+	// ExpiresAt is when the signed context stops being valid.
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// token is the signed form, forwarded on downstream calls
+	token string
+}
+
+type callerInfoKey struct{}
+
+// withCallerInfo stores a verified caller context in the context.
+func withCallerInfo(ctx context.Context, info *CallerInfo) context.Context {
+	return context.WithValue(ctx, callerInfoKey{}, info)
+}
+
+// CallerFromContext returns the verified caller context of a routed call.
+// Returns nil if the call carried no caller context.
+func CallerFromContext(ctx context.Context) *CallerInfo {
+	info, _ := ctx.Value(callerInfoKey{}).(*CallerInfo)
+	return info
+}
+
+// signCallerContext encodes and signs a caller context as
+// base64url(payload) "." base64url(signature).
+func signCallerContext(key ed25519.PrivateKey, info *CallerInfo) (string, error) {
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode caller context: %w", err)
+	}
+	sig := ed25519.Sign(key, payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// CallerAudience identifies the provider a caller context must have been
+// issued for.
+type CallerAudience struct {
+	// RuntimeID returns the provider's runtime ID. It is called for every
+	// call since a plugin's runtime ID is assigned after it starts.
+	RuntimeID func() string
+
+	// ServiceTypes are the service types the provider serves; the context
+	// must be for one of them.
+	ServiceTypes []string
+}
+
+// check reports whether a caller context was issued for this audience.
+func (a CallerAudience) check(info *CallerInfo) error {
+	runtimeID := ""
+	if a.RuntimeID != nil {
+		runtimeID = a.RuntimeID()
+	}
+	if runtimeID == "" || info.Audience != runtimeID {
+		return fmt.Errorf("caller context issued for another provider (%s)", info.Audience)
+	}
+	for _, serviceType := range a.ServiceTypes {
+		if info.ServiceType == serviceType {
+			return nil
+		}
+	}
+	return fmt.Errorf("caller context issued for another service type (%s)", info.ServiceType)
+}
+
+// VerifyCallerContext checks a signed caller context against the router's
+// verification key and the provider it must have been issued for, and
+// returns its contents.
+func VerifyCallerContext(key ed25519.PublicKey, token string, audience CallerAudience) (*CallerInfo, error) {
+	info, err := verifyCallerSignature(key, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := audience.check(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// verifyCallerSignature checks a signed caller context's signature and
+// expiry and returns its contents.
+func verifyCallerSignature(key ed25519.PublicKey, token string) (*CallerInfo, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errors.New("malformed caller context")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("malformed caller context: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, fmt.Errorf("malformed caller context signature: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, payload, sig) {
+		return nil, errors.New("invalid caller context signature")
+	}
+
+	var info CallerInfo
+	if err := json.Unmarshal(payload, &info); err != nil {
+		return nil, fmt.Errorf("malformed caller context: %w", err)
+	}
+	if time.Now().After(info.ExpiresAt) {
+		return nil, errors.New("caller context expired")
+	}
+	info.token = token
+	return &info, nil
+}
+
+// encodeOrigin encodes an AuthContext for OriginHeader.
+func encodeOrigin(auth *AuthContext) string {
+	data, err := json.Marshal(auth)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeOrigin decodes an OriginHeader value.
+func decodeOrigin(value string) (*AuthContext, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("malformed origin: %w", err)
+	}
+	var auth AuthContext
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return nil, fmt.Errorf("malformed origin: %w", err)
+	}
+	return &auth, nil
+}
+
+// CallerContextInterceptor verifies caller contexts on incoming calls and
+// propagates them on outgoing calls.
+//
+// Handlers read the verified context with CallerFromContext. Calls without
+// a caller context pass through; calls with an invalid one are rejected.
+// Outgoing calls made with such a context forward it, so the originating
+// end user survives multiple hops. Otherwise an end-user AuthContext in the
+// outgoing context is sent as the call's origin.
+type CallerContextInterceptor struct {
+	key      ed25519.PublicKey
+	audience CallerAudience
+}
+
+// NewCallerContextInterceptor creates an interceptor that verifies caller
+// contexts with the router's verification key and accepts only those
+// issued for audience.
+func NewCallerContextInterceptor(key ed25519.PublicKey, audience CallerAudience) *CallerContextInterceptor {
+	return &CallerContextInterceptor{key: key, audience: audience}
+}
+
+// WrapUnary verifies incoming and propagates outgoing unary calls.
+func (i *CallerContextInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			propagateCaller(ctx, req.Header())
+			return next(ctx, req)
+		}
+		ctx, err := i.verify(ctx, req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient propagates the caller context on outgoing streams.
+func (i *CallerContextInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		propagateCaller(ctx, conn.RequestHeader())
+		return conn
+	}
+}
+
+// WrapStreamingHandler verifies the caller context on incoming streams.
+func (i *CallerContextInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.verify(ctx, conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+// verify stores a valid caller context in ctx.
+func (i *CallerContextInterceptor) verify(ctx context.Context, header http.Header) (context.Context, error) {
+	token := header.Get(CallerContextHeader)
+	if token == "" {
+		return ctx, nil
+	}
+	info, err := VerifyCallerContext(i.key, token, i.audience)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+	return withCallerInfo(ctx, info), nil
+}
+
+// propagateCaller forwards the verified caller context, or the end-user
+// AuthContext, on an outgoing call.
+func propagateCaller(ctx context.Context, header http.Header) {
+	if info := CallerFromContext(ctx); info != nil && info.token != "" {
+		header.Set(CallerContextHeader, info.token)
+		return
+	}
+	if auth := GetAuthContext(ctx); auth != nil && auth.Provider != runtimeAuthProvider {
+		header.Set(OriginHeader, encodeOrigin(auth))
+	}
+}
+
+// CallerContextMiddleware verifies caller contexts for an http.Handler, for
+// plugins whose Connect handlers can't take interceptors. Only contexts
+// issued for audience are accepted. Handlers read the verified context with
+// CallerFromContext.
+func CallerContextMiddleware(key ed25519.PublicKey, audience CallerAudience, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token := req.Header.Get(CallerContextHeader)
+		if token == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		info, err := VerifyCallerContext(key, token, audience)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(withCallerInfo(req.Context(), info)))
+	})
+}
+
+// CallerKeyFromEnv returns the verification key passed to the plugin in
+// CALLER_CONTEXT_KEY. Returns nil if unset.
+func CallerKeyFromEnv() (ed25519.PublicKey, error) {
+	value := os.Getenv(CallerKeyEnv)
+	if value == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid %s", CallerKeyEnv)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// CallerVerificationKey returns the public key providers use to verify
+// caller contexts attached by this router.
+func (r *ServiceRouter) CallerVerificationKey() ed25519.PublicKey {
+	return r.config.CallerSigningKey.Public().(ed25519.PublicKey)
+}
+
+// callerInfo builds the caller context for a routed call.
+// An upstream caller context forwarded by the caller must verify and have
+// been issued to the caller; its origin is carried over. Otherwise the
+// caller's asserted origin is used and marked OriginAsserted.
+func (r *ServiceRouter) callerInfo(req *http.Request, callerID string) (*CallerInfo, error) {
+	info := &CallerInfo{
+		RuntimeID: callerID,
+		SelfID:    r.handshakeServer.selfID(callerID),
+		IssuedAt:  time.Now(),
+	}
+
+	if upstream := req.Header.Get(CallerContextHeader); upstream != "" {
+		prior, err := verifyCallerSignature(r.CallerVerificationKey(), upstream)
+		if err != nil {
+			return nil, err
+		}
+		if prior.Audience != callerID {
+			return nil, errors.New("forwarded caller context was issued to another plugin")
+		}
+		info.Origin = prior.Origin
+	} else if origin := req.Header.Get(OriginHeader); origin != "" {
+		auth, err := decodeOrigin(origin)
+		if err != nil {
+			return nil, err
+		}
+		info.Origin = auth
+		info.OriginAsserted = true
+	}
+
+	info.ExpiresAt = info.IssuedAt.Add(callerContextTTL)
+	if deadline, ok := requestDeadline(req); ok {
+		info.Deadline = deadline
+		if deadline.Before(info.ExpiresAt) {
+			info.ExpiresAt = deadline
+		}
+	}
+	return info, nil
+}
+
+// signCaller signs the caller context for one provider. Each attempt
+// (failover, shadow) is signed for the provider it goes to.
+func (r *ServiceRouter) signCaller(caller *CallerInfo, provider *ServiceProvider) (string, error) {
+	info := *caller
+	info.Audience = provider.RuntimeID
+	info.ServiceType = provider.ServiceType
+	return signCallerContext(r.config.CallerSigningKey, &info)
+}
+
+// requestDeadline returns the caller's deadline from Connect-Timeout-Ms or
+// the request context.
+func requestDeadline(req *http.Request) (time.Time, bool) {
+	deadline, ok := req.Context().Deadline()
+	if ms, err := strconv.ParseInt(req.Header.Get("Connect-Timeout-Ms"), 10, 64); err == nil && ms > 0 {
+		timeout := time.Now().Add(time.Duration(ms) * time.Millisecond)
+		if !ok || timeout.Before(deadline) {
+			deadline, ok = timeout, true
+		}
+	}
+	return deadline, ok
+}
@@ -0,0 +1,195 @@
+package connectplugin
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyCallerContext(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	otherPublic, _, _ := ed25519.GenerateKey(nil)
+
+	audience := CallerAudience{
+		RuntimeID:    func() string { return "kv-plugin-a1b2" },
+		ServiceTypes: []string{"kv"},
+	}
+
+	now := time.Now()
+	token, err := signCallerContext(private, &CallerInfo{
+		RuntimeID:   "cache-plugin-x7k2",
+		SelfID:      "cache-plugin",
+		Audience:    "kv-plugin-a1b2",
+		ServiceType: "kv",
+		IssuedAt:    now,
+		ExpiresAt:   now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("signCallerContext failed: %v", err)
+	}
+
+	info, err := VerifyCallerContext(public, token, audience)
+	if err != nil {
+		t.Fatalf("VerifyCallerContext failed: %v", err)
+	}
+	if info.RuntimeID != "cache-plugin-x7k2" || info.SelfID != "cache-plugin" {
+		t.Errorf("Unexpected caller info: %+v", info)
+	}
+
+	if _, err := VerifyCallerContext(otherPublic, token, audience); err == nil {
+		t.Error("Expected failure with wrong key")
+	}
+
+	payload, sig, _ := strings.Cut(token, ".")
+	forged, _ := signCallerContext(private, &CallerInfo{RuntimeID: "admin", ExpiresAt: now.Add(time.Minute)})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	if _, err := VerifyCallerContext(public, forgedPayload+"."+sig, audience); err == nil {
+		t.Error("Expected failure with tampered payload")
+	}
+	if _, err := VerifyCallerContext(public, payload, audience); err == nil {
+		t.Error("Expected failure without signature")
+	}
+
+	expired, _ := signCallerContext(private, &CallerInfo{RuntimeID: "a", Audience: "kv-plugin-a1b2", ServiceType: "kv", ExpiresAt: now.Add(-time.Second)})
+	if _, err := VerifyCallerContext(public, expired, audience); err == nil {
+		t.Error("Expected failure for expired context")
+	}
+
+	// Contexts issued for another provider or service type are rejected
+	otherProvider := CallerAudience{RuntimeID: func() string { return "kv-plugin-c3d4" }, ServiceTypes: []string{"kv"}}
+	if _, err := VerifyCallerContext(public, token, otherProvider); err == nil {
+		t.Error("Expected failure for context issued to another provider")
+	}
+	otherService := CallerAudience{RuntimeID: audience.RuntimeID, ServiceTypes: []string{"secrets"}}
+	if _, err := VerifyCallerContext(public, token, otherService); err == nil {
+		t.Error("Expected failure for context issued for another service type")
+	}
+	unassigned := CallerAudience{RuntimeID: func() string { return "" }, ServiceTypes: []string{"kv"}}
+	if _, err := VerifyCallerContext(public, token, unassigned); err == nil {
+		t.Error("Expected failure before the provider has a runtime ID")
+	}
+}
+
+func TestServiceRouter_PropagatesCallerContext(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	var providerID string
+	audience := CallerAudience{
+		RuntimeID:    func() string { return providerID },
+		ServiceTypes: []string{"kv"},
+	}
+	seen := make(chan *CallerInfo, 1)
+	providerID = registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		CallerContextMiddleware(router.CallerVerificationKey(), audience,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(OriginHeader) != "" {
+					t.Error("Origin header should not reach the provider")
+				}
+				seen <- CallerFromContext(r.Context())
+			})))
+
+	callerID, _ := generateRuntimeID("api-plugin")
+	callerToken, _ := generateToken()
+	handshake.issueToken(callerID, "api-plugin", callerToken)
+
+	req := httptest.NewRequest("POST", "/services/kv/"+providerID+"/Get", nil)
+	req.Header.Set(RuntimeIDHeader, callerID)
+	req.Header.Set("Authorization", "Bearer "+callerToken)
+	req.Header.Set("Connect-Timeout-Ms", "5000")
+	propagateCaller(WithAuthContext(context.Background(), &AuthContext{
+		Identity: "alice",
+		Claims:   map[string]string{"tenant": "acme"},
+		Provider: "token",
+	}), req.Header)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	info := <-seen
+	if info == nil {
+		t.Fatal("Expected caller context at provider")
+	}
+	if info.RuntimeID != callerID || info.SelfID != "api-plugin" {
+		t.Errorf("Unexpected caller identity: %+v", info)
+	}
+	if info.Origin == nil || info.Origin.Identity != "alice" || info.Origin.Claims["tenant"] != "acme" {
+		t.Errorf("Expected origin alice/acme, got %+v", info.Origin)
+	}
+	if !info.OriginAsserted {
+		t.Error("Expected origin asserted by the caller")
+	}
+	if remaining := time.Until(info.Deadline); remaining <= 0 || remaining > 5*time.Second {
+		t.Errorf("Expected deadline within 5s, got %s", remaining)
+	}
+
+	if info.Audience != providerID || info.ServiceType != "kv" {
+		t.Errorf("Expected context issued for %s/kv, got %s/%s", providerID, info.Audience, info.ServiceType)
+	}
+
+	// A downstream call the provider makes with the verified context keeps
+	// the origin
+	providerToken, _ := generateToken()
+	handshake.issueToken(providerID, "kv", providerToken)
+	nextReq := httptest.NewRequest("POST", "/services/kv/"+providerID+"/Get", nil)
+	nextReq.Header.Set(RuntimeIDHeader, providerID)
+	nextReq.Header.Set("Authorization", "Bearer "+providerToken)
+	propagateCaller(withCallerInfo(context.Background(), info), nextReq.Header)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, nextReq)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for downstream call, got %d: %s", w.Code, w.Body.String())
+	}
+	next := <-seen
+	if next.RuntimeID != providerID || next.Origin == nil || next.Origin.Identity != "alice" {
+		t.Errorf("Expected new caller with inherited origin, got %+v", next)
+	}
+	if next.OriginAsserted {
+		t.Error("Expected inherited origin not marked asserted")
+	}
+
+	// Another plugin can't replay a context it was not issued
+	replayReq := newTestCaller(t, handshake)("POST", "/services/kv/"+providerID+"/Get", nil)
+	propagateCaller(withCallerInfo(context.Background(), info), replayReq.Header)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, replayReq)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for replayed caller context, got %d", w.Code)
+	}
+}
+
+func TestServiceRouter_RejectsForgedCallerContext(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Signed by a key other than the router's
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	forged, _ := signCallerContext(otherKey, &CallerInfo{
+		RuntimeID: "admin",
+		Origin:    &AuthContext{Identity: "root"},
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+
+	req := newTestCaller(t, handshake)("POST", "/services/kv/"+providerID+"/Get", nil)
+	req.Header.Set(CallerContextHeader, forged)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for forged caller context, got %d", w.Code)
+	}
+}
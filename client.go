@@ -352,13 +352,20 @@ func (c *Client) SetRuntimeIdentity(runtimeID, runtimeToken, hostURL string) {
 	c.runtimeToken = runtimeToken
 
 	// Update endpoint if host URL provided
+	endpointChanged := false
 	if hostURL != "" && c.cfg.Endpoint != hostURL {
 		c.cfg.Endpoint = hostURL
 		c.cfg.HostURL = hostURL
+		endpointChanged = true
+	}
+
+	// Managed plugins never call Connect, so create the HTTP client here
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{}
 	}
 
 	// Initialize Phase 2 clients if not already done
-	if c.lifecycleClient == nil && c.httpClient != nil {
+	if c.lifecycleClient == nil || endpointChanged {
 		c.lifecycleClient = connectpluginv1connect.NewPluginLifecycleClient(
 			c.httpClient,
 			c.cfg.Endpoint,
@@ -398,6 +405,75 @@ func (c *Client) ReportHealth(ctx context.Context, state connectpluginv1.HealthS
 	return err
 }
 
+// RegisterServices registers every service in Metadata.Provides with the
+// host's service registry. metadata is attached to each registration
+// (e.g. "base_url" for plugins the host reaches over the network).
+// This is a Phase 2 feature - only works if runtime identity was assigned.
+func (c *Client) RegisterServices(ctx context.Context, metadata map[string]string) error {
+	_, err := c.registerServicesFrom(ctx, metadata, 0)
+	return err
+}
+
+// registerServicesFrom registers the services in Metadata.Provides starting
+// at index start. Returns the index of the first service not registered, so
+// a failed call can be resumed without registering a service twice.
+func (c *Client) registerServicesFrom(ctx context.Context, metadata map[string]string, start int) (int, error) {
+	c.mu.RLock()
+	registryClient := c.registryClient
+	runtimeID := c.runtimeID
+	runtimeToken := c.runtimeToken
+	provides := c.cfg.Metadata.Provides
+	c.mu.RUnlock()
+
+	if registryClient == nil {
+		return start, fmt.Errorf("RegisterServices requires Phase 2 runtime identity (provide SelfID in ClientConfig)")
+	}
+
+	for i := start; i < len(provides); i++ {
+		svc := provides[i]
+		req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  svc.Type,
+			Version:      svc.Version,
+			EndpointPath: svc.Path,
+			Metadata:     metadata,
+		})
+
+		// Add runtime identity headers
+		req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
+		req.Header().Set("Authorization", "Bearer "+runtimeToken)
+
+		if _, err := registryClient.RegisterService(ctx, req); err != nil {
+			return i, fmt.Errorf("register service %q: %w", svc.Type, err)
+		}
+	}
+	return len(provides), nil
+}
+
+// WatchPluginHealth streams health transitions of a plugin, or of all plugins
+// if runtimeID is empty. The stream starts with the current state of each.
+// This is a Phase 2 feature - only works if runtime identity was assigned.
+func (c *Client) WatchPluginHealth(ctx context.Context, runtimeID string) (*connect.ServerStreamForClient[connectpluginv1.PluginHealthEvent], error) {
+	c.mu.RLock()
+	lifecycleClient := c.lifecycleClient
+	selfRuntimeID := c.runtimeID
+	runtimeToken := c.runtimeToken
+	c.mu.RUnlock()
+
+	if lifecycleClient == nil {
+		return nil, fmt.Errorf("WatchPluginHealth requires Phase 2 runtime identity (provide SelfID in ClientConfig)")
+	}
+
+	req := connect.NewRequest(&connectpluginv1.WatchPluginHealthRequest{
+		RuntimeId: runtimeID,
+	})
+
+	// Add runtime identity headers
+	req.Header().Set("X-Plugin-Runtime-ID", selfRuntimeID)
+	req.Header().Set("Authorization", "Bearer "+runtimeToken)
+
+	return lifecycleClient.WatchPluginHealth(ctx, req)
+}
+
 // Close closes the client and releases resources.
 // This should be called when the client is no longer needed.
 func (c *Client) Close() error {
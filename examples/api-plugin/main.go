@@ -54,6 +54,14 @@ func main() {
 		log.Fatalf("Failed to create client: %v", err)
 	}
 
+	// Only the host may assign our identity or shut us down; without a
+	// provisioned secret these calls are rejected
+	hostSecret, err := connectplugin.HostSecretFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to read host secret: %v", err)
+	}
+	hostAuth := connect.WithInterceptors(connectplugin.NewHostAuthInterceptor(hostSecret))
+
 	ctx := context.Background()
 
 	if modelB {
@@ -71,11 +79,11 @@ func main() {
 	mux := http.NewServeMux()
 
 	controlHandler := &pluginControlHandler{client: client}
-	controlPath, controlH := connectpluginv1connect.NewPluginControlHandler(controlHandler)
+	controlPath, controlH := connectpluginv1connect.NewPluginControlHandler(controlHandler, hostAuth)
 	mux.Handle(controlPath, controlH)
 
 	identityHandler := &pluginIdentityHandler{client: client}
-	identityPath, identityH := connectpluginv1connect.NewPluginIdentityHandler(identityHandler)
+	identityPath, identityH := connectpluginv1connect.NewPluginIdentityHandler(identityHandler, hostAuth)
 	mux.Handle(identityPath, identityH)
 
 	mux.HandleFunc("/api.v1.API/Shorten", api.Shorten)
@@ -291,6 +299,8 @@ func registerServices(ctx context.Context, client *connectplugin.Client) {
 }
 
 type pluginControlHandler struct {
+	connectpluginv1connect.UnimplementedPluginControlHandler
+
 	client *connectplugin.Client
 }
 
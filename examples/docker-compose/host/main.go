@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 
+	"connectrpc.com/connect"
 	connectplugin "github.com/masegraye/connect-plugin-go"
 	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
 )
@@ -22,15 +23,18 @@ func main() {
 	registry := connectplugin.NewServiceRegistry(lifecycle)
 	router := connectplugin.NewServiceRouter(handshake, registry, lifecycle)
 
+	// Phase 2 host RPCs require the runtime token issued at handshake
+	runtimeAuth := connect.WithInterceptors(connectplugin.NewRuntimeAuthInterceptor(handshake))
+
 	mux := http.NewServeMux()
 
 	handshakePath, handshakeHandler := connectpluginv1connect.NewHandshakeServiceHandler(handshake)
 	mux.Handle(handshakePath, handshakeHandler)
 
-	lifecyclePath, lifecycleHandler := connectpluginv1connect.NewPluginLifecycleHandler(lifecycle)
+	lifecyclePath, lifecycleHandler := connectpluginv1connect.NewPluginLifecycleHandler(lifecycle, runtimeAuth)
 	mux.Handle(lifecyclePath, lifecycleHandler)
 
-	registryPath, registryHandler := connectpluginv1connect.NewServiceRegistryHandler(registry)
+	registryPath, registryHandler := connectpluginv1connect.NewServiceRegistryHandler(registry, runtimeAuth)
 	mux.Handle(registryPath, registryHandler)
 
 	mux.Handle("/services/", router)
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"time"
 
+	"connectrpc.com/connect"
 	connectplugin "github.com/masegraye/connect-plugin-go"
 	loggercap "github.com/masegraye/connect-plugin-go/examples/capabilities/logger"
 	"github.com/masegraye/connect-plugin-go/examples/kv/gen/kvv1delegate"
@@ -33,12 +34,15 @@ func main() {
 			router := connectplugin.NewServiceRouter(handshake, registry, lifecycle)
 			platform := connectplugin.NewPlatform(registry, lifecycle, router)
 
+			// Phase 2 host RPCs require the runtime token issued at handshake
+			runtimeAuth := connect.WithInterceptors(connectplugin.NewRuntimeAuthInterceptor(handshake))
+
 			mux := http.NewServeMux()
 			handshakePath, handshakeHandler := connectpluginv1connect.NewHandshakeServiceHandler(handshake)
 			mux.Handle(handshakePath, handshakeHandler)
-			lifecyclePath, lifecycleHandler := connectpluginv1connect.NewPluginLifecycleHandler(lifecycle)
+			lifecyclePath, lifecycleHandler := connectpluginv1connect.NewPluginLifecycleHandler(lifecycle, runtimeAuth)
 			mux.Handle(lifecyclePath, lifecycleHandler)
-			registryPath, registryHandler := connectpluginv1connect.NewServiceRegistryHandler(registry)
+			registryPath, registryHandler := connectpluginv1connect.NewServiceRegistryHandler(registry, runtimeAuth)
 			mux.Handle(registryPath, registryHandler)
 			mux.Handle("/services/", platform.Router())
 
@@ -11,15 +11,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
 
 	"connectrpc.com/connect"
 	connectplugin "github.com/masegraye/connect-plugin-go"
 	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
-	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
 )
 
 func main() {
@@ -28,9 +25,7 @@ func main() {
 		port = "8082"
 	}
 	hostURL := os.Getenv("HOST_URL")
-
-	modelB := hostURL != ""
-	if !modelB {
+	if hostURL == "" {
 		hostURL = "http://localhost:8080"
 	}
 
@@ -53,53 +48,31 @@ func main() {
 		log.Fatalf("Failed to create client: %v", err)
 	}
 
-	ctx := context.Background()
-
-	if modelB {
-		if err := client.Connect(ctx); err != nil {
-			log.Fatalf("Failed to connect: %v", err)
-		}
-		log.Printf("Storage plugin started (Unmanaged) with runtime_id: %s", client.RuntimeID())
-		registerServices(ctx, client)
-	} else {
-		log.Printf("Storage plugin started (Managed) - waiting for host")
-	}
-
 	storage := &storageService{
 		data:   make(map[string]string),
 		client: client,
 	}
 
 	mux := http.NewServeMux()
-
-	controlHandler := &pluginControlHandler{client: client}
-	controlPath, controlH := connectpluginv1connect.NewPluginControlHandler(controlHandler)
-	mux.Handle(controlPath, controlH)
-
-	identityHandler := &pluginIdentityHandler{client: client}
-	identityPath, identityH := connectpluginv1connect.NewPluginIdentityHandler(identityHandler)
-	mux.Handle(identityPath, identityH)
-
 	mux.HandleFunc("/storage.v1.Storage/Store", storage.Store)
 	mux.HandleFunc("/storage.v1.Storage/Get", storage.Get)
 
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
-	}
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-sigCh
-		log.Println("Shutting down storage plugin")
-		server.Shutdown(context.Background())
-	}()
-
-	log.Printf("Storage plugin listening on :%s", port)
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		log.Fatalf("Server error: %v", err)
+	// ServePlugin handles both deployment models:
+	// - If HOST_URL is set → Unmanaged (self-registering, plugin initiates handshake)
+	// - If HOST_URL is empty → Managed (platform-managed, wait for host to call us)
+	err = connectplugin.ServePlugin(&connectplugin.PluginServeConfig{
+		Client: client,
+		Addr:   ":" + port,
+		Handlers: map[string]http.Handler{
+			"/storage.v1.Storage/": mux,
+		},
+		Health:         health(client),
+		HealthInterval: 10 * time.Second,
+	})
+	if err != nil {
+		log.Fatalf("Storage plugin error: %v", err)
 	}
+	log.Println("Storage plugin stopped")
 }
 
 type storageService struct {
@@ -202,136 +175,38 @@ func (s *storageService) logOperation(ctx context.Context, message string) {
 	}
 }
 
-func registerServices(ctx context.Context, client *connectplugin.Client) {
-	regClient := client.RegistryClient()
-	if regClient == nil {
-		log.Println("Registry client not available")
-		return
-	}
-
-	// Get my own endpoint URL for router registration
-	myPort := os.Getenv("PORT")
-	if myPort == "" {
-		myPort = "8082"
-	}
-	myHost := os.Getenv("HOSTNAME")
-	if myHost == "" {
-		myHost = "localhost"
+// health reports DEGRADED while the logger dependency is not available.
+func health(client *connectplugin.Client) func(ctx context.Context) *connectpluginv1.GetHealthResponse {
+	return func(ctx context.Context) *connectpluginv1.GetHealthResponse {
+		if err := discover(ctx, client, "logger"); err != nil {
+			log.Printf("Logger not available yet, reporting degraded: %v", err)
+			return &connectpluginv1.GetHealthResponse{
+				State:                   connectpluginv1.HealthState_HEALTH_STATE_DEGRADED,
+				Reason:                  "logger dependency not available",
+				UnavailableDependencies: []string{"logger"},
+			}
+		}
+		return &connectpluginv1.GetHealthResponse{
+			State:  connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+			Reason: "operational",
+		}
 	}
-	myBaseURL := fmt.Sprintf("http://%s:%s", myHost, myPort)
-
-	for _, svc := range client.Config().Metadata.Provides {
-		regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
-			ServiceType:  svc.Type,
-			Version:      svc.Version,
-			EndpointPath: svc.Path,
-			Metadata: map[string]string{
-				"base_url": myBaseURL,
-			},
-		})
-		regReq.Header().Set("X-Plugin-Runtime-ID", client.RuntimeID())
-		regReq.Header().Set("Authorization", "Bearer "+client.RuntimeToken())
+}
 
-		if _, err := regClient.RegisterService(ctx, regReq); err != nil {
-			log.Fatalf("Failed to register service %s: %v", svc.Type, err)
-		}
-		log.Printf("Registered service: %s v%s at %s", svc.Type, svc.Version, myBaseURL)
+// discover checks that a provider of the service type is registered.
+func discover(ctx context.Context, client *connectplugin.Client, serviceType string) error {
+	regClient := client.RegistryClient()
+	if regClient == nil {
+		return fmt.Errorf("registry client not available")
 	}
 
-	time.Sleep(200 * time.Millisecond)
 	discReq := connect.NewRequest(&connectpluginv1.DiscoverServiceRequest{
-		ServiceType: "logger",
+		ServiceType: serviceType,
 		MinVersion:  "1.0.0",
 	})
 	discReq.Header().Set("X-Plugin-Runtime-ID", client.RuntimeID())
 	discReq.Header().Set("Authorization", "Bearer "+client.RuntimeToken())
 
 	_, err := regClient.DiscoverService(ctx, discReq)
-	if err != nil {
-		log.Printf("Logger not available yet, reporting degraded: %v", err)
-		client.ReportHealth(ctx, connectpluginv1.HealthState_HEALTH_STATE_DEGRADED,
-			"logger dependency not available", []string{"logger"})
-	} else {
-		log.Println("Logger discovered, reporting healthy")
-		client.ReportHealth(ctx, connectpluginv1.HealthState_HEALTH_STATE_HEALTHY, "", nil)
-	}
-}
-
-type pluginControlHandler struct {
-	client *connectplugin.Client
-}
-
-func (h *pluginControlHandler) GetHealth(
-	ctx context.Context,
-	req *connect.Request[connectpluginv1.GetHealthRequest],
-) (*connect.Response[connectpluginv1.GetHealthResponse], error) {
-	return connect.NewResponse(&connectpluginv1.GetHealthResponse{
-		State:  connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
-		Reason: "operational",
-	}), nil
-}
-
-func (h *pluginControlHandler) Shutdown(
-	ctx context.Context,
-	req *connect.Request[connectpluginv1.ShutdownRequest],
-) (*connect.Response[connectpluginv1.ShutdownResponse], error) {
-	log.Printf("Shutdown requested (grace: %ds)", req.Msg.GracePeriodSeconds)
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		os.Exit(0)
-	}()
-	return connect.NewResponse(&connectpluginv1.ShutdownResponse{Acknowledged: true}), nil
-}
-
-type pluginIdentityHandler struct {
-	client *connectplugin.Client
-}
-
-func (h *pluginIdentityHandler) GetPluginInfo(
-	ctx context.Context,
-	req *connect.Request[connectpluginv1.GetPluginInfoRequest],
-) (*connect.Response[connectpluginv1.GetPluginInfoResponse], error) {
-	cfg := h.client.Config()
-
-	provides := make([]*connectpluginv1.ServiceDeclaration, len(cfg.Metadata.Provides))
-	for i, svc := range cfg.Metadata.Provides {
-		provides[i] = &connectpluginv1.ServiceDeclaration{
-			Type:    svc.Type,
-			Version: svc.Version,
-			Path:    svc.Path,
-		}
-	}
-
-	requires := make([]*connectpluginv1.ServiceDependency, len(cfg.Metadata.Requires))
-	for i, dep := range cfg.Metadata.Requires {
-		requires[i] = &connectpluginv1.ServiceDependency{
-			Type:               dep.Type,
-			MinVersion:         dep.MinVersion,
-			RequiredForStartup: dep.RequiredForStartup,
-			WatchForChanges:    dep.WatchForChanges,
-		}
-	}
-
-	return connect.NewResponse(&connectpluginv1.GetPluginInfoResponse{
-		SelfId:      cfg.SelfID,
-		SelfVersion: cfg.SelfVersion,
-		Provides:    provides,
-		Requires:    requires,
-		Metadata: map[string]string{
-			"name":    cfg.Metadata.Name,
-			"version": cfg.Metadata.Version,
-		},
-	}), nil
-}
-
-func (h *pluginIdentityHandler) SetRuntimeIdentity(
-	ctx context.Context,
-	req *connect.Request[connectpluginv1.SetRuntimeIdentityRequest],
-) (*connect.Response[connectpluginv1.SetRuntimeIdentityResponse], error) {
-	h.client.SetRuntimeIdentity(req.Msg.RuntimeId, req.Msg.RuntimeToken, req.Msg.HostUrl)
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		registerServices(context.Background(), h.client)
-	}()
-	return connect.NewResponse(&connectpluginv1.SetRuntimeIdentityResponse{Acknowledged: true}), nil
+	return err
 }
@@ -38,10 +38,22 @@ const (
 	// PluginLifecycleReportHealthProcedure is the fully-qualified name of the PluginLifecycle's
 	// ReportHealth RPC.
 	PluginLifecycleReportHealthProcedure = "/connectplugin.v1.PluginLifecycle/ReportHealth"
+	// PluginLifecycleWatchPluginHealthProcedure is the fully-qualified name of the PluginLifecycle's
+	// WatchPluginHealth RPC.
+	PluginLifecycleWatchPluginHealthProcedure = "/connectplugin.v1.PluginLifecycle/WatchPluginHealth"
 	// PluginControlGetHealthProcedure is the fully-qualified name of the PluginControl's GetHealth RPC.
 	PluginControlGetHealthProcedure = "/connectplugin.v1.PluginControl/GetHealth"
 	// PluginControlShutdownProcedure is the fully-qualified name of the PluginControl's Shutdown RPC.
 	PluginControlShutdownProcedure = "/connectplugin.v1.PluginControl/Shutdown"
+	// PluginControlDrainProcedure is the fully-qualified name of the PluginControl's Drain RPC.
+	PluginControlDrainProcedure = "/connectplugin.v1.PluginControl/Drain"
+	// PluginControlPauseProcedure is the fully-qualified name of the PluginControl's Pause RPC.
+	PluginControlPauseProcedure = "/connectplugin.v1.PluginControl/Pause"
+	// PluginControlResumeProcedure is the fully-qualified name of the PluginControl's Resume RPC.
+	PluginControlResumeProcedure = "/connectplugin.v1.PluginControl/Resume"
+	// PluginControlReconfigureProcedure is the fully-qualified name of the PluginControl's Reconfigure
+	// RPC.
+	PluginControlReconfigureProcedure = "/connectplugin.v1.PluginControl/Reconfigure"
 )
 
 // PluginLifecycleClient is a client for the connectplugin.v1.PluginLifecycle service.
@@ -49,6 +61,9 @@ type PluginLifecycleClient interface {
 	// ReportHealth allows plugin to report its health state to the host.
 	// Host uses this to decide whether to route traffic to the plugin.
 	ReportHealth(context.Context, *connect.Request[v1.ReportHealthRequest]) (*connect.Response[v1.ReportHealthResponse], error)
+	// WatchPluginHealth streams health transitions of one or all plugins.
+	// The stream starts with the current state of each matching plugin.
+	WatchPluginHealth(context.Context, *connect.Request[v1.WatchPluginHealthRequest]) (*connect.ServerStreamForClient[v1.PluginHealthEvent], error)
 }
 
 // NewPluginLifecycleClient constructs a client for the connectplugin.v1.PluginLifecycle service. By
@@ -68,12 +83,19 @@ func NewPluginLifecycleClient(httpClient connect.HTTPClient, baseURL string, opt
 			connect.WithSchema(pluginLifecycleMethods.ByName("ReportHealth")),
 			connect.WithClientOptions(opts...),
 		),
+		watchPluginHealth: connect.NewClient[v1.WatchPluginHealthRequest, v1.PluginHealthEvent](
+			httpClient,
+			baseURL+PluginLifecycleWatchPluginHealthProcedure,
+			connect.WithSchema(pluginLifecycleMethods.ByName("WatchPluginHealth")),
+			connect.WithClientOptions(opts...),
+		),
 	}
 }
 
 // pluginLifecycleClient implements PluginLifecycleClient.
 type pluginLifecycleClient struct {
-	reportHealth *connect.Client[v1.ReportHealthRequest, v1.ReportHealthResponse]
+	reportHealth      *connect.Client[v1.ReportHealthRequest, v1.ReportHealthResponse]
+	watchPluginHealth *connect.Client[v1.WatchPluginHealthRequest, v1.PluginHealthEvent]
 }
 
 // ReportHealth calls connectplugin.v1.PluginLifecycle.ReportHealth.
@@ -81,11 +103,19 @@ func (c *pluginLifecycleClient) ReportHealth(ctx context.Context, req *connect.R
 	return c.reportHealth.CallUnary(ctx, req)
 }
 
+// WatchPluginHealth calls connectplugin.v1.PluginLifecycle.WatchPluginHealth.
+func (c *pluginLifecycleClient) WatchPluginHealth(ctx context.Context, req *connect.Request[v1.WatchPluginHealthRequest]) (*connect.ServerStreamForClient[v1.PluginHealthEvent], error) {
+	return c.watchPluginHealth.CallServerStream(ctx, req)
+}
+
 // PluginLifecycleHandler is an implementation of the connectplugin.v1.PluginLifecycle service.
 type PluginLifecycleHandler interface {
 	// ReportHealth allows plugin to report its health state to the host.
 	// Host uses this to decide whether to route traffic to the plugin.
 	ReportHealth(context.Context, *connect.Request[v1.ReportHealthRequest]) (*connect.Response[v1.ReportHealthResponse], error)
+	// WatchPluginHealth streams health transitions of one or all plugins.
+	// The stream starts with the current state of each matching plugin.
+	WatchPluginHealth(context.Context, *connect.Request[v1.WatchPluginHealthRequest], *connect.ServerStream[v1.PluginHealthEvent]) error
 }
 
 // NewPluginLifecycleHandler builds an HTTP handler from the service implementation. It returns the
@@ -101,10 +131,18 @@ func NewPluginLifecycleHandler(svc PluginLifecycleHandler, opts ...connect.Handl
 		connect.WithSchema(pluginLifecycleMethods.ByName("ReportHealth")),
 		connect.WithHandlerOptions(opts...),
 	)
+	pluginLifecycleWatchPluginHealthHandler := connect.NewServerStreamHandler(
+		PluginLifecycleWatchPluginHealthProcedure,
+		svc.WatchPluginHealth,
+		connect.WithSchema(pluginLifecycleMethods.ByName("WatchPluginHealth")),
+		connect.WithHandlerOptions(opts...),
+	)
 	return "/connectplugin.v1.PluginLifecycle/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case PluginLifecycleReportHealthProcedure:
 			pluginLifecycleReportHealthHandler.ServeHTTP(w, r)
+		case PluginLifecycleWatchPluginHealthProcedure:
+			pluginLifecycleWatchPluginHealthHandler.ServeHTTP(w, r)
 		default:
 			http.NotFound(w, r)
 		}
@@ -118,6 +156,10 @@ func (UnimplementedPluginLifecycleHandler) ReportHealth(context.Context, *connec
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("connectplugin.v1.PluginLifecycle.ReportHealth is not implemented"))
 }
 
+func (UnimplementedPluginLifecycleHandler) WatchPluginHealth(context.Context, *connect.Request[v1.WatchPluginHealthRequest], *connect.ServerStream[v1.PluginHealthEvent]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("connectplugin.v1.PluginLifecycle.WatchPluginHealth is not implemented"))
+}
+
 // PluginControlClient is a client for the connectplugin.v1.PluginControl service.
 type PluginControlClient interface {
 	// GetHealth checks plugin's current health state.
@@ -126,6 +168,15 @@ type PluginControlClient interface {
 	// Shutdown requests graceful shutdown.
 	// Plugin should stop accepting new requests, finish in-flight work, and exit.
 	Shutdown(context.Context, *connect.Request[v1.ShutdownRequest]) (*connect.Response[v1.ShutdownResponse], error)
+	// Drain asks the plugin to stop accepting new work.
+	// Returns once in-flight work has finished or the timeout elapsed.
+	Drain(context.Context, *connect.Request[v1.DrainRequest]) (*connect.Response[v1.DrainResponse], error)
+	// Pause suspends processing. New work waits until Resume.
+	Pause(context.Context, *connect.Request[v1.PauseRequest]) (*connect.Response[v1.PauseResponse], error)
+	// Resume continues processing after Pause or Drain.
+	Resume(context.Context, *connect.Request[v1.ResumeRequest]) (*connect.Response[v1.ResumeResponse], error)
+	// Reconfigure applies a new configuration without restart.
+	Reconfigure(context.Context, *connect.Request[v1.ReconfigureRequest]) (*connect.Response[v1.ReconfigureResponse], error)
 }
 
 // NewPluginControlClient constructs a client for the connectplugin.v1.PluginControl service. By
@@ -151,13 +202,41 @@ func NewPluginControlClient(httpClient connect.HTTPClient, baseURL string, opts
 			connect.WithSchema(pluginControlMethods.ByName("Shutdown")),
 			connect.WithClientOptions(opts...),
 		),
+		drain: connect.NewClient[v1.DrainRequest, v1.DrainResponse](
+			httpClient,
+			baseURL+PluginControlDrainProcedure,
+			connect.WithSchema(pluginControlMethods.ByName("Drain")),
+			connect.WithClientOptions(opts...),
+		),
+		pause: connect.NewClient[v1.PauseRequest, v1.PauseResponse](
+			httpClient,
+			baseURL+PluginControlPauseProcedure,
+			connect.WithSchema(pluginControlMethods.ByName("Pause")),
+			connect.WithClientOptions(opts...),
+		),
+		resume: connect.NewClient[v1.ResumeRequest, v1.ResumeResponse](
+			httpClient,
+			baseURL+PluginControlResumeProcedure,
+			connect.WithSchema(pluginControlMethods.ByName("Resume")),
+			connect.WithClientOptions(opts...),
+		),
+		reconfigure: connect.NewClient[v1.ReconfigureRequest, v1.ReconfigureResponse](
+			httpClient,
+			baseURL+PluginControlReconfigureProcedure,
+			connect.WithSchema(pluginControlMethods.ByName("Reconfigure")),
+			connect.WithClientOptions(opts...),
+		),
 	}
 }
 
 // pluginControlClient implements PluginControlClient.
 type pluginControlClient struct {
-	getHealth *connect.Client[v1.GetHealthRequest, v1.GetHealthResponse]
-	shutdown  *connect.Client[v1.ShutdownRequest, v1.ShutdownResponse]
+	getHealth   *connect.Client[v1.GetHealthRequest, v1.GetHealthResponse]
+	shutdown    *connect.Client[v1.ShutdownRequest, v1.ShutdownResponse]
+	drain       *connect.Client[v1.DrainRequest, v1.DrainResponse]
+	pause       *connect.Client[v1.PauseRequest, v1.PauseResponse]
+	resume      *connect.Client[v1.ResumeRequest, v1.ResumeResponse]
+	reconfigure *connect.Client[v1.ReconfigureRequest, v1.ReconfigureResponse]
 }
 
 // GetHealth calls connectplugin.v1.PluginControl.GetHealth.
@@ -170,6 +249,26 @@ func (c *pluginControlClient) Shutdown(ctx context.Context, req *connect.Request
 	return c.shutdown.CallUnary(ctx, req)
 }
 
+// Drain calls connectplugin.v1.PluginControl.Drain.
+func (c *pluginControlClient) Drain(ctx context.Context, req *connect.Request[v1.DrainRequest]) (*connect.Response[v1.DrainResponse], error) {
+	return c.drain.CallUnary(ctx, req)
+}
+
+// Pause calls connectplugin.v1.PluginControl.Pause.
+func (c *pluginControlClient) Pause(ctx context.Context, req *connect.Request[v1.PauseRequest]) (*connect.Response[v1.PauseResponse], error) {
+	return c.pause.CallUnary(ctx, req)
+}
+
+// Resume calls connectplugin.v1.PluginControl.Resume.
+func (c *pluginControlClient) Resume(ctx context.Context, req *connect.Request[v1.ResumeRequest]) (*connect.Response[v1.ResumeResponse], error) {
+	return c.resume.CallUnary(ctx, req)
+}
+
+// Reconfigure calls connectplugin.v1.PluginControl.Reconfigure.
+func (c *pluginControlClient) Reconfigure(ctx context.Context, req *connect.Request[v1.ReconfigureRequest]) (*connect.Response[v1.ReconfigureResponse], error) {
+	return c.reconfigure.CallUnary(ctx, req)
+}
+
 // PluginControlHandler is an implementation of the connectplugin.v1.PluginControl service.
 type PluginControlHandler interface {
 	// GetHealth checks plugin's current health state.
@@ -178,6 +277,15 @@ type PluginControlHandler interface {
 	// Shutdown requests graceful shutdown.
 	// Plugin should stop accepting new requests, finish in-flight work, and exit.
 	Shutdown(context.Context, *connect.Request[v1.ShutdownRequest]) (*connect.Response[v1.ShutdownResponse], error)
+	// Drain asks the plugin to stop accepting new work.
+	// Returns once in-flight work has finished or the timeout elapsed.
+	Drain(context.Context, *connect.Request[v1.DrainRequest]) (*connect.Response[v1.DrainResponse], error)
+	// Pause suspends processing. New work waits until Resume.
+	Pause(context.Context, *connect.Request[v1.PauseRequest]) (*connect.Response[v1.PauseResponse], error)
+	// Resume continues processing after Pause or Drain.
+	Resume(context.Context, *connect.Request[v1.ResumeRequest]) (*connect.Response[v1.ResumeResponse], error)
+	// Reconfigure applies a new configuration without restart.
+	Reconfigure(context.Context, *connect.Request[v1.ReconfigureRequest]) (*connect.Response[v1.ReconfigureResponse], error)
 }
 
 // NewPluginControlHandler builds an HTTP handler from the service implementation. It returns the
@@ -199,12 +307,44 @@ func NewPluginControlHandler(svc PluginControlHandler, opts ...connect.HandlerOp
 		connect.WithSchema(pluginControlMethods.ByName("Shutdown")),
 		connect.WithHandlerOptions(opts...),
 	)
+	pluginControlDrainHandler := connect.NewUnaryHandler(
+		PluginControlDrainProcedure,
+		svc.Drain,
+		connect.WithSchema(pluginControlMethods.ByName("Drain")),
+		connect.WithHandlerOptions(opts...),
+	)
+	pluginControlPauseHandler := connect.NewUnaryHandler(
+		PluginControlPauseProcedure,
+		svc.Pause,
+		connect.WithSchema(pluginControlMethods.ByName("Pause")),
+		connect.WithHandlerOptions(opts...),
+	)
+	pluginControlResumeHandler := connect.NewUnaryHandler(
+		PluginControlResumeProcedure,
+		svc.Resume,
+		connect.WithSchema(pluginControlMethods.ByName("Resume")),
+		connect.WithHandlerOptions(opts...),
+	)
+	pluginControlReconfigureHandler := connect.NewUnaryHandler(
+		PluginControlReconfigureProcedure,
+		svc.Reconfigure,
+		connect.WithSchema(pluginControlMethods.ByName("Reconfigure")),
+		connect.WithHandlerOptions(opts...),
+	)
 	return "/connectplugin.v1.PluginControl/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case PluginControlGetHealthProcedure:
 			pluginControlGetHealthHandler.ServeHTTP(w, r)
 		case PluginControlShutdownProcedure:
 			pluginControlShutdownHandler.ServeHTTP(w, r)
+		case PluginControlDrainProcedure:
+			pluginControlDrainHandler.ServeHTTP(w, r)
+		case PluginControlPauseProcedure:
+			pluginControlPauseHandler.ServeHTTP(w, r)
+		case PluginControlResumeProcedure:
+			pluginControlResumeHandler.ServeHTTP(w, r)
+		case PluginControlReconfigureProcedure:
+			pluginControlReconfigureHandler.ServeHTTP(w, r)
 		default:
 			http.NotFound(w, r)
 		}
@@ -221,3 +361,19 @@ func (UnimplementedPluginControlHandler) GetHealth(context.Context, *connect.Req
 func (UnimplementedPluginControlHandler) Shutdown(context.Context, *connect.Request[v1.ShutdownRequest]) (*connect.Response[v1.ShutdownResponse], error) {
 	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("connectplugin.v1.PluginControl.Shutdown is not implemented"))
 }
+
+func (UnimplementedPluginControlHandler) Drain(context.Context, *connect.Request[v1.DrainRequest]) (*connect.Response[v1.DrainResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("connectplugin.v1.PluginControl.Drain is not implemented"))
+}
+
+func (UnimplementedPluginControlHandler) Pause(context.Context, *connect.Request[v1.PauseRequest]) (*connect.Response[v1.PauseResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("connectplugin.v1.PluginControl.Pause is not implemented"))
+}
+
+func (UnimplementedPluginControlHandler) Resume(context.Context, *connect.Request[v1.ResumeRequest]) (*connect.Response[v1.ResumeResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("connectplugin.v1.PluginControl.Resume is not implemented"))
+}
+
+func (UnimplementedPluginControlHandler) Reconfigure(context.Context, *connect.Request[v1.ReconfigureRequest]) (*connect.Response[v1.ReconfigureResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("connectplugin.v1.PluginControl.Reconfigure is not implemented"))
+}
@@ -60,6 +60,8 @@ type ServiceRegistryClient interface {
 	DiscoverService(context.Context, *connect.Request[v1.DiscoverServiceRequest]) (*connect.Response[v1.DiscoverServiceResponse], error)
 	// WatchService streams service availability updates.
 	// Plugins subscribe to be notified when services come online, go offline, or change state.
+	// Each event carries the full provider set and a revision. Slow consumers are
+	// never dropped: intermediate states are coalesced and the latest state is delivered.
 	WatchService(context.Context, *connect.Request[v1.WatchServiceRequest]) (*connect.ServerStreamForClient[v1.WatchServiceEvent], error)
 }
 
@@ -142,6 +144,8 @@ type ServiceRegistryHandler interface {
 	DiscoverService(context.Context, *connect.Request[v1.DiscoverServiceRequest]) (*connect.Response[v1.DiscoverServiceResponse], error)
 	// WatchService streams service availability updates.
 	// Plugins subscribe to be notified when services come online, go offline, or change state.
+	// Each event carries the full provider set and a revision. Slow consumers are
+	// never dropped: intermediate states are coalesced and the latest state is delivered.
 	WatchService(context.Context, *connect.Request[v1.WatchServiceRequest], *connect.ServerStream[v1.WatchServiceEvent]) error
 }
 
@@ -9,6 +9,7 @@ package connectpluginv1
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -179,6 +180,168 @@ func (*ReportHealthResponse) Descriptor() ([]byte, []int) {
 	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{1}
 }
 
+type WatchPluginHealthRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Runtime ID to watch. Empty watches all plugins.
+	RuntimeId     string `protobuf:"bytes,1,opt,name=runtime_id,json=runtimeId,proto3" json:"runtime_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchPluginHealthRequest) Reset() {
+	*x = WatchPluginHealthRequest{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchPluginHealthRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchPluginHealthRequest) ProtoMessage() {}
+
+func (x *WatchPluginHealthRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchPluginHealthRequest.ProtoReflect.Descriptor instead.
+func (*WatchPluginHealthRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WatchPluginHealthRequest) GetRuntimeId() string {
+	if x != nil {
+		return x.RuntimeId
+	}
+	return ""
+}
+
+type PluginHealthEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Runtime ID of the plugin.
+	RuntimeId string `protobuf:"bytes,1,opt,name=runtime_id,json=runtimeId,proto3" json:"runtime_id,omitempty"`
+	// Health state after the transition.
+	State HealthState `protobuf:"varint,2,opt,name=state,proto3,enum=connectplugin.v1.HealthState" json:"state,omitempty"`
+	// Health state before the transition (UNSPECIFIED if unknown).
+	PreviousState HealthState `protobuf:"varint,3,opt,name=previous_state,json=previousState,proto3,enum=connectplugin.v1.HealthState" json:"previous_state,omitempty"`
+	// Human-readable reason for the state.
+	Reason string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	// List of dependency service types currently unavailable.
+	UnavailableDependencies []string `protobuf:"bytes,5,rep,name=unavailable_dependencies,json=unavailableDependencies,proto3" json:"unavailable_dependencies,omitempty"`
+	// When the transition happened.
+	ChangedAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	// When the plugin last reported health (unset if it never reported).
+	ReportedAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=reported_at,json=reportedAt,proto3" json:"reported_at,omitempty"`
+	// True if the plugin was removed after missing heartbeats.
+	Removed bool `protobuf:"varint,8,opt,name=removed,proto3" json:"removed,omitempty"`
+	// True for the events describing current states when the watch starts.
+	Initial       bool `protobuf:"varint,9,opt,name=initial,proto3" json:"initial,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginHealthEvent) Reset() {
+	*x = PluginHealthEvent{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginHealthEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginHealthEvent) ProtoMessage() {}
+
+func (x *PluginHealthEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginHealthEvent.ProtoReflect.Descriptor instead.
+func (*PluginHealthEvent) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PluginHealthEvent) GetRuntimeId() string {
+	if x != nil {
+		return x.RuntimeId
+	}
+	return ""
+}
+
+func (x *PluginHealthEvent) GetState() HealthState {
+	if x != nil {
+		return x.State
+	}
+	return HealthState_HEALTH_STATE_UNSPECIFIED
+}
+
+func (x *PluginHealthEvent) GetPreviousState() HealthState {
+	if x != nil {
+		return x.PreviousState
+	}
+	return HealthState_HEALTH_STATE_UNSPECIFIED
+}
+
+func (x *PluginHealthEvent) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *PluginHealthEvent) GetUnavailableDependencies() []string {
+	if x != nil {
+		return x.UnavailableDependencies
+	}
+	return nil
+}
+
+func (x *PluginHealthEvent) GetChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return nil
+}
+
+func (x *PluginHealthEvent) GetReportedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReportedAt
+	}
+	return nil
+}
+
+func (x *PluginHealthEvent) GetRemoved() bool {
+	if x != nil {
+		return x.Removed
+	}
+	return false
+}
+
+func (x *PluginHealthEvent) GetInitial() bool {
+	if x != nil {
+		return x.Initial
+	}
+	return false
+}
+
 type GetHealthRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -187,7 +350,7 @@ type GetHealthRequest struct {
 
 func (x *GetHealthRequest) Reset() {
 	*x = GetHealthRequest{}
-	mi := &file_plugin_v1_lifecycle_proto_msgTypes[2]
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -199,7 +362,7 @@ func (x *GetHealthRequest) String() string {
 func (*GetHealthRequest) ProtoMessage() {}
 
 func (x *GetHealthRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_v1_lifecycle_proto_msgTypes[2]
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -212,7 +375,7 @@ func (x *GetHealthRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetHealthRequest.ProtoReflect.Descriptor instead.
 func (*GetHealthRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{2}
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{4}
 }
 
 type GetHealthResponse struct {
@@ -229,7 +392,7 @@ type GetHealthResponse struct {
 
 func (x *GetHealthResponse) Reset() {
 	*x = GetHealthResponse{}
-	mi := &file_plugin_v1_lifecycle_proto_msgTypes[3]
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -241,7 +404,7 @@ func (x *GetHealthResponse) String() string {
 func (*GetHealthResponse) ProtoMessage() {}
 
 func (x *GetHealthResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_v1_lifecycle_proto_msgTypes[3]
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -254,7 +417,7 @@ func (x *GetHealthResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetHealthResponse.ProtoReflect.Descriptor instead.
 func (*GetHealthResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{3}
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *GetHealthResponse) GetState() HealthState {
@@ -290,7 +453,7 @@ type ShutdownRequest struct {
 
 func (x *ShutdownRequest) Reset() {
 	*x = ShutdownRequest{}
-	mi := &file_plugin_v1_lifecycle_proto_msgTypes[4]
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -302,7 +465,7 @@ func (x *ShutdownRequest) String() string {
 func (*ShutdownRequest) ProtoMessage() {}
 
 func (x *ShutdownRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_v1_lifecycle_proto_msgTypes[4]
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -315,7 +478,7 @@ func (x *ShutdownRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShutdownRequest.ProtoReflect.Descriptor instead.
 func (*ShutdownRequest) Descriptor() ([]byte, []int) {
-	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{4}
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ShutdownRequest) GetGracePeriodSeconds() int32 {
@@ -342,7 +505,7 @@ type ShutdownResponse struct {
 
 func (x *ShutdownResponse) Reset() {
 	*x = ShutdownResponse{}
-	mi := &file_plugin_v1_lifecycle_proto_msgTypes[5]
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -354,7 +517,7 @@ func (x *ShutdownResponse) String() string {
 func (*ShutdownResponse) ProtoMessage() {}
 
 func (x *ShutdownResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_plugin_v1_lifecycle_proto_msgTypes[5]
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -367,7 +530,7 @@ func (x *ShutdownResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShutdownResponse.ProtoReflect.Descriptor instead.
 func (*ShutdownResponse) Descriptor() ([]byte, []int) {
-	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{5}
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ShutdownResponse) GetAcknowledged() bool {
@@ -377,16 +540,401 @@ func (x *ShutdownResponse) GetAcknowledged() bool {
 	return false
 }
 
+type DrainRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Maximum time in seconds to wait for in-flight work (0 = don't wait).
+	TimeoutSeconds int32 `protobuf:"varint,1,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	// Reason for draining (e.g., "plugin replacement").
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DrainRequest) Reset() {
+	*x = DrainRequest{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DrainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainRequest) ProtoMessage() {}
+
+func (x *DrainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainRequest.ProtoReflect.Descriptor instead.
+func (*DrainRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DrainRequest) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+func (x *DrainRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type DrainResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// True if all in-flight work finished.
+	Drained bool `protobuf:"varint,1,opt,name=drained,proto3" json:"drained,omitempty"`
+	// Number of requests still in flight.
+	InFlight      int64 `protobuf:"varint,2,opt,name=in_flight,json=inFlight,proto3" json:"in_flight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DrainResponse) Reset() {
+	*x = DrainResponse{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DrainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainResponse) ProtoMessage() {}
+
+func (x *DrainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainResponse.ProtoReflect.Descriptor instead.
+func (*DrainResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DrainResponse) GetDrained() bool {
+	if x != nil {
+		return x.Drained
+	}
+	return false
+}
+
+func (x *DrainResponse) GetInFlight() int64 {
+	if x != nil {
+		return x.InFlight
+	}
+	return 0
+}
+
+type PauseRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Reason for pausing.
+	Reason        string `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseRequest) Reset() {
+	*x = PauseRequest{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseRequest) ProtoMessage() {}
+
+func (x *PauseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseRequest.ProtoReflect.Descriptor instead.
+func (*PauseRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *PauseRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type PauseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseResponse) Reset() {
+	*x = PauseResponse{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseResponse) ProtoMessage() {}
+
+func (x *PauseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseResponse.ProtoReflect.Descriptor instead.
+func (*PauseResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{11}
+}
+
+type ResumeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeRequest) Reset() {
+	*x = ResumeRequest{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeRequest) ProtoMessage() {}
+
+func (x *ResumeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeRequest.ProtoReflect.Descriptor instead.
+func (*ResumeRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{12}
+}
+
+type ResumeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeResponse) Reset() {
+	*x = ResumeResponse{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeResponse) ProtoMessage() {}
+
+func (x *ResumeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeResponse.ProtoReflect.Descriptor instead.
+func (*ResumeResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{13}
+}
+
+type ReconfigureRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Configuration blob, opaque to the host.
+	Config []byte `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+	// Media type of config (e.g., "application/json").
+	ContentType string `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Version of the configuration, echoed back once applied.
+	Version       string `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconfigureRequest) Reset() {
+	*x = ReconfigureRequest{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconfigureRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconfigureRequest) ProtoMessage() {}
+
+func (x *ReconfigureRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconfigureRequest.ProtoReflect.Descriptor instead.
+func (*ReconfigureRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ReconfigureRequest) GetConfig() []byte {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *ReconfigureRequest) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *ReconfigureRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+type ReconfigureResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Version of the configuration now in effect.
+	Version       string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconfigureResponse) Reset() {
+	*x = ReconfigureResponse{}
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconfigureResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconfigureResponse) ProtoMessage() {}
+
+func (x *ReconfigureResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_v1_lifecycle_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconfigureResponse.ProtoReflect.Descriptor instead.
+func (*ReconfigureResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_v1_lifecycle_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ReconfigureResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
 var File_plugin_v1_lifecycle_proto protoreflect.FileDescriptor
 
 const file_plugin_v1_lifecycle_proto_rawDesc = "" +
 	"\n" +
-	"\x19plugin/v1/lifecycle.proto\x12\x10connectplugin.v1\"\x9d\x01\n" +
+	"\x19plugin/v1/lifecycle.proto\x12\x10connectplugin.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9d\x01\n" +
 	"\x13ReportHealthRequest\x123\n" +
 	"\x05state\x18\x01 \x01(\x0e2\x1d.connectplugin.v1.HealthStateR\x05state\x12\x16\n" +
 	"\x06reason\x18\x02 \x01(\tR\x06reason\x129\n" +
 	"\x18unavailable_dependencies\x18\x03 \x03(\tR\x17unavailableDependencies\"\x16\n" +
-	"\x14ReportHealthResponse\"\x12\n" +
+	"\x14ReportHealthResponse\"9\n" +
+	"\x18WatchPluginHealthRequest\x12\x1d\n" +
+	"\n" +
+	"runtime_id\x18\x01 \x01(\tR\truntimeId\"\xac\x03\n" +
+	"\x11PluginHealthEvent\x12\x1d\n" +
+	"\n" +
+	"runtime_id\x18\x01 \x01(\tR\truntimeId\x123\n" +
+	"\x05state\x18\x02 \x01(\x0e2\x1d.connectplugin.v1.HealthStateR\x05state\x12D\n" +
+	"\x0eprevious_state\x18\x03 \x01(\x0e2\x1d.connectplugin.v1.HealthStateR\rpreviousState\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\x129\n" +
+	"\x18unavailable_dependencies\x18\x05 \x03(\tR\x17unavailableDependencies\x129\n" +
+	"\n" +
+	"changed_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tchangedAt\x12;\n" +
+	"\vreported_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"reportedAt\x12\x18\n" +
+	"\aremoved\x18\b \x01(\bR\aremoved\x12\x18\n" +
+	"\ainitial\x18\t \x01(\bR\ainitial\"\x12\n" +
 	"\x10GetHealthRequest\"\x9b\x01\n" +
 	"\x11GetHealthResponse\x123\n" +
 	"\x05state\x18\x01 \x01(\x0e2\x1d.connectplugin.v1.HealthStateR\x05state\x12\x16\n" +
@@ -396,17 +944,39 @@ const file_plugin_v1_lifecycle_proto_rawDesc = "" +
 	"\x14grace_period_seconds\x18\x01 \x01(\x05R\x12gracePeriodSeconds\x12\x16\n" +
 	"\x06reason\x18\x02 \x01(\tR\x06reason\"6\n" +
 	"\x10ShutdownResponse\x12\"\n" +
-	"\facknowledged\x18\x01 \x01(\bR\facknowledged*|\n" +
+	"\facknowledged\x18\x01 \x01(\bR\facknowledged\"O\n" +
+	"\fDrainRequest\x12'\n" +
+	"\x0ftimeout_seconds\x18\x01 \x01(\x05R\x0etimeoutSeconds\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"F\n" +
+	"\rDrainResponse\x12\x18\n" +
+	"\adrained\x18\x01 \x01(\bR\adrained\x12\x1b\n" +
+	"\tin_flight\x18\x02 \x01(\x03R\binFlight\"&\n" +
+	"\fPauseRequest\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\"\x0f\n" +
+	"\rPauseResponse\"\x0f\n" +
+	"\rResumeRequest\"\x10\n" +
+	"\x0eResumeResponse\"i\n" +
+	"\x12ReconfigureRequest\x12\x16\n" +
+	"\x06config\x18\x01 \x01(\fR\x06config\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\"/\n" +
+	"\x13ReconfigureResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion*|\n" +
 	"\vHealthState\x12\x1c\n" +
 	"\x18HEALTH_STATE_UNSPECIFIED\x10\x00\x12\x18\n" +
 	"\x14HEALTH_STATE_HEALTHY\x10\x01\x12\x19\n" +
 	"\x15HEALTH_STATE_DEGRADED\x10\x02\x12\x1a\n" +
-	"\x16HEALTH_STATE_UNHEALTHY\x10\x032p\n" +
+	"\x16HEALTH_STATE_UNHEALTHY\x10\x032\xd8\x01\n" +
 	"\x0fPluginLifecycle\x12]\n" +
-	"\fReportHealth\x12%.connectplugin.v1.ReportHealthRequest\x1a&.connectplugin.v1.ReportHealthResponse2\xb8\x01\n" +
+	"\fReportHealth\x12%.connectplugin.v1.ReportHealthRequest\x1a&.connectplugin.v1.ReportHealthResponse\x12f\n" +
+	"\x11WatchPluginHealth\x12*.connectplugin.v1.WatchPluginHealthRequest\x1a#.connectplugin.v1.PluginHealthEvent0\x012\xf5\x03\n" +
 	"\rPluginControl\x12T\n" +
 	"\tGetHealth\x12\".connectplugin.v1.GetHealthRequest\x1a#.connectplugin.v1.GetHealthResponse\x12Q\n" +
-	"\bShutdown\x12!.connectplugin.v1.ShutdownRequest\x1a\".connectplugin.v1.ShutdownResponseBFZDgithub.com/masegraye/connect-plugin-go/gen/plugin/v1;connectpluginv1b\x06proto3"
+	"\bShutdown\x12!.connectplugin.v1.ShutdownRequest\x1a\".connectplugin.v1.ShutdownResponse\x12H\n" +
+	"\x05Drain\x12\x1e.connectplugin.v1.DrainRequest\x1a\x1f.connectplugin.v1.DrainResponse\x12H\n" +
+	"\x05Pause\x12\x1e.connectplugin.v1.PauseRequest\x1a\x1f.connectplugin.v1.PauseResponse\x12K\n" +
+	"\x06Resume\x12\x1f.connectplugin.v1.ResumeRequest\x1a .connectplugin.v1.ResumeResponse\x12Z\n" +
+	"\vReconfigure\x12$.connectplugin.v1.ReconfigureRequest\x1a%.connectplugin.v1.ReconfigureResponseBFZDgithub.com/masegraye/connect-plugin-go/gen/plugin/v1;connectpluginv1b\x06proto3"
 
 var (
 	file_plugin_v1_lifecycle_proto_rawDescOnce sync.Once
@@ -421,30 +991,55 @@ func file_plugin_v1_lifecycle_proto_rawDescGZIP() []byte {
 }
 
 var file_plugin_v1_lifecycle_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_plugin_v1_lifecycle_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_plugin_v1_lifecycle_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_plugin_v1_lifecycle_proto_goTypes = []any{
-	(HealthState)(0),             // 0: connectplugin.v1.HealthState
-	(*ReportHealthRequest)(nil),  // 1: connectplugin.v1.ReportHealthRequest
-	(*ReportHealthResponse)(nil), // 2: connectplugin.v1.ReportHealthResponse
-	(*GetHealthRequest)(nil),     // 3: connectplugin.v1.GetHealthRequest
-	(*GetHealthResponse)(nil),    // 4: connectplugin.v1.GetHealthResponse
-	(*ShutdownRequest)(nil),      // 5: connectplugin.v1.ShutdownRequest
-	(*ShutdownResponse)(nil),     // 6: connectplugin.v1.ShutdownResponse
+	(HealthState)(0),                 // 0: connectplugin.v1.HealthState
+	(*ReportHealthRequest)(nil),      // 1: connectplugin.v1.ReportHealthRequest
+	(*ReportHealthResponse)(nil),     // 2: connectplugin.v1.ReportHealthResponse
+	(*WatchPluginHealthRequest)(nil), // 3: connectplugin.v1.WatchPluginHealthRequest
+	(*PluginHealthEvent)(nil),        // 4: connectplugin.v1.PluginHealthEvent
+	(*GetHealthRequest)(nil),         // 5: connectplugin.v1.GetHealthRequest
+	(*GetHealthResponse)(nil),        // 6: connectplugin.v1.GetHealthResponse
+	(*ShutdownRequest)(nil),          // 7: connectplugin.v1.ShutdownRequest
+	(*ShutdownResponse)(nil),         // 8: connectplugin.v1.ShutdownResponse
+	(*DrainRequest)(nil),             // 9: connectplugin.v1.DrainRequest
+	(*DrainResponse)(nil),            // 10: connectplugin.v1.DrainResponse
+	(*PauseRequest)(nil),             // 11: connectplugin.v1.PauseRequest
+	(*PauseResponse)(nil),            // 12: connectplugin.v1.PauseResponse
+	(*ResumeRequest)(nil),            // 13: connectplugin.v1.ResumeRequest
+	(*ResumeResponse)(nil),           // 14: connectplugin.v1.ResumeResponse
+	(*ReconfigureRequest)(nil),       // 15: connectplugin.v1.ReconfigureRequest
+	(*ReconfigureResponse)(nil),      // 16: connectplugin.v1.ReconfigureResponse
+	(*timestamppb.Timestamp)(nil),    // 17: google.protobuf.Timestamp
 }
 var file_plugin_v1_lifecycle_proto_depIdxs = []int32{
-	0, // 0: connectplugin.v1.ReportHealthRequest.state:type_name -> connectplugin.v1.HealthState
-	0, // 1: connectplugin.v1.GetHealthResponse.state:type_name -> connectplugin.v1.HealthState
-	1, // 2: connectplugin.v1.PluginLifecycle.ReportHealth:input_type -> connectplugin.v1.ReportHealthRequest
-	3, // 3: connectplugin.v1.PluginControl.GetHealth:input_type -> connectplugin.v1.GetHealthRequest
-	5, // 4: connectplugin.v1.PluginControl.Shutdown:input_type -> connectplugin.v1.ShutdownRequest
-	2, // 5: connectplugin.v1.PluginLifecycle.ReportHealth:output_type -> connectplugin.v1.ReportHealthResponse
-	4, // 6: connectplugin.v1.PluginControl.GetHealth:output_type -> connectplugin.v1.GetHealthResponse
-	6, // 7: connectplugin.v1.PluginControl.Shutdown:output_type -> connectplugin.v1.ShutdownResponse
-	5, // [5:8] is the sub-list for method output_type
-	2, // [2:5] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: connectplugin.v1.ReportHealthRequest.state:type_name -> connectplugin.v1.HealthState
+	0,  // 1: connectplugin.v1.PluginHealthEvent.state:type_name -> connectplugin.v1.HealthState
+	0,  // 2: connectplugin.v1.PluginHealthEvent.previous_state:type_name -> connectplugin.v1.HealthState
+	17, // 3: connectplugin.v1.PluginHealthEvent.changed_at:type_name -> google.protobuf.Timestamp
+	17, // 4: connectplugin.v1.PluginHealthEvent.reported_at:type_name -> google.protobuf.Timestamp
+	0,  // 5: connectplugin.v1.GetHealthResponse.state:type_name -> connectplugin.v1.HealthState
+	1,  // 6: connectplugin.v1.PluginLifecycle.ReportHealth:input_type -> connectplugin.v1.ReportHealthRequest
+	3,  // 7: connectplugin.v1.PluginLifecycle.WatchPluginHealth:input_type -> connectplugin.v1.WatchPluginHealthRequest
+	5,  // 8: connectplugin.v1.PluginControl.GetHealth:input_type -> connectplugin.v1.GetHealthRequest
+	7,  // 9: connectplugin.v1.PluginControl.Shutdown:input_type -> connectplugin.v1.ShutdownRequest
+	9,  // 10: connectplugin.v1.PluginControl.Drain:input_type -> connectplugin.v1.DrainRequest
+	11, // 11: connectplugin.v1.PluginControl.Pause:input_type -> connectplugin.v1.PauseRequest
+	13, // 12: connectplugin.v1.PluginControl.Resume:input_type -> connectplugin.v1.ResumeRequest
+	15, // 13: connectplugin.v1.PluginControl.Reconfigure:input_type -> connectplugin.v1.ReconfigureRequest
+	2,  // 14: connectplugin.v1.PluginLifecycle.ReportHealth:output_type -> connectplugin.v1.ReportHealthResponse
+	4,  // 15: connectplugin.v1.PluginLifecycle.WatchPluginHealth:output_type -> connectplugin.v1.PluginHealthEvent
+	6,  // 16: connectplugin.v1.PluginControl.GetHealth:output_type -> connectplugin.v1.GetHealthResponse
+	8,  // 17: connectplugin.v1.PluginControl.Shutdown:output_type -> connectplugin.v1.ShutdownResponse
+	10, // 18: connectplugin.v1.PluginControl.Drain:output_type -> connectplugin.v1.DrainResponse
+	12, // 19: connectplugin.v1.PluginControl.Pause:output_type -> connectplugin.v1.PauseResponse
+	14, // 20: connectplugin.v1.PluginControl.Resume:output_type -> connectplugin.v1.ResumeResponse
+	16, // 21: connectplugin.v1.PluginControl.Reconfigure:output_type -> connectplugin.v1.ReconfigureResponse
+	14, // [14:22] is the sub-list for method output_type
+	6,  // [6:14] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_plugin_v1_lifecycle_proto_init() }
@@ -458,7 +1053,7 @@ func file_plugin_v1_lifecycle_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_plugin_v1_lifecycle_proto_rawDesc), len(file_plugin_v1_lifecycle_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   6,
+			NumMessages:   16,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
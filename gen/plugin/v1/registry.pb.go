@@ -395,7 +395,9 @@ type ServiceEndpoint struct {
 	// Plugin calls: hostURL + endpoint_url + "/MethodName"
 	EndpointUrl string `protobuf:"bytes,3,opt,name=endpoint_url,json=endpointUrl,proto3" json:"endpoint_url,omitempty"`
 	// Service metadata.
-	Metadata      map[string]string `protobuf:"bytes,4,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Metadata map[string]string `protobuf:"bytes,4,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Provider state (set in WatchServiceEvent.endpoints).
+	State         ServiceState `protobuf:"varint,5,opt,name=state,proto3,enum=connectplugin.v1.ServiceState" json:"state,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -458,12 +460,23 @@ func (x *ServiceEndpoint) GetMetadata() map[string]string {
 	return nil
 }
 
+func (x *ServiceEndpoint) GetState() ServiceState {
+	if x != nil {
+		return x.State
+	}
+	return ServiceState_SERVICE_STATE_UNSPECIFIED
+}
+
 type WatchServiceRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Service type to watch (e.g., "logger").
-	ServiceType   string `protobuf:"bytes,1,opt,name=service_type,json=serviceType,proto3" json:"service_type,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	ServiceType string `protobuf:"bytes,1,opt,name=service_type,json=serviceType,proto3" json:"service_type,omitempty"`
+	// Revision of the last event the watcher received (0 = none).
+	// The current state is always sent first; if it is still at this revision,
+	// the event is marked unchanged.
+	ResumeFromRevision uint64 `protobuf:"varint,2,opt,name=resume_from_revision,json=resumeFromRevision,proto3" json:"resume_from_revision,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *WatchServiceRequest) Reset() {
@@ -503,6 +516,13 @@ func (x *WatchServiceRequest) GetServiceType() string {
 	return ""
 }
 
+func (x *WatchServiceRequest) GetResumeFromRevision() uint64 {
+	if x != nil {
+		return x.ResumeFromRevision
+	}
+	return 0
+}
+
 type WatchServiceEvent struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Service type.
@@ -510,7 +530,15 @@ type WatchServiceEvent struct {
 	// Current state of the service.
 	State ServiceState `protobuf:"varint,2,opt,name=state,proto3,enum=connectplugin.v1.ServiceState" json:"state,omitempty"`
 	// If state is AVAILABLE or DEGRADED, this is the endpoint to use.
-	Endpoint      *ServiceEndpoint `protobuf:"bytes,3,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Endpoint *ServiceEndpoint `protobuf:"bytes,3,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	// All registered providers of the service, each with its own state.
+	Endpoints []*ServiceEndpoint `protobuf:"bytes,4,rep,name=endpoints,proto3" json:"endpoints,omitempty"`
+	// Monotonically increasing revision of the service state.
+	// Never reused, including across host restarts.
+	Revision uint64 `protobuf:"varint,5,opt,name=revision,proto3" json:"revision,omitempty"`
+	// True if this is the initial event of a resumed watch and nothing changed
+	// since resume_from_revision.
+	Unchanged     bool `protobuf:"varint,6,opt,name=unchanged,proto3" json:"unchanged,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -566,6 +594,27 @@ func (x *WatchServiceEvent) GetEndpoint() *ServiceEndpoint {
 	return nil
 }
 
+func (x *WatchServiceEvent) GetEndpoints() []*ServiceEndpoint {
+	if x != nil {
+		return x.Endpoints
+	}
+	return nil
+}
+
+func (x *WatchServiceEvent) GetRevision() uint64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *WatchServiceEvent) GetUnchanged() bool {
+	if x != nil {
+		return x.Unchanged
+	}
+	return false
+}
+
 var File_plugin_v1_registry_proto protoreflect.FileDescriptor
 
 const file_plugin_v1_registry_proto_rawDesc = "" +
@@ -590,22 +639,27 @@ const file_plugin_v1_registry_proto_rawDesc = "" +
 	"minVersion\"\x81\x01\n" +
 	"\x17DiscoverServiceResponse\x12=\n" +
 	"\bendpoint\x18\x01 \x01(\v2!.connectplugin.v1.ServiceEndpointR\bendpoint\x12'\n" +
-	"\x0fsingle_provider\x18\x02 \x01(\bR\x0esingleProvider\"\xf9\x01\n" +
+	"\x0fsingle_provider\x18\x02 \x01(\bR\x0esingleProvider\"\xaf\x02\n" +
 	"\x0fServiceEndpoint\x12\x1f\n" +
 	"\vprovider_id\x18\x01 \x01(\tR\n" +
 	"providerId\x12\x18\n" +
 	"\aversion\x18\x02 \x01(\tR\aversion\x12!\n" +
 	"\fendpoint_url\x18\x03 \x01(\tR\vendpointUrl\x12K\n" +
-	"\bmetadata\x18\x04 \x03(\v2/.connectplugin.v1.ServiceEndpoint.MetadataEntryR\bmetadata\x1a;\n" +
+	"\bmetadata\x18\x04 \x03(\v2/.connectplugin.v1.ServiceEndpoint.MetadataEntryR\bmetadata\x124\n" +
+	"\x05state\x18\x05 \x01(\x0e2\x1e.connectplugin.v1.ServiceStateR\x05state\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"8\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"j\n" +
 	"\x13WatchServiceRequest\x12!\n" +
-	"\fservice_type\x18\x01 \x01(\tR\vserviceType\"\xab\x01\n" +
+	"\fservice_type\x18\x01 \x01(\tR\vserviceType\x120\n" +
+	"\x14resume_from_revision\x18\x02 \x01(\x04R\x12resumeFromRevision\"\xa6\x02\n" +
 	"\x11WatchServiceEvent\x12!\n" +
 	"\fservice_type\x18\x01 \x01(\tR\vserviceType\x124\n" +
 	"\x05state\x18\x02 \x01(\x0e2\x1e.connectplugin.v1.ServiceStateR\x05state\x12=\n" +
-	"\bendpoint\x18\x03 \x01(\v2!.connectplugin.v1.ServiceEndpointR\bendpoint*\x85\x01\n" +
+	"\bendpoint\x18\x03 \x01(\v2!.connectplugin.v1.ServiceEndpointR\bendpoint\x12?\n" +
+	"\tendpoints\x18\x04 \x03(\v2!.connectplugin.v1.ServiceEndpointR\tendpoints\x12\x1a\n" +
+	"\brevision\x18\x05 \x01(\x04R\brevision\x12\x1c\n" +
+	"\tunchanged\x18\x06 \x01(\bR\tunchanged*\x85\x01\n" +
 	"\fServiceState\x12\x1d\n" +
 	"\x19SERVICE_STATE_UNSPECIFIED\x10\x00\x12\x1b\n" +
 	"\x17SERVICE_STATE_AVAILABLE\x10\x01\x12\x1d\n" +
@@ -649,21 +703,23 @@ var file_plugin_v1_registry_proto_depIdxs = []int32{
 	10, // 0: connectplugin.v1.RegisterServiceRequest.metadata:type_name -> connectplugin.v1.RegisterServiceRequest.MetadataEntry
 	7,  // 1: connectplugin.v1.DiscoverServiceResponse.endpoint:type_name -> connectplugin.v1.ServiceEndpoint
 	11, // 2: connectplugin.v1.ServiceEndpoint.metadata:type_name -> connectplugin.v1.ServiceEndpoint.MetadataEntry
-	0,  // 3: connectplugin.v1.WatchServiceEvent.state:type_name -> connectplugin.v1.ServiceState
-	7,  // 4: connectplugin.v1.WatchServiceEvent.endpoint:type_name -> connectplugin.v1.ServiceEndpoint
-	1,  // 5: connectplugin.v1.ServiceRegistry.RegisterService:input_type -> connectplugin.v1.RegisterServiceRequest
-	3,  // 6: connectplugin.v1.ServiceRegistry.UnregisterService:input_type -> connectplugin.v1.UnregisterServiceRequest
-	5,  // 7: connectplugin.v1.ServiceRegistry.DiscoverService:input_type -> connectplugin.v1.DiscoverServiceRequest
-	8,  // 8: connectplugin.v1.ServiceRegistry.WatchService:input_type -> connectplugin.v1.WatchServiceRequest
-	2,  // 9: connectplugin.v1.ServiceRegistry.RegisterService:output_type -> connectplugin.v1.RegisterServiceResponse
-	4,  // 10: connectplugin.v1.ServiceRegistry.UnregisterService:output_type -> connectplugin.v1.UnregisterServiceResponse
-	6,  // 11: connectplugin.v1.ServiceRegistry.DiscoverService:output_type -> connectplugin.v1.DiscoverServiceResponse
-	9,  // 12: connectplugin.v1.ServiceRegistry.WatchService:output_type -> connectplugin.v1.WatchServiceEvent
-	9,  // [9:13] is the sub-list for method output_type
-	5,  // [5:9] is the sub-list for method input_type
-	5,  // [5:5] is the sub-list for extension type_name
-	5,  // [5:5] is the sub-list for extension extendee
-	0,  // [0:5] is the sub-list for field type_name
+	0,  // 3: connectplugin.v1.ServiceEndpoint.state:type_name -> connectplugin.v1.ServiceState
+	0,  // 4: connectplugin.v1.WatchServiceEvent.state:type_name -> connectplugin.v1.ServiceState
+	7,  // 5: connectplugin.v1.WatchServiceEvent.endpoint:type_name -> connectplugin.v1.ServiceEndpoint
+	7,  // 6: connectplugin.v1.WatchServiceEvent.endpoints:type_name -> connectplugin.v1.ServiceEndpoint
+	1,  // 7: connectplugin.v1.ServiceRegistry.RegisterService:input_type -> connectplugin.v1.RegisterServiceRequest
+	3,  // 8: connectplugin.v1.ServiceRegistry.UnregisterService:input_type -> connectplugin.v1.UnregisterServiceRequest
+	5,  // 9: connectplugin.v1.ServiceRegistry.DiscoverService:input_type -> connectplugin.v1.DiscoverServiceRequest
+	8,  // 10: connectplugin.v1.ServiceRegistry.WatchService:input_type -> connectplugin.v1.WatchServiceRequest
+	2,  // 11: connectplugin.v1.ServiceRegistry.RegisterService:output_type -> connectplugin.v1.RegisterServiceResponse
+	4,  // 12: connectplugin.v1.ServiceRegistry.UnregisterService:output_type -> connectplugin.v1.UnregisterServiceResponse
+	6,  // 13: connectplugin.v1.ServiceRegistry.DiscoverService:output_type -> connectplugin.v1.DiscoverServiceResponse
+	9,  // 14: connectplugin.v1.ServiceRegistry.WatchService:output_type -> connectplugin.v1.WatchServiceEvent
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_plugin_v1_registry_proto_init() }
@@ -6,6 +6,7 @@ import (
 	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"sync"
@@ -30,6 +31,7 @@ const (
 // tokenInfo stores token metadata including expiration.
 type tokenInfo struct {
 	token     string
+	selfID    string
 	issuedAt  time.Time
 	expiresAt time.Time
 }
@@ -41,6 +43,9 @@ type HandshakeServer struct {
 	// Phase 2: Token storage for runtime identity
 	mu     sync.RWMutex
 	tokens map[string]*tokenInfo // runtime_id → token info
+
+	// store persists issued tokens (nil = in-memory only)
+	store StateStore
 }
 
 // NewHandshakeServer creates a new handshake server for the given configuration.
@@ -112,19 +117,9 @@ func (h *HandshakeServer) Handshake(
 		}
 
 		// Store token for later validation with expiration
-		ttl := DefaultRuntimeTokenTTL
-		if h.cfg.RuntimeTokenTTL > 0 {
-			ttl = h.cfg.RuntimeTokenTTL
-		}
-
-		now := time.Now()
-		h.mu.Lock()
-		h.tokens[runtimeID] = &tokenInfo{
-			token:     runtimeToken,
-			issuedAt:  now,
-			expiresAt: now.Add(ttl),
+		if err := h.issueToken(runtimeID, req.Msg.SelfId, runtimeToken); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
 		}
-		h.mu.Unlock()
 	}
 
 	// Build plugin info for requested plugins
@@ -226,6 +221,7 @@ func (h *HandshakeServer) ValidateToken(runtimeID, token string) bool {
 	if time.Now().After(info.expiresAt) {
 		// Token expired - remove it and return false
 		delete(h.tokens, runtimeID)
+		h.persistRevokeLocked(runtimeID)
 		return false
 	}
 
@@ -236,6 +232,115 @@ func (h *HandshakeServer) ValidateToken(runtimeID, token string) bool {
 	return subtle.ConstantTimeCompare([]byte(info.token), []byte(token)) == 1
 }
 
+// selfID returns the declared self ID of a runtime identity, or "" if unknown.
+func (h *HandshakeServer) selfID(runtimeID string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if info, ok := h.tokens[runtimeID]; ok {
+		return info.selfID
+	}
+	return ""
+}
+
+// issueToken stores a runtime token for later validation, expiring after
+// the configured RuntimeTokenTTL. selfID is the plugin's declared ID.
+func (h *HandshakeServer) issueToken(runtimeID, selfID, token string) error {
+	ttl := DefaultRuntimeTokenTTL
+	if h.cfg.RuntimeTokenTTL > 0 {
+		ttl = h.cfg.RuntimeTokenTTL
+	}
+
+	now := time.Now()
+	info := &tokenInfo{
+		token:     token,
+		selfID:    selfID,
+		issuedAt:  now,
+		expiresAt: now.Add(ttl),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.persistTokenLocked(runtimeID, info); err != nil {
+		return err
+	}
+	h.tokens[runtimeID] = info
+	return nil
+}
+
+// revokeToken removes a runtime token so it can no longer authenticate.
+func (h *HandshakeServer) revokeToken(runtimeID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.tokens[runtimeID]; !ok {
+		return
+	}
+	delete(h.tokens, runtimeID)
+	h.persistRevokeLocked(runtimeID)
+}
+
+// SetStateStore attaches a store that issued and expired tokens are persisted to.
+// Use RestoreState to also load previously persisted tokens.
+func (h *HandshakeServer) SetStateStore(store StateStore) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.store = store
+}
+
+// restore loads persisted tokens, skipping any that have expired.
+func (h *HandshakeServer) restore(tokens map[string]*PersistedToken) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for runtimeID, t := range tokens {
+		if now.After(t.ExpiresAt) {
+			continue
+		}
+		h.tokens[runtimeID] = &tokenInfo{
+			token:     t.Token,
+			selfID:    t.SelfID,
+			issuedAt:  t.IssuedAt,
+			expiresAt: t.ExpiresAt,
+		}
+	}
+}
+
+// persistTokenLocked records an issued token.
+// Caller must hold lock.
+func (h *HandshakeServer) persistTokenLocked(runtimeID string, info *tokenInfo) error {
+	if h.store == nil {
+		return nil
+	}
+	err := h.store.Append(StateRecord{
+		Kind:      RecordIssueToken,
+		RuntimeID: runtimeID,
+		Token: &PersistedToken{
+			Token:     info.token,
+			SelfID:    info.selfID,
+			IssuedAt:  info.issuedAt,
+			ExpiresAt: info.expiresAt,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to persist runtime token: %w", err)
+	}
+	return nil
+}
+
+// persistRevokeLocked records removal of a token.
+// Failures are logged: the token is already unusable in memory and is
+// skipped on restore once expired.
+// Caller must hold lock.
+func (h *HandshakeServer) persistRevokeLocked(runtimeID string) {
+	if h.store == nil {
+		return
+	}
+	if err := h.store.Append(StateRecord{Kind: RecordRevokeToken, RuntimeID: runtimeID}); err != nil {
+		log.Printf("[PERSIST] Failed to record token revocation for %s: %v", runtimeID, err)
+	}
+}
+
 // generateRuntimeID generates a unique runtime ID from the plugin's self-declared ID.
 // Format: {self_id}-{random_suffix}
 // Example: "cache-plugin" → "cache-plugin-x7k2"
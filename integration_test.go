@@ -373,6 +373,7 @@ func TestIntegration_ModelA_AddPlugin(t *testing.T) {
 		SelfID:      "logger-plugin",
 		SelfVersion: "1.0.0",
 		Endpoint:    "http://localhost:19081",
+		HostSecret:  integrationHostSecret,
 		Metadata: connectplugin.PluginMetadata{
 			Name:    "logger",
 			Version: "1.0.0",
@@ -428,6 +429,7 @@ func TestIntegration_ModelA_DependencyValidation(t *testing.T) {
 		SelfID:      "cache-plugin",
 		SelfVersion: "1.0.0",
 		Endpoint:    "http://localhost:20082",
+		HostSecret:  integrationHostSecret,
 		Metadata: connectplugin.PluginMetadata{
 			Name:    "cache",
 			Version: "1.0.0",
@@ -459,6 +461,7 @@ func TestIntegration_ModelA_DependencyValidation(t *testing.T) {
 		SelfID:      "logger-plugin",
 		SelfVersion: "1.0.0",
 		Endpoint:    "http://localhost:20081",
+		HostSecret:  integrationHostSecret,
 		Metadata: connectplugin.PluginMetadata{
 			Name:    "logger",
 			Version: "1.0.0",
@@ -515,6 +518,7 @@ func TestIntegration_ModelA_ReplacePlugin(t *testing.T) {
 		SelfID:      "logger-plugin",
 		SelfVersion: "1.0.0",
 		Endpoint:    "http://localhost:21081",
+		HostSecret:  integrationHostSecret,
 		Metadata: connectplugin.PluginMetadata{
 			Name:    "logger",
 			Version: "1.0.0",
@@ -547,6 +551,7 @@ func TestIntegration_ModelA_ReplacePlugin(t *testing.T) {
 		SelfID:      "logger-plugin",
 		SelfVersion: "2.0.0",
 		Endpoint:    "http://localhost:21091",
+		HostSecret:  integrationHostSecret,
 		Metadata: connectplugin.PluginMetadata{
 			Name:    "logger",
 			Version: "2.0.0",
@@ -592,16 +597,19 @@ func startHostServer(t *testing.T, port int) *hostServer {
 	router := connectplugin.NewServiceRouter(handshake, registry, lifecycle)
 	platform := connectplugin.NewPlatform(registry, lifecycle, router)
 
+	// Phase 2 host RPCs require the runtime token issued at handshake
+	runtimeAuth := connect.WithInterceptors(connectplugin.NewRuntimeAuthInterceptor(handshake))
+
 	mux := http.NewServeMux()
 
 	// Register Phase 2 services
 	handshakePath, handshakeHandler := connectpluginv1connect.NewHandshakeServiceHandler(handshake)
 	mux.Handle(handshakePath, handshakeHandler)
 
-	lifecyclePath, lifecycleHandler := connectpluginv1connect.NewPluginLifecycleHandler(lifecycle)
+	lifecyclePath, lifecycleHandler := connectpluginv1connect.NewPluginLifecycleHandler(lifecycle, runtimeAuth)
 	mux.Handle(lifecyclePath, lifecycleHandler)
 
-	registryPath, registryHandler := connectpluginv1connect.NewServiceRegistryHandler(registry)
+	registryPath, registryHandler := connectpluginv1connect.NewServiceRegistryHandler(registry, runtimeAuth)
 	mux.Handle(registryPath, registryHandler)
 
 	mux.Handle("/services/", router)
@@ -632,6 +640,9 @@ func startHostServer(t *testing.T, port int) *hostServer {
 
 // buildAndStartPlugin starts a plugin binary from dist/.
 // Assumes plugin was already built via `task build-examples`.
+// integrationHostSecret is provisioned to every plugin the tests start.
+const integrationHostSecret = "integration-host-secret"
+
 func buildAndStartPlugin(t *testing.T, name string, port, hostPort int) *exec.Cmd {
 	// Use pre-built binary from dist/
 	binaryPath := filepath.Join("dist", name)
@@ -641,6 +652,7 @@ func buildAndStartPlugin(t *testing.T, name string, port, hostPort int) *exec.Cm
 	cmd.Env = append(os.Environ(),
 		fmt.Sprintf("PORT=%d", port),
 		fmt.Sprintf("HOST_URL=http://localhost:%d", hostPort),
+		connectplugin.HostSecretEnv+"="+integrationHostSecret,
 	)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
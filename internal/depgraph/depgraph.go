@@ -4,10 +4,13 @@ package depgraph
 import (
 	"fmt"
 	"sort"
+	"sync"
 )
 
 // Graph represents a dependency graph of plugins and their service dependencies.
+// It is safe for concurrent use.
 type Graph struct {
+	mu     sync.RWMutex
 	nodes map[string]*Node           // runtime_id → node
 	edges map[string][]string        // runtime_id → service types required
 	byType map[string][]string       // service_type → provider runtime_ids
@@ -50,6 +53,9 @@ func New() *Graph {
 
 // Add adds a plugin node to the graph.
 func (g *Graph) Add(node *Node) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	g.nodes[node.RuntimeID] = node
 
 	// Build edges for required dependencies
@@ -69,6 +75,9 @@ func (g *Graph) Add(node *Node) {
 
 // Remove removes a plugin node from the graph.
 func (g *Graph) Remove(runtimeID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	node, ok := g.nodes[runtimeID]
 	if !ok {
 		return
@@ -99,6 +108,9 @@ func (g *Graph) Remove(runtimeID string) {
 // Plugins with no dependencies come first, then plugins that depend on them, etc.
 // Returns error if a dependency cycle is detected.
 func (g *Graph) StartupOrder() ([]string, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
 	// Kahn's algorithm for topological sort with cycle detection
 	inDegree := make(map[string]int)
 	adjList := make(map[string][]string) // service_type → plugins that depend on it
@@ -166,6 +178,9 @@ func (g *Graph) StartupOrder() ([]string, error) {
 
 // GetImpact analyzes what will be affected if a plugin is removed.
 func (g *Graph) GetImpact(runtimeID string) *ImpactAnalysis {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
 	node, ok := g.nodes[runtimeID]
 	if !ok {
 		return &ImpactAnalysis{
@@ -198,6 +213,7 @@ func (g *Graph) GetImpact(runtimeID string) *ImpactAnalysis {
 }
 
 // findDependents recursively finds all plugins that depend on this plugin's services.
+// Caller must hold lock.
 func (g *Graph) findDependents(runtimeID string, impact *ImpactAnalysis, visited map[string]bool) {
 	if visited[runtimeID] {
 		return
@@ -242,6 +258,7 @@ func (g *Graph) findDependents(runtimeID string, impact *ImpactAnalysis, visited
 }
 
 // getOtherProviders returns providers of a service type excluding the given runtime_id.
+// Caller must hold lock.
 func (g *Graph) getOtherProviders(serviceType, excludeRuntimeID string) []string {
 	result := make([]string, 0)
 	for _, providerID := range g.byType[serviceType] {
@@ -277,20 +294,53 @@ func appendUnique(slice []string, item string) []string {
 	return append(slice, item)
 }
 
-// GetNode returns a node by runtime ID.
+// GetNode returns a copy of a node by runtime ID, or nil if not found.
 func (g *Graph) GetNode(runtimeID string) *Node {
-	return g.nodes[runtimeID]
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	node, ok := g.nodes[runtimeID]
+	if !ok {
+		return nil
+	}
+	copied := *node
+	copied.Provides = append([]ServiceDeclaration(nil), node.Provides...)
+	copied.Requires = append([]ServiceDependency(nil), node.Requires...)
+	return &copied
 }
 
 // GetProviders returns all plugins that provide a given service type.
 func (g *Graph) GetProviders(serviceType string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
 	providers := g.byType[serviceType]
 	result := make([]string, len(providers))
 	copy(result, providers)
 	return result
 }
 
+// Dependents returns the plugins that require a given service type.
+func (g *Graph) Dependents(serviceType string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	result := make([]string, 0)
+	for runtimeID, node := range g.nodes {
+		for _, dep := range node.Requires {
+			if dep.Type == serviceType {
+				result = append(result, runtimeID)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
 // HasService returns true if at least one plugin provides the given service type.
 func (g *Graph) HasService(serviceType string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return len(g.byType[serviceType]) > 0
 }
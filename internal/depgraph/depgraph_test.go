@@ -1,8 +1,10 @@
 package depgraph
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
 )
 
@@ -527,6 +529,32 @@ func TestGraph_GetProviders(t *testing.T) {
 	}
 }
 
+func TestGraph_Dependents(t *testing.T) {
+	g := New()
+
+	g.Add(&Node{
+		RuntimeID: "logger-a",
+		Provides:  []ServiceDeclaration{{Type: "logger", Version: "1.0.0"}},
+	})
+	g.Add(&Node{
+		RuntimeID: "cache-b",
+		Requires:  []ServiceDependency{{Type: "logger", RequiredForStartup: true}},
+	})
+	g.Add(&Node{
+		RuntimeID: "app-c",
+		Requires:  []ServiceDependency{{Type: "logger"}, {Type: "cache"}},
+	})
+
+	// Optional dependents are included
+	expected := []string{"app-c", "cache-b"}
+	if dependents := g.Dependents("logger"); !reflect.DeepEqual(dependents, expected) {
+		t.Errorf("Expected dependents %v, got %v", expected, dependents)
+	}
+	if dependents := g.Dependents("metrics"); len(dependents) != 0 {
+		t.Errorf("Expected no dependents, got %v", dependents)
+	}
+}
+
 func TestGraph_HasService(t *testing.T) {
 	g := New()
 
@@ -637,3 +665,36 @@ func TestGraph_GetImpact_EmptyGraph(t *testing.T) {
 		t.Error("Expected no affected plugins for nonexistent target")
 	}
 }
+
+func TestGraph_ConcurrentAccess(t *testing.T) {
+	g := New()
+	g.Add(&Node{
+		RuntimeID: "logger-abc",
+		Provides:  []ServiceDeclaration{{Type: "logger", Version: "1.0.0"}},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runtimeID := fmt.Sprintf("cache-%d", i)
+			for j := 0; j < 100; j++ {
+				g.Add(&Node{
+					RuntimeID: runtimeID,
+					Provides:  []ServiceDeclaration{{Type: "cache", Version: "1.0.0"}},
+					Requires:  []ServiceDependency{{Type: "logger", RequiredForStartup: true}},
+				})
+				g.GetImpact("logger-abc")
+				g.StartupOrder()
+				g.HasService("cache")
+				g.Remove(runtimeID)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if g.HasService("cache") {
+		t.Error("Expected all cache providers removed")
+	}
+}
@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 
 	"connectrpc.com/connect"
 	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
-	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
 	"github.com/masegraye/connect-plugin-go/internal/memtransport"
 )
 
@@ -65,18 +65,38 @@ func (s *InMemoryStrategy) Launch(ctx context.Context, spec PluginSpec) (LaunchR
 
 	// 3. Build HTTP mux with plugin handler + control services
 	mux := http.NewServeMux()
-	mux.Handle(path, handler)
 
-	// Add PluginControl service (for health checks)
-	controlPath, controlH := connectpluginv1connect.NewPluginControlHandler(
-		&inMemoryControlHandler{},
-	)
-	mux.Handle(controlPath, controlH)
+	// Add PluginControl service (health checks, drain, pause/resume)
+	control := NewPluginControlServer(ControlConfig{
+		Health: func(ctx context.Context) *connectpluginv1.GetHealthResponse {
+			return &connectpluginv1.GetHealthResponse{
+				State:  connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+				Reason: "in-memory (in-process)",
+			}
+		},
+	})
+	// In-memory plugins are only reachable through the launch result's
+	// HTTPClient, so without a host secret control calls are not authenticated
+	var controlOpts []connect.HandlerOption
+	if spec.HostSecret != "" {
+		controlOpts = append(controlOpts, connect.WithInterceptors(NewHostAuthInterceptor(spec.HostSecret)))
+	}
+	mux.Handle(control.Handler(controlOpts...))
+	mux.Handle(path, control.Middleware(handler))
 
 	// 4. Create in-memory listener and start server
 	ln := memtransport.New()
 
-	server := &http.Server{Handler: mux}
+	var root http.Handler = mux
+	if spec.CallerVerificationKey != nil {
+		// In-memory plugins are registered under their name
+		audience := CallerAudience{
+			RuntimeID:    func() string { return spec.Name },
+			ServiceTypes: spec.Provides,
+		}
+		root = CallerContextMiddleware(spec.CallerVerificationKey, audience, mux)
+	}
+	server := &http.Server{Handler: root}
 	go func() {
 		if err := server.Serve(ln); err != http.ErrServerClosed {
 			log.Printf("[InMemory] Plugin %s server error: %v", spec.Name, err)
@@ -122,6 +142,11 @@ func (s *InMemoryStrategy) registerService(spec PluginSpec) {
 		if i < len(metadata.Provides) {
 			servicePath = metadata.Provides[i].Path
 		}
+		// Generated plugins declare the bare service name; the router expects
+		// the handler path form (/pkg.Service/)
+		if servicePath != "" {
+			servicePath = "/" + strings.Trim(servicePath, "/") + "/"
+		}
 
 		regID, err := generateRegistrationID()
 		if err != nil {
@@ -141,30 +166,9 @@ func (s *InMemoryStrategy) registerService(spec PluginSpec) {
 		s.registry.mu.Lock()
 		s.registry.providers[svcType] = append(s.registry.providers[svcType], provider)
 		s.registry.registrations[regID] = provider
+		s.registry.notifyWatchersLocked(svcType)
 		s.registry.mu.Unlock()
 
 		log.Printf("[InMemory] Registered service: %s v1.0.0 (in-memory)", svcType)
 	}
 }
-
-// inMemoryControlHandler is a minimal PluginControl handler for in-memory plugins.
-type inMemoryControlHandler struct{}
-
-func (h *inMemoryControlHandler) GetHealth(
-	ctx context.Context,
-	req *connect.Request[connectpluginv1.GetHealthRequest],
-) (*connect.Response[connectpluginv1.GetHealthResponse], error) {
-	return connect.NewResponse(&connectpluginv1.GetHealthResponse{
-		State:  connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
-		Reason: "in-memory (in-process)",
-	}), nil
-}
-
-func (h *inMemoryControlHandler) Shutdown(
-	ctx context.Context,
-	req *connect.Request[connectpluginv1.ShutdownRequest],
-) (*connect.Response[connectpluginv1.ShutdownResponse], error) {
-	return connect.NewResponse(&connectpluginv1.ShutdownResponse{
-		Acknowledged: true,
-	}), nil
-}
@@ -3,6 +3,8 @@ package connectplugin_test
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"connectrpc.com/connect"
@@ -400,3 +402,64 @@ func TestLaunchPluginLocked_UnknownStrategy(t *testing.T) {
 		t.Error("expected error for unknown strategy")
 	}
 }
+
+func TestInMemoryStrategy_RoutedThroughServiceRouter(t *testing.T) {
+	handshake := connectplugin.NewHandshakeServer(&connectplugin.ServeConfig{})
+	lifecycle := connectplugin.NewLifecycleServer()
+	registry := connectplugin.NewServiceRegistry(lifecycle)
+	router := connectplugin.NewServiceRouter(handshake, registry, lifecycle)
+
+	launcher := connectplugin.NewPluginLauncher(nil, registry)
+	launcher.SetRouter(router)
+	launcher.RegisterStrategy(connectplugin.NewInMemoryStrategy(registry))
+	launcher.Configure(map[string]connectplugin.PluginSpec{
+		"kv-routed": {
+			Name:        "kv-routed",
+			Provides:    []string{"kv"},
+			Strategy:    "in-memory",
+			Plugin:      &kvplugin.KVServicePlugin{},
+			ImplFactory: func() any { return kvimpl.NewStore() },
+		},
+	})
+	defer launcher.Shutdown()
+
+	if _, err := launcher.GetService("kv-routed", "kv"); err != nil {
+		t.Fatalf("GetService failed: %v", err)
+	}
+
+	// Caller obtains a runtime token through the handshake
+	hsResp, err := handshake.Handshake(context.Background(), connect.NewRequest(&connectpluginv1.HandshakeRequest{
+		CoreProtocolVersion: 1,
+		AppProtocolVersion:  1,
+		MagicCookieKey:      connectplugin.DefaultMagicCookieKey,
+		MagicCookieValue:    connectplugin.DefaultMagicCookieValue,
+		SelfId:              "caller",
+	}))
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+
+	call := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/services/kv/kv-routed/"+method, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(connectplugin.RuntimeIDHeader, hsResp.Msg.RuntimeId)
+		req.Header.Set("Authorization", "Bearer "+hsResp.Msg.RuntimeToken)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// No TCP endpoint exists; the router dispatches over the in-memory transport
+	if w := call("Put", `{"key": "routed", "value": "dmFsdWU="}`); w.Code != http.StatusOK {
+		t.Fatalf("Put through router: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := call("Get", `{"key": "routed"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Get through router: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"dmFsdWU="`) {
+		t.Errorf("Expected stored value in response, got %s", w.Body.String())
+	}
+}
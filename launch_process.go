@@ -2,11 +2,13 @@ package connectplugin
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"runtime"
 	"sync"
 	"time"
 )
@@ -47,10 +49,23 @@ func (s *ProcessStrategy) Launch(ctx context.Context, spec PluginSpec) (LaunchRe
 		fmt.Sprintf("PORT=%d", spec.Port),
 		fmt.Sprintf("HOST_URL=%s", hostURL),  // Unmanaged: plugin connects to host
 	)
+	if spec.CallerVerificationKey != nil {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", CallerKeyEnv,
+			base64.StdEncoding.EncodeToString(spec.CallerVerificationKey)))
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Start(); err != nil {
+	secretPipe, err := passHostSecret(cmd, spec.HostSecret)
+	if err != nil {
+		return LaunchResult{}, err
+	}
+
+	err = cmd.Start()
+	if secretPipe != nil {
+		secretPipe.Close() // The child has its own copy
+	}
+	if err != nil {
 		return LaunchResult{}, fmt.Errorf("failed to start process %s: %w", spec.BinaryPath, err)
 	}
 
@@ -85,6 +100,36 @@ func (s *ProcessStrategy) Launch(ctx context.Context, spec PluginSpec) (LaunchRe
 	return LaunchResult{Endpoint: endpoint, Cleanup: cleanup}, nil
 }
 
+// passHostSecret passes the host secret to the plugin on an inherited pipe
+// (fd 3, named in PLUGIN_HOST_SECRET_FD) so it stays out of the plugin's
+// environment. Windows cannot inherit extra files, so the secret is passed in
+// PLUGIN_HOST_SECRET there. Returns the read end of the pipe, which the
+// caller closes once the process has started.
+func passHostSecret(cmd *exec.Cmd, secret string) (*os.File, error) {
+	if secret == "" {
+		return nil, nil
+	}
+	if runtime.GOOS == "windows" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", HostSecretEnv, secret))
+		return nil, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host secret pipe: %w", err)
+	}
+	_, err = w.WriteString(secret)
+	w.Close()
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to write host secret: %w", err)
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", HostSecretFDEnv, 2+len(cmd.ExtraFiles)))
+	return r, nil
+}
+
 // waitForPluginReady polls until the plugin endpoint is ready or timeout.
 func waitForPluginReady(endpoint string, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -2,6 +2,7 @@ package connectplugin
 
 import (
 	"context"
+	"crypto/ed25519"
 
 	"connectrpc.com/connect"
 )
@@ -70,6 +71,16 @@ type PluginSpec struct {
 	// Returns the handler interface (e.g., &LoggerImpl{}, &CacheImpl{})
 	ImplFactory func() any
 
+	// CallerVerificationKey verifies caller contexts attached by the
+	// ServiceRouter. Set by PluginLauncher from its router; passed to process
+	// plugins in CALLER_CONTEXT_KEY and verified for in-memory plugins.
+	CallerVerificationKey ed25519.PublicKey
+
+	// HostSecret authenticates the host's SetRuntimeIdentity and control
+	// calls to the plugin. Generated by PluginLauncher if empty; passed to
+	// process plugins on an inherited file descriptor (PLUGIN_HOST_SECRET_FD).
+	HostSecret string
+
 	// === Metadata ===
 
 	// Metadata contains additional plugin metadata (version, description, etc.)
@@ -15,6 +15,7 @@ import (
 type PluginLauncher struct {
 	platform   *Platform
 	registry   *ServiceRegistry
+	router     *ServiceRouter
 	strategies map[string]LaunchStrategy
 	specs      map[string]PluginSpec
 	instances  map[string]*pluginInstance
@@ -26,19 +27,33 @@ type pluginInstance struct {
 	pluginName string
 	endpoint   string
 	httpClient connect.HTTPClient // Non-nil for direct dispatch (in-memory transport)
+	hostSecret string             // Presented on control calls to the plugin
 	cleanup    func()
 	provides   []string
 }
 
 // NewPluginLauncher creates a plugin launcher.
+// If platform is non-nil, in-memory plugins are registered with its router.
 func NewPluginLauncher(platform *Platform, registry *ServiceRegistry) *PluginLauncher {
-	return &PluginLauncher{
+	l := &PluginLauncher{
 		platform:   platform,
 		registry:   registry,
 		strategies: make(map[string]LaunchStrategy),
 		specs:      make(map[string]PluginSpec),
 		instances:  make(map[string]*pluginInstance),
 	}
+	if platform != nil {
+		l.router = platform.Router()
+	}
+	return l
+}
+
+// SetRouter sets the router that launched plugins are registered with, so
+// /services/ calls reach in-memory plugins without the network.
+func (l *PluginLauncher) SetRouter(router *ServiceRouter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.router = router
 }
 
 // RegisterStrategy registers a launch strategy.
@@ -158,6 +173,19 @@ func (l *PluginLauncher) launchPluginLocked(pluginName string, spec PluginSpec)
 			spec.Strategy, l.availableStrategies())
 	}
 
+	if l.router != nil && spec.CallerVerificationKey == nil {
+		spec.CallerVerificationKey = l.router.CallerVerificationKey()
+	}
+
+	// Provision a fresh host secret for each launch
+	if spec.HostSecret == "" {
+		secret, err := GenerateHostSecret()
+		if err != nil {
+			return err
+		}
+		spec.HostSecret = secret
+	}
+
 	// Launch plugin
 	ctx := context.Background()
 	result, err := strategy.Launch(ctx, spec)
@@ -169,11 +197,19 @@ func (l *PluginLauncher) launchPluginLocked(pluginName string, spec PluginSpec)
 	// Plugin connects to host, registers services, reports health
 	time.Sleep(500 * time.Millisecond)
 
+	// Route /services/ calls to in-memory plugins through their transport.
+	// In-memory plugins register their services under the spec name.
+	if l.router != nil && result.HTTPClient != nil {
+		l.router.RegisterPluginEndpoint(spec.Name, result.Endpoint)
+		l.router.RegisterPluginClient(spec.Name, result.HTTPClient)
+	}
+
 	// Store instance
 	l.instances[pluginName] = &pluginInstance{
 		pluginName: pluginName,
 		endpoint:   result.Endpoint,
 		httpClient: result.HTTPClient,
+		hostSecret: spec.HostSecret,
 		cleanup:    result.Cleanup,
 		provides:   spec.Provides,
 	}
@@ -200,6 +236,20 @@ func (l *PluginLauncher) GetDefaultService(pluginName string) (string, error) {
 	return l.GetService(pluginName, spec.Provides[0])
 }
 
+// ControlClient returns a PluginControl client for a launched plugin that
+// presents the plugin's host secret, so Shutdown, Drain and the other
+// host-only calls are accepted.
+func (l *PluginLauncher) ControlClient(pluginName string) (*PluginControlClient, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	instance, ok := l.instances[pluginName]
+	if !ok {
+		return nil, fmt.Errorf("plugin %q not running", pluginName)
+	}
+	return NewPluginControlClient(instance.endpoint, instance.httpClient, WithHostSecret(instance.hostSecret)), nil
+}
+
 // Shutdown stops all launched plugins.
 // Should be called in fx OnStop hook.
 func (l *PluginLauncher) Shutdown() {
@@ -207,6 +257,9 @@ func (l *PluginLauncher) Shutdown() {
 	defer l.mu.Unlock()
 
 	for name, instance := range l.instances {
+		if l.router != nil && instance.httpClient != nil {
+			l.router.UnregisterPluginEndpoint(l.specs[name].Name)
+		}
 		if instance.cleanup != nil {
 			instance.cleanup()
 		}
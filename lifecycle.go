@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"connectrpc.com/connect"
 	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
@@ -16,6 +17,47 @@ import (
 type LifecycleServer struct {
 	mu     sync.RWMutex
 	states map[string]*PluginHealthState // runtime_id → health state
+
+	// store persists health reports (nil = in-memory only)
+	store StateStore
+
+	// listeners are notified of health changes
+	listeners []func(HealthChange)
+
+	// history maps runtime_id to its recent health changes, oldest first
+	history       map[string][]HealthChange
+	historyConfig HealthHistoryConfig
+
+	// flapUntil maps runtime_id to when a flapping plugin returns to rotation
+	flapUntil map[string]time.Time
+
+	// watchers are active WatchPluginHealth streams
+	watchers map[*pluginHealthWatcher]struct{}
+
+	// heartbeats maps runtime_id to its heartbeat config (host config)
+	heartbeats       map[string]HeartbeatConfig
+	defaultHeartbeat HeartbeatConfig
+
+	// lastSeen maps runtime_id to its last report (or start of tracking)
+	lastSeen map[string]time.Time
+
+	// staleSince maps runtime_id to when it was found stale
+	staleSince map[string]time.Time
+
+	// degraded maps runtime_id to a DEGRADED state set by the host
+	degraded map[string]*PluginHealthState
+
+	// Active health probing (host config)
+	probes       map[string]*pluginProbe
+	probeTargets map[string]probeTarget
+	probeConfigs map[string]ProbeConfig
+	defaultProbe ProbeConfig
+
+	// Heartbeat monitor control
+	wake           chan struct{}
+	stopCh         chan struct{}
+	monitorRunning bool
+	stopped        bool
 }
 
 // PluginHealthState tracks a plugin's health state and metadata.
@@ -23,12 +65,28 @@ type PluginHealthState struct {
 	State                   connectpluginv1.HealthState
 	Reason                  string
 	UnavailableDependencies []string
+
+	// ReportedAt is when the plugin last reported health.
+	ReportedAt time.Time
 }
 
 // NewLifecycleServer creates a new lifecycle server.
 func NewLifecycleServer() *LifecycleServer {
 	return &LifecycleServer{
-		states: make(map[string]*PluginHealthState),
+		states:        make(map[string]*PluginHealthState),
+		heartbeats:    make(map[string]HeartbeatConfig),
+		lastSeen:      make(map[string]time.Time),
+		staleSince:    make(map[string]time.Time),
+		probes:        make(map[string]*pluginProbe),
+		probeTargets:  make(map[string]probeTarget),
+		probeConfigs:  make(map[string]ProbeConfig),
+		degraded:      make(map[string]*PluginHealthState),
+		history:       make(map[string][]HealthChange),
+		historyConfig: HealthHistoryConfig{}.withDefaults(),
+		flapUntil:     make(map[string]time.Time),
+		watchers:      make(map[*pluginHealthWatcher]struct{}),
+		wake:          make(chan struct{}, 1),
+		stopCh:        make(chan struct{}),
 	}
 }
 
@@ -37,26 +95,54 @@ func (l *LifecycleServer) ReportHealth(
 	ctx context.Context,
 	req *connect.Request[connectpluginv1.ReportHealthRequest],
 ) (*connect.Response[connectpluginv1.ReportHealthResponse], error) {
-	// Extract runtime_id from request headers
-	// For now, we'll need to pass this through context or headers
-	// TODO: Extract from X-Plugin-Runtime-ID header when router is implemented
-	runtimeID := req.Header().Get("X-Plugin-Runtime-ID")
-	if runtimeID == "" {
-		return nil, connect.NewError(
-			connect.CodeInvalidArgument,
-			fmt.Errorf("X-Plugin-Runtime-ID header required"),
-		)
+	// Use the runtime ID verified by RuntimeAuthInterceptor
+	runtimeID, err := authenticatedRuntimeID(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	now := time.Now()
 	l.mu.Lock()
-	defer l.mu.Unlock()
 
-	// Store or update health state
+	if l.store != nil {
+		err := l.store.Append(StateRecord{
+			Kind:      RecordReportHealth,
+			RuntimeID: runtimeID,
+			Health: &PersistedHealth{
+				State:                   req.Msg.State,
+				Reason:                  req.Msg.Reason,
+				UnavailableDependencies: req.Msg.UnavailableDependencies,
+				ReportedAt:              now,
+			},
+		})
+		if err != nil {
+			l.mu.Unlock()
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to persist health: %w", err))
+		}
+	}
+
+	// The previous state as routing saw it (UNHEALTHY if stale)
+	previous := l.healthStateLocked(runtimeID, now)
+
+	// Store or update health state; a report also counts as a heartbeat
 	l.states[runtimeID] = &PluginHealthState{
 		State:                   req.Msg.State,
 		Reason:                  req.Msg.Reason,
 		UnavailableDependencies: req.Msg.UnavailableDependencies,
+		ReportedAt:              now,
 	}
+	l.lastSeen[runtimeID] = now
+	delete(l.staleSince, runtimeID)
+	l.wakeMonitorLocked()
+
+	// A failing probe may keep the plugin UNHEALTHY despite the report
+	var changes []HealthChange
+	if change, changed := l.healthChangeLocked(runtimeID, previous, now); changed {
+		changes = l.publishLocked(change)
+	}
+	l.mu.Unlock()
+
+	l.notifyListeners(changes...)
 
 	return connect.NewResponse(&connectpluginv1.ReportHealthResponse{}), nil
 }
@@ -66,8 +152,95 @@ func (l *LifecycleServer) ReportHealth(
 func (l *LifecycleServer) GetHealthState(runtimeID string) *PluginHealthState {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
+	return l.healthStateLocked(runtimeID, time.Now())
+}
+
+// healthStateLocked returns a copy of a plugin's effective health state:
+// the self-reported state merged with active probe results and the host's
+// DEGRADED mark. A plugin that missed its heartbeat TTL is reported
+// UNHEALTHY.
+// Caller must hold lock.
+func (l *LifecycleServer) healthStateLocked(runtimeID string, now time.Time) *PluginHealthState {
+	state := l.reportedStateLocked(runtimeID, now)
+	if probe, ok := l.probes[runtimeID]; ok {
+		state = mergeProbeState(state, probe.stateLocked())
+	}
+	if degraded, ok := l.degraded[runtimeID]; ok {
+		marked := *degraded
+		marked.UnavailableDependencies = append([]string{}, degraded.UnavailableDependencies...)
+		state = mergeProbeState(state, &marked)
+	}
+	return state
+}
+
+// MarkDegraded reports a plugin as at least DEGRADED, e.g. because a
+// service it requires went away, until ClearDegraded. The plugin's own
+// reports cannot clear the mark; a worse state still wins. Watchers of the
+// plugin's services and health are notified.
+func (l *LifecycleServer) MarkDegraded(runtimeID, reason string, unavailableDependencies []string) {
+	l.setDegraded(runtimeID, &PluginHealthState{
+		State:                   connectpluginv1.HealthState_HEALTH_STATE_DEGRADED,
+		Reason:                  reason,
+		UnavailableDependencies: append([]string{}, unavailableDependencies...),
+	})
+}
+
+// ClearDegraded removes a mark set by MarkDegraded.
+func (l *LifecycleServer) ClearDegraded(runtimeID string) {
+	l.setDegraded(runtimeID, nil)
+}
+
+// setDegraded sets or clears (nil) a plugin's DEGRADED mark.
+func (l *LifecycleServer) setDegraded(runtimeID string, state *PluginHealthState) {
+	now := time.Now()
+	l.mu.Lock()
+	previous := l.healthStateLocked(runtimeID, now)
+	if state == nil {
+		delete(l.degraded, runtimeID)
+	} else {
+		l.degraded[runtimeID] = state
+	}
+	var changes []HealthChange
+	if change, changed := l.healthChangeLocked(runtimeID, previous, now); changed {
+		changes = l.publishLocked(change)
+	}
+	l.mu.Unlock()
+
+	l.notifyListeners(changes...)
+}
+
+// healthChangeLocked describes the change from previous to a plugin's
+// current effective state. Returns false if the state did not change.
+// Caller must hold lock.
+func (l *LifecycleServer) healthChangeLocked(runtimeID string, previous *PluginHealthState, now time.Time) (HealthChange, bool) {
+	change := HealthChange{RuntimeID: runtimeID, ChangedAt: now}
+	if previous != nil {
+		change.Previous = previous.State
+	}
+	if current := l.healthStateLocked(runtimeID, now); current != nil {
+		change.Current = current.State
+		change.Reason = current.Reason
+		change.UnavailableDependencies = current.UnavailableDependencies
+		change.ReportedAt = current.ReportedAt
+	}
+	return change, change.Previous != change.Current
+}
 
+// reportedStateLocked returns a copy of a plugin's self-reported health
+// state, or UNHEALTHY if it missed its heartbeat TTL.
+// Caller must hold lock.
+func (l *LifecycleServer) reportedStateLocked(runtimeID string, now time.Time) *PluginHealthState {
 	state, ok := l.states[runtimeID]
+	if l.isStaleLocked(runtimeID, now) {
+		stale := &PluginHealthState{
+			State:  connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+			Reason: staleReason(now.Sub(l.lastSeen[runtimeID])),
+		}
+		if ok {
+			stale.ReportedAt = state.ReportedAt
+		}
+		return stale
+	}
 	if !ok {
 		return nil
 	}
@@ -77,6 +250,33 @@ func (l *LifecycleServer) GetHealthState(runtimeID string) *PluginHealthState {
 		State:                   state.State,
 		Reason:                  state.Reason,
 		UnavailableDependencies: append([]string{}, state.UnavailableDependencies...),
+		ReportedAt:              state.ReportedAt,
+	}
+}
+
+// SetStateStore attaches a store that health reports are persisted to.
+// Use RestoreState to also load previously persisted health.
+func (l *LifecycleServer) SetStateStore(store StateStore) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.store = store
+}
+
+// restore loads persisted health states.
+func (l *LifecycleServer) restore(health map[string]*PersistedHealth) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Restored plugins get a full heartbeat window from now
+	now := time.Now()
+	for runtimeID, h := range health {
+		l.states[runtimeID] = &PluginHealthState{
+			State:                   h.State,
+			Reason:                  h.Reason,
+			UnavailableDependencies: h.UnavailableDependencies,
+			ReportedAt:              h.ReportedAt,
+		}
+		l.lastSeen[runtimeID] = now
 	}
 }
 
@@ -87,8 +287,15 @@ func (l *LifecycleServer) GetHealthState(runtimeID string) *PluginHealthState {
 //   - HEALTHY: route traffic (full functionality)
 //   - DEGRADED: route traffic (plugin decides what to return)
 //   - UNHEALTHY: DO NOT route traffic
+//   - Missed heartbeat TTL: treated as UNHEALTHY
+//   - Failing active probe: treated as UNHEALTHY
+//   - Flapping: DO NOT route traffic until stable (see HealthHistoryConfig)
 //   - Unknown/nil state: assume HEALTHY (backward compat)
 func (l *LifecycleServer) ShouldRouteTraffic(runtimeID string) bool {
+	if l.IsFlapping(runtimeID) {
+		return false
+	}
+
 	state := l.GetHealthState(runtimeID)
 	if state == nil {
 		// No health reported yet - assume healthy (backward compat)
@@ -109,20 +316,28 @@ func (l *LifecycleServer) ShouldRouteTraffic(runtimeID string) bool {
 }
 
 // LifecycleServerHandler returns the path and handler for the lifecycle service.
-func LifecycleServerHandler(server *LifecycleServer) (string, http.Handler) {
-	return connectpluginv1connect.NewPluginLifecycleHandler(server)
+// Pass connect.WithInterceptors(NewRuntimeAuthInterceptor(handshake)) so
+// callers are authenticated with their runtime token.
+func LifecycleServerHandler(server *LifecycleServer, opts ...connect.HandlerOption) (string, http.Handler) {
+	return connectpluginv1connect.NewPluginLifecycleHandler(server, opts...)
 }
 
 // PluginControlClient is a helper for calling PluginControl RPCs on a plugin.
-// This is used by the host to query plugin health and request shutdown.
+// This is used by the host to query plugin health, drain, pause or
+// reconfigure a plugin, and request shutdown.
 type PluginControlClient struct {
 	client connectpluginv1connect.PluginControlClient
 }
 
 // NewPluginControlClient creates a client for calling PluginControl RPCs.
-func NewPluginControlClient(endpoint string, httpClient connect.HTTPClient) *PluginControlClient {
+// Pass WithHostSecret for plugins that require host authentication.
+func NewPluginControlClient(endpoint string, httpClient connect.HTTPClient, opts ...connect.ClientOption) *PluginControlClient {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
 	return &PluginControlClient{
-		client: connectpluginv1connect.NewPluginControlClient(httpClient, endpoint),
+		client: connectpluginv1connect.NewPluginControlClient(httpClient, endpoint, opts...),
 	}
 }
 
@@ -146,3 +361,46 @@ func (p *PluginControlClient) Shutdown(ctx context.Context, gracePeriodSeconds i
 	}
 	return resp.Msg.Acknowledged, nil
 }
+
+// Drain asks the plugin to stop accepting new work and waits up to timeout
+// for in-flight work to finish. The timeout is sent in whole seconds,
+// rounded up. Returns whether it finished and the number of requests still
+// in flight.
+func (p *PluginControlClient) Drain(ctx context.Context, timeout time.Duration, reason string) (bool, int64, error) {
+	resp, err := p.client.Drain(ctx, connect.NewRequest(&connectpluginv1.DrainRequest{
+		TimeoutSeconds: int32((timeout + time.Second - 1) / time.Second),
+		Reason:         reason,
+	}))
+	if err != nil {
+		return false, 0, err
+	}
+	return resp.Msg.Drained, resp.Msg.InFlight, nil
+}
+
+// Pause suspends processing on the plugin until Resume.
+func (p *PluginControlClient) Pause(ctx context.Context, reason string) error {
+	_, err := p.client.Pause(ctx, connect.NewRequest(&connectpluginv1.PauseRequest{
+		Reason: reason,
+	}))
+	return err
+}
+
+// Resume continues processing after Pause or Drain.
+func (p *PluginControlClient) Resume(ctx context.Context) error {
+	_, err := p.client.Resume(ctx, connect.NewRequest(&connectpluginv1.ResumeRequest{}))
+	return err
+}
+
+// Reconfigure applies a new configuration to the plugin without restart.
+// Returns the configuration version now in effect.
+func (p *PluginControlClient) Reconfigure(ctx context.Context, config []byte, contentType, version string) (string, error) {
+	resp, err := p.client.Reconfigure(ctx, connect.NewRequest(&connectpluginv1.ReconfigureRequest{
+		Config:      config,
+		ContentType: contentType,
+		Version:     version,
+	}))
+	if err != nil {
+		return "", err
+	}
+	return resp.Msg.Version, nil
+}
@@ -0,0 +1,287 @@
+package connectplugin
+
+import (
+	"fmt"
+	"time"
+
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+)
+
+// HeartbeatConfig requires a plugin to report health periodically.
+// A plugin that misses its TTL is marked UNHEALTHY and stops receiving
+// traffic until it reports again.
+type HeartbeatConfig struct {
+	// Interval is how often the plugin is expected to call ReportHealth.
+	// Zero disables heartbeat tracking.
+	Interval time.Duration
+
+	// TTL is how long after its last report a plugin is considered stale.
+	// Default: 3 × Interval
+	TTL time.Duration
+
+	// RemoveAfter removes a stale plugin's health state and registrations
+	// once it has been stale this long.
+	// Default: 0 (never removed)
+	RemoveAfter time.Duration
+}
+
+// withDefaults returns the config with unset fields filled in.
+func (c HeartbeatConfig) withDefaults() HeartbeatConfig {
+	if c.TTL <= 0 {
+		c.TTL = 3 * c.Interval
+	}
+	return c
+}
+
+// HealthChange describes a change in a plugin's health as seen by the host.
+type HealthChange struct {
+	RuntimeID               string
+	Previous                connectpluginv1.HealthState
+	Current                 connectpluginv1.HealthState
+	Reason                  string
+	UnavailableDependencies []string
+
+	// ChangedAt is when the change happened.
+	ChangedAt time.Time
+
+	// ReportedAt is when the plugin last reported health (zero if never).
+	ReportedAt time.Time
+
+	// Removed is set when a stale plugin was removed after RemoveAfter.
+	Removed bool
+
+	// Flapping is set while the plugin is out of rotation for flapping.
+	Flapping bool
+}
+
+// OnHealthChange registers a callback for health changes: reported state
+// changes, heartbeat expiry and removal. Callbacks run synchronously,
+// outside the server's lock.
+func (l *LifecycleServer) OnHealthChange(fn func(HealthChange)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listeners = append(l.listeners, fn)
+}
+
+// publishLocked records health changes in the history and queues them for
+// WatchPluginHealth streams. Publishing under the lock that computed the
+// changes keeps history and streams in transition order. Returns the
+// recorded changes for notifyListeners.
+// Caller must hold lock.
+func (l *LifecycleServer) publishLocked(changes ...HealthChange) []HealthChange {
+	for i := range changes {
+		changes[i] = l.recordLocked(changes[i])
+		event := healthEvent(changes[i])
+		for watcher := range l.watchers {
+			watcher.publish(event)
+		}
+	}
+	return changes
+}
+
+// notifyListeners delivers published health changes to OnHealthChange
+// callbacks.
+// Must be called without holding the lock.
+func (l *LifecycleServer) notifyListeners(changes ...HealthChange) {
+	if len(changes) == 0 {
+		return
+	}
+	l.mu.RLock()
+	listeners := append([]func(HealthChange){}, l.listeners...)
+	l.mu.RUnlock()
+
+	for _, change := range changes {
+		for _, fn := range listeners {
+			fn(change)
+		}
+	}
+}
+
+// SetHeartbeat configures the heartbeat for a plugin, overriding the default.
+// Tracking starts now, so a plugin that never reports also goes stale.
+// A zero Interval disables heartbeat tracking for the plugin.
+func (l *LifecycleServer) SetHeartbeat(runtimeID string, config HeartbeatConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.heartbeats[runtimeID] = config.withDefaults()
+	if _, ok := l.lastSeen[runtimeID]; !ok {
+		l.lastSeen[runtimeID] = time.Now()
+	}
+	l.startMonitorLocked()
+}
+
+// SetDefaultHeartbeat configures the heartbeat for plugins without their own.
+// It applies to plugins once they register a service or report health.
+func (l *LifecycleServer) SetDefaultHeartbeat(config HeartbeatConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.defaultHeartbeat = config.withDefaults()
+	l.startMonitorLocked()
+}
+
+// trackHeartbeat starts the heartbeat window of a plugin that has not
+// reported yet (e.g. when it registers a service).
+func (l *LifecycleServer) trackHeartbeat(runtimeID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.lastSeen[runtimeID]; !ok {
+		l.lastSeen[runtimeID] = time.Now()
+		l.wakeMonitorLocked()
+	}
+}
+
+// Close stops the heartbeat monitor and health probes.
+func (l *LifecycleServer) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.stopped {
+		close(l.stopCh)
+	}
+	l.stopped = true
+	l.probes = make(map[string]*pluginProbe)
+}
+
+// heartbeatLocked returns the heartbeat config of a plugin.
+// Caller must hold lock.
+func (l *LifecycleServer) heartbeatLocked(runtimeID string) HeartbeatConfig {
+	if config, ok := l.heartbeats[runtimeID]; ok {
+		return config
+	}
+	return l.defaultHeartbeat
+}
+
+// isStaleLocked reports whether a plugin has missed its heartbeat TTL.
+// Caller must hold lock.
+func (l *LifecycleServer) isStaleLocked(runtimeID string, now time.Time) bool {
+	config := l.heartbeatLocked(runtimeID)
+	lastSeen, ok := l.lastSeen[runtimeID]
+	return ok && config.Interval > 0 && now.Sub(lastSeen) > config.TTL
+}
+
+// startMonitorLocked starts the heartbeat monitor once, or wakes it so it
+// picks up configuration changes.
+// Caller must hold lock.
+func (l *LifecycleServer) startMonitorLocked() {
+	if l.stopped {
+		return
+	}
+	if !l.monitorRunning {
+		l.monitorRunning = true
+		go l.monitorHeartbeats()
+		return
+	}
+	l.wakeMonitorLocked()
+}
+
+// wakeMonitorLocked makes the monitor recompute its next deadline.
+// Caller must hold lock.
+func (l *LifecycleServer) wakeMonitorLocked() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// monitorHeartbeats marks plugins stale when they miss their TTL and
+// removes them after RemoveAfter, sleeping until the next deadline.
+func (l *LifecycleServer) monitorHeartbeats() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		changes, next := l.checkHeartbeats(time.Now())
+		l.notifyListeners(changes...)
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-l.stopCh:
+			return
+		case <-l.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// checkHeartbeats applies heartbeat expiry, removal and the end of flapping
+// as of now. Returns the published changes and the time of the next
+// deadline (zero if none).
+func (l *LifecycleServer) checkHeartbeats(now time.Time) ([]HealthChange, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changes, next := l.checkFlappingLocked(now)
+	schedule := func(t time.Time) {
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+
+	for runtimeID, lastSeen := range l.lastSeen {
+		config := l.heartbeatLocked(runtimeID)
+		if config.Interval <= 0 {
+			continue
+		}
+
+		staleAt := lastSeen.Add(config.TTL)
+		if !now.After(staleAt) {
+			schedule(staleAt.Add(time.Millisecond))
+			continue
+		}
+
+		if _, ok := l.staleSince[runtimeID]; !ok {
+			l.staleSince[runtimeID] = now
+			change := HealthChange{
+				RuntimeID: runtimeID,
+				Current:   connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+				Reason:    staleReason(now.Sub(lastSeen)),
+				ChangedAt: now,
+			}
+			if state, ok := l.states[runtimeID]; ok {
+				change.Previous = state.State
+				change.ReportedAt = state.ReportedAt
+			}
+			changes = append(changes, change)
+		}
+
+		if config.RemoveAfter <= 0 {
+			continue
+		}
+		removeAt := l.staleSince[runtimeID].Add(config.RemoveAfter)
+		if now.Before(removeAt) {
+			schedule(removeAt)
+			continue
+		}
+
+		delete(l.states, runtimeID)
+		delete(l.lastSeen, runtimeID)
+		delete(l.staleSince, runtimeID)
+		delete(l.heartbeats, runtimeID)
+		delete(l.probeTargets, runtimeID)
+		delete(l.probeConfigs, runtimeID)
+		l.stopProbeLocked(runtimeID)
+		changes = append(changes, HealthChange{
+			RuntimeID: runtimeID,
+			Previous:  connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+			Current:   connectpluginv1.HealthState_HEALTH_STATE_UNSPECIFIED,
+			Reason:    "removed after missing heartbeats",
+			ChangedAt: now,
+			Removed:   true,
+		})
+	}
+
+	return l.publishLocked(changes...), next
+}
+
+// staleReason describes a missed heartbeat.
+func staleReason(silence time.Duration) string {
+	return fmt.Sprintf("heartbeat expired (no report for %s)", silence.Round(time.Millisecond))
+}
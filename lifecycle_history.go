@@ -0,0 +1,155 @@
+package connectplugin
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthHistoryConfig bounds the health history kept per plugin and
+// configures flap detection.
+//
+// A plugin with Threshold transitions within Window is flapping: it is
+// taken out of rotation until it goes StableFor without a transition.
+type HealthHistoryConfig struct {
+	// Size is the number of health changes kept per plugin.
+	// Default: 64
+	Size int
+
+	// FlapThreshold is the number of transitions within FlapWindow that
+	// marks a plugin as flapping. Zero disables flap detection.
+	// Default: 0
+	FlapThreshold int
+
+	// FlapWindow is the window transitions are counted in.
+	// Default: 1 minute
+	FlapWindow time.Duration
+
+	// StableFor is how long a flapping plugin must go without a transition
+	// before it is routed traffic again.
+	// Default: FlapWindow
+	StableFor time.Duration
+}
+
+// withDefaults returns the config with unset fields filled in.
+func (c HealthHistoryConfig) withDefaults() HealthHistoryConfig {
+	if c.Size <= 0 {
+		c.Size = 64
+	}
+	if c.FlapWindow <= 0 {
+		c.FlapWindow = time.Minute
+	}
+	if c.StableFor <= 0 {
+		c.StableFor = c.FlapWindow
+	}
+	return c
+}
+
+// SetHealthHistory configures the health history and flap detection.
+func (l *LifecycleServer) SetHealthHistory(config HealthHistoryConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.historyConfig = config.withDefaults()
+	for runtimeID, history := range l.history {
+		if len(history) > l.historyConfig.Size {
+			l.history[runtimeID] = history[len(history)-l.historyConfig.Size:]
+		}
+	}
+}
+
+// HealthHistory returns a plugin's recorded health changes, oldest first.
+// Entries with Flapping set were made while the plugin was out of rotation
+// for flapping; an entry with equal Previous and Current states marks the
+// end of flapping.
+func (l *LifecycleServer) HealthHistory(runtimeID string) []HealthChange {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]HealthChange(nil), l.history[runtimeID]...)
+}
+
+// IsFlapping reports whether a plugin is out of rotation for flapping.
+func (l *LifecycleServer) IsFlapping(runtimeID string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.isFlappingLocked(runtimeID, time.Now())
+}
+
+// isFlappingLocked reports whether a plugin is held out of rotation.
+// Caller must hold lock.
+func (l *LifecycleServer) isFlappingLocked(runtimeID string, now time.Time) bool {
+	until, ok := l.flapUntil[runtimeID]
+	return ok && now.Before(until)
+}
+
+// recordLocked appends a change to the plugin's history and updates its
+// flapping state. Returns the change with Flapping set.
+// Caller must hold lock.
+func (l *LifecycleServer) recordLocked(change HealthChange) HealthChange {
+	config := l.historyConfig
+
+	if change.Removed {
+		delete(l.history, change.RuntimeID)
+		delete(l.flapUntil, change.RuntimeID)
+		return change
+	}
+
+	if change.Previous != change.Current && config.FlapThreshold > 0 {
+		// Count this transition and the recorded ones within the window
+		transitions := 1
+		for _, past := range l.history[change.RuntimeID] {
+			if past.Previous != past.Current && change.ChangedAt.Sub(past.ChangedAt) < config.FlapWindow {
+				transitions++
+			}
+		}
+
+		// Any transition while flapping restarts the stable period
+		if transitions >= config.FlapThreshold || l.isFlappingLocked(change.RuntimeID, change.ChangedAt) {
+			if !l.isFlappingLocked(change.RuntimeID, change.ChangedAt) {
+				change.Reason = fmt.Sprintf("flapping (%d transitions in %s): %s", transitions, config.FlapWindow, change.Reason)
+			}
+			l.flapUntil[change.RuntimeID] = change.ChangedAt.Add(config.StableFor)
+			l.startMonitorLocked()
+		}
+	}
+	change.Flapping = l.isFlappingLocked(change.RuntimeID, change.ChangedAt)
+
+	history := append(l.history[change.RuntimeID], change)
+	if len(history) > config.Size {
+		history = history[len(history)-config.Size:]
+	}
+	l.history[change.RuntimeID] = history
+	return change
+}
+
+// checkFlappingLocked returns plugins back to rotation once they have been
+// stable long enough. Returns the resulting changes and the time of the
+// next deadline (zero if none).
+// Caller must hold lock.
+func (l *LifecycleServer) checkFlappingLocked(now time.Time) ([]HealthChange, time.Time) {
+	var changes []HealthChange
+	var next time.Time
+
+	for runtimeID, until := range l.flapUntil {
+		if now.Before(until) {
+			if next.IsZero() || until.Before(next) {
+				next = until
+			}
+			continue
+		}
+
+		delete(l.flapUntil, runtimeID)
+		change := HealthChange{
+			RuntimeID: runtimeID,
+			Reason:    fmt.Sprintf("stable for %s, no longer flapping", l.historyConfig.StableFor),
+			ChangedAt: now,
+		}
+		if state := l.healthStateLocked(runtimeID, now); state != nil {
+			change.Previous = state.State
+			change.Current = state.State
+			change.UnavailableDependencies = state.UnavailableDependencies
+			change.ReportedAt = state.ReportedAt
+		}
+		changes = append(changes, change)
+	}
+	return changes, next
+}
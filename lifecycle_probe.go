@@ -0,0 +1,406 @@
+package connectplugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
+)
+
+// ProbeMethod selects the RPC the host uses to probe a plugin.
+type ProbeMethod int
+
+const (
+	// ProbePluginControl calls PluginControl.GetHealth (default).
+	ProbePluginControl ProbeMethod = iota
+
+	// ProbeHealthService calls HealthService.Check.
+	ProbeHealthService
+)
+
+// ProbeConfig makes the host actively probe a plugin's health.
+// Probe results are merged with the plugin's self-reported state: the worse
+// of the two wins, so a plugin that keeps sending heartbeats but no longer
+// answers probes is taken out of rotation.
+type ProbeConfig struct {
+	// Method is the RPC used to probe.
+	// Default: ProbePluginControl
+	Method ProbeMethod
+
+	// Service is the service name passed to HealthService.Check.
+	// Default: "" (overall health)
+	Service string
+
+	// Interval is how often the plugin is probed.
+	// Zero disables probing.
+	Interval time.Duration
+
+	// Timeout bounds each probe.
+	// Default: 1s
+	Timeout time.Duration
+
+	// FailureThreshold is the number of consecutive failed probes after
+	// which the plugin is considered UNHEALTHY.
+	// Default: 3
+	FailureThreshold int
+
+	// SuccessThreshold is the number of consecutive successful probes
+	// needed to recover after reaching FailureThreshold.
+	// Default: 1
+	SuccessThreshold int
+
+	// HTTPClient is used to call the plugin. Plugins registered with the
+	// ServiceRouter through RegisterPluginClient are probed with that client.
+	// Default: http.DefaultClient
+	HTTPClient connect.HTTPClient
+}
+
+// withDefaults returns the config with unset fields filled in.
+func (c ProbeConfig) withDefaults() ProbeConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = time.Second
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 3
+	}
+	if c.SuccessThreshold <= 0 {
+		c.SuccessThreshold = 1
+	}
+	return c
+}
+
+// ProbeStatus reports the outcome of a plugin's health probes.
+type ProbeStatus struct {
+	Endpoint string
+
+	// Failing is set once FailureThreshold consecutive probes failed, until
+	// SuccessThreshold consecutive probes succeed.
+	Failing bool
+
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+
+	// State is the state returned by the last successful probe.
+	State  connectpluginv1.HealthState
+	Reason string
+
+	// LastError is the error of the last failed probe.
+	LastError string
+
+	// CheckedAt is when the last probe completed (zero if none has).
+	CheckedAt time.Time
+}
+
+// probeTarget is where a plugin is reached for probing.
+type probeTarget struct {
+	endpoint string
+	client   connect.HTTPClient // nil = ProbeConfig.HTTPClient
+}
+
+// pluginProbe probes one plugin on an interval.
+// Fields other than target, config and stop are guarded by LifecycleServer.mu.
+type pluginProbe struct {
+	target probeTarget
+	config ProbeConfig
+	stop   chan struct{}
+
+	failures  int
+	successes int
+	failing   bool
+	result    *PluginHealthState // last successful probe
+	lastErr   error
+	checkedAt time.Time
+}
+
+// SetProbe configures active probing of a plugin at endpoint, overriding
+// the default. A zero Interval disables probing of the plugin.
+func (l *LifecycleServer) SetProbe(runtimeID, endpoint string, config ProbeConfig) {
+	now := time.Now()
+	l.mu.Lock()
+	previous := l.healthStateLocked(runtimeID, now)
+
+	target := l.probeTargets[runtimeID]
+	target.endpoint = endpoint
+	l.probeTargets[runtimeID] = target
+	l.probeConfigs[runtimeID] = config.withDefaults()
+	l.restartProbeLocked(runtimeID)
+
+	var changes []HealthChange
+	if change, changed := l.healthChangeLocked(runtimeID, previous, now); changed {
+		changes = l.publishLocked(change)
+	}
+	l.mu.Unlock()
+
+	l.notifyListeners(changes...)
+}
+
+// SetDefaultProbe configures active probing for plugins without their own
+// probe config. It applies to every plugin whose endpoint is registered
+// with the ServiceRouter (e.g. by Platform or PluginLauncher).
+func (l *LifecycleServer) SetDefaultProbe(config ProbeConfig) {
+	now := time.Now()
+	l.mu.Lock()
+
+	l.defaultProbe = config.withDefaults()
+
+	var changes []HealthChange
+	for runtimeID := range l.probeTargets {
+		if _, ok := l.probeConfigs[runtimeID]; ok {
+			continue
+		}
+		previous := l.healthStateLocked(runtimeID, now)
+		l.restartProbeLocked(runtimeID)
+		if change, changed := l.healthChangeLocked(runtimeID, previous, now); changed {
+			changes = append(changes, change)
+		}
+	}
+	changes = l.publishLocked(changes...)
+	l.mu.Unlock()
+
+	l.notifyListeners(changes...)
+}
+
+// GetProbeStatus returns the probe status of a plugin.
+// Returns nil if the plugin is not probed.
+func (l *LifecycleServer) GetProbeStatus(runtimeID string) *ProbeStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	probe, ok := l.probes[runtimeID]
+	if !ok {
+		return nil
+	}
+
+	status := &ProbeStatus{
+		Endpoint:             probe.target.endpoint,
+		Failing:              probe.failing,
+		ConsecutiveFailures:  probe.failures,
+		ConsecutiveSuccesses: probe.successes,
+		CheckedAt:            probe.checkedAt,
+	}
+	if probe.result != nil {
+		status.State = probe.result.State
+		status.Reason = probe.result.Reason
+	}
+	if probe.lastErr != nil {
+		status.LastError = probe.lastErr.Error()
+	}
+	return status
+}
+
+// setProbeTarget records where a plugin is reached, starting the default
+// probe if one is configured. An empty endpoint stops probing the plugin.
+// Called by the ServiceRouter as plugin endpoints come and go.
+func (l *LifecycleServer) setProbeTarget(runtimeID, endpoint string, client connect.HTTPClient) {
+	now := time.Now()
+	l.mu.Lock()
+	previous := l.healthStateLocked(runtimeID, now)
+
+	if endpoint == "" {
+		delete(l.probeTargets, runtimeID)
+		delete(l.probeConfigs, runtimeID)
+	} else {
+		l.probeTargets[runtimeID] = probeTarget{endpoint: endpoint, client: client}
+	}
+	l.restartProbeLocked(runtimeID)
+
+	var changes []HealthChange
+	if change, changed := l.healthChangeLocked(runtimeID, previous, now); changed {
+		changes = l.publishLocked(change)
+	}
+	l.mu.Unlock()
+
+	l.notifyListeners(changes...)
+}
+
+// setProbeClient sets the HTTP client a plugin is probed with.
+func (l *LifecycleServer) setProbeClient(runtimeID string, client connect.HTTPClient) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	target, ok := l.probeTargets[runtimeID]
+	if !ok {
+		return
+	}
+	target.client = client
+	l.probeTargets[runtimeID] = target
+	l.restartProbeLocked(runtimeID)
+}
+
+// restartProbeLocked stops a plugin's probe and starts a new one from its
+// current target and config. Previous results are discarded.
+// Caller must hold lock.
+func (l *LifecycleServer) restartProbeLocked(runtimeID string) {
+	l.stopProbeLocked(runtimeID)
+
+	target, ok := l.probeTargets[runtimeID]
+	if !ok || target.endpoint == "" || l.stopped {
+		return
+	}
+	config, ok := l.probeConfigs[runtimeID]
+	if !ok {
+		config = l.defaultProbe
+	}
+	if config.Interval <= 0 {
+		return
+	}
+
+	probe := &pluginProbe{
+		target: target,
+		config: config,
+		stop:   make(chan struct{}),
+	}
+	l.probes[runtimeID] = probe
+	go l.runProbe(runtimeID, probe)
+}
+
+// stopProbeLocked stops a plugin's probe, if any.
+// Caller must hold lock.
+func (l *LifecycleServer) stopProbeLocked(runtimeID string) {
+	if probe, ok := l.probes[runtimeID]; ok {
+		close(probe.stop)
+		delete(l.probes, runtimeID)
+	}
+}
+
+// runProbe probes a plugin every Interval until stopped.
+func (l *LifecycleServer) runProbe(runtimeID string, probe *pluginProbe) {
+	ticker := time.NewTicker(probe.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-probe.stop:
+			return
+		case <-l.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), probe.config.Timeout)
+		result, err := probe.check(ctx)
+		cancel()
+
+		l.applyProbe(runtimeID, probe, result, err)
+	}
+}
+
+// check runs one probe.
+func (p *pluginProbe) check(ctx context.Context) (*PluginHealthState, error) {
+	client := p.target.client
+	if client == nil {
+		client = p.config.HTTPClient
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	switch p.config.Method {
+	case ProbeHealthService:
+		resp, err := connectpluginv1connect.NewHealthServiceClient(client, p.target.endpoint).Check(
+			ctx, connect.NewRequest(&connectpluginv1.HealthCheckRequest{Service: p.config.Service}))
+		if err != nil {
+			return nil, err
+		}
+		switch resp.Msg.Status {
+		case connectpluginv1.ServingStatus_SERVING_STATUS_SERVING:
+			return &PluginHealthState{State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY}, nil
+		case connectpluginv1.ServingStatus_SERVING_STATUS_NOT_SERVING:
+			return &PluginHealthState{
+				State:  connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+				Reason: "health check: not serving",
+			}, nil
+		default:
+			return nil, fmt.Errorf("health check returned %v", resp.Msg.Status)
+		}
+
+	default:
+		resp, err := NewPluginControlClient(p.target.endpoint, client).GetHealth(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &PluginHealthState{
+			State:                   resp.State,
+			Reason:                  resp.Reason,
+			UnavailableDependencies: resp.UnavailableDependencies,
+		}, nil
+	}
+}
+
+// applyProbe records a probe result and emits the resulting health change.
+func (l *LifecycleServer) applyProbe(runtimeID string, probe *pluginProbe, result *PluginHealthState, err error) {
+	now := time.Now()
+	l.mu.Lock()
+
+	// Drop results of a probe that was stopped or replaced meanwhile
+	if l.probes[runtimeID] != probe {
+		l.mu.Unlock()
+		return
+	}
+	previous := l.healthStateLocked(runtimeID, now)
+
+	probe.checkedAt = now
+	if err != nil {
+		probe.failures++
+		probe.successes = 0
+		probe.lastErr = err
+		if probe.failures >= probe.config.FailureThreshold {
+			probe.failing = true
+		}
+	} else {
+		probe.successes++
+		probe.failures = 0
+		probe.result = result
+		if probe.successes >= probe.config.SuccessThreshold {
+			probe.failing = false
+		}
+	}
+
+	var changes []HealthChange
+	if change, changed := l.healthChangeLocked(runtimeID, previous, now); changed {
+		changes = l.publishLocked(change)
+	}
+	l.mu.Unlock()
+
+	l.notifyListeners(changes...)
+}
+
+// stateLocked returns the health state the probe vouches for, or nil if it
+// has no verdict yet.
+// Caller must hold lock.
+func (p *pluginProbe) stateLocked() *PluginHealthState {
+	if p.failing {
+		return &PluginHealthState{
+			State:  connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+			Reason: fmt.Sprintf("health probe failed %d times: %v", p.failures, p.lastErr),
+		}
+	}
+	if p.result == nil || p.result.State == connectpluginv1.HealthState_HEALTH_STATE_UNSPECIFIED {
+		return nil
+	}
+	return &PluginHealthState{
+		State:                   p.result.State,
+		Reason:                  p.result.Reason,
+		UnavailableDependencies: append([]string{}, p.result.UnavailableDependencies...),
+	}
+}
+
+// mergeProbeState combines a self-reported (or stale) state with the probe's
+// verdict. The worse state wins; HealthState values are ordered by severity.
+func mergeProbeState(reported, probed *PluginHealthState) *PluginHealthState {
+	if probed == nil {
+		return reported
+	}
+	if reported == nil {
+		return probed
+	}
+	if probed.State > reported.State {
+		probed.ReportedAt = reported.ReportedAt
+		return probed
+	}
+	return reported
+}
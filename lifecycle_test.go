@@ -2,10 +2,16 @@ package connectplugin
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"connectrpc.com/connect"
 	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
 )
 
 func TestLifecycleServer_HealthStateTracking(t *testing.T) {
@@ -21,9 +27,8 @@ func TestLifecycleServer_HealthStateTracking(t *testing.T) {
 		State:  connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
 		Reason: "all systems operational",
 	})
-	req.Header().Set("X-Plugin-Runtime-ID", "test-plugin-abc123")
 
-	_, err := server.ReportHealth(context.Background(), req)
+	_, err := server.ReportHealth(runtimeContext("test-plugin-abc123"), req)
 	if err != nil {
 		t.Fatalf("ReportHealth failed: %v", err)
 	}
@@ -39,9 +44,8 @@ func TestLifecycleServer_HealthStateTracking(t *testing.T) {
 		Reason:                  "logger dependency unavailable",
 		UnavailableDependencies: []string{"logger"},
 	})
-	req2.Header().Set("X-Plugin-Runtime-ID", "test-plugin-abc123")
 
-	_, err = server.ReportHealth(context.Background(), req2)
+	_, err = server.ReportHealth(runtimeContext("test-plugin-abc123"), req2)
 	if err != nil {
 		t.Fatalf("ReportHealth failed: %v", err)
 	}
@@ -71,9 +75,8 @@ func TestLifecycleServer_HealthStateTracking(t *testing.T) {
 		State:  connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
 		Reason: "database connection failed",
 	})
-	req3.Header().Set("X-Plugin-Runtime-ID", "test-plugin-abc123")
 
-	_, err = server.ReportHealth(context.Background(), req3)
+	_, err = server.ReportHealth(runtimeContext("test-plugin-abc123"), req3)
 	if err != nil {
 		t.Fatalf("ReportHealth failed: %v", err)
 	}
@@ -91,15 +94,13 @@ func TestLifecycleServer_MultiplePlugins(t *testing.T) {
 	reqA := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
 	})
-	reqA.Header().Set("X-Plugin-Runtime-ID", "plugin-a-xyz")
-	server.ReportHealth(context.Background(), reqA)
+	server.ReportHealth(runtimeContext("plugin-a-xyz"), reqA)
 
 	// Plugin B is unhealthy
 	reqB := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
 	})
-	reqB.Header().Set("X-Plugin-Runtime-ID", "plugin-b-123")
-	server.ReportHealth(context.Background(), reqB)
+	server.ReportHealth(runtimeContext("plugin-b-123"), reqB)
 
 	// Should route to A, not to B
 	if !server.ShouldRouteTraffic("plugin-a-xyz") {
@@ -110,17 +111,439 @@ func TestLifecycleServer_MultiplePlugins(t *testing.T) {
 	}
 }
 
-func TestLifecycleServer_MissingRuntimeID(t *testing.T) {
+func TestLifecycleServer_Unauthenticated(t *testing.T) {
 	server := NewLifecycleServer()
 
-	// Request without runtime ID should fail
+	// Raw header without a verified runtime identity should fail
 	req := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
-		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+		State: connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
 	})
-	// No X-Plugin-Runtime-ID header
+	req.Header().Set("X-Plugin-Runtime-ID", "victim-plugin")
 
 	_, err := server.ReportHealth(context.Background(), req)
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", err)
+	}
+	if server.GetHealthState("victim-plugin") != nil {
+		t.Error("Expected no health state for unauthenticated report")
+	}
+}
+
+func TestLifecycleServer_HeartbeatExpiry(t *testing.T) {
+	server := NewLifecycleServer()
+	defer server.Close()
+
+	changes := make(chan HealthChange, 10)
+	server.OnHealthChange(func(c HealthChange) { changes <- c })
+
+	server.SetHeartbeat("cache-x7k2", HeartbeatConfig{
+		Interval:    20 * time.Millisecond,
+		TTL:         50 * time.Millisecond,
+		RemoveAfter: 50 * time.Millisecond,
+	})
+
+	healthy := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+	})
+	if _, err := server.ReportHealth(runtimeContext("cache-x7k2"), healthy); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+	if c := <-changes; c.Current != connectpluginv1.HealthState_HEALTH_STATE_HEALTHY {
+		t.Errorf("Expected change to HEALTHY, got %+v", c)
+	}
+
+	// Missing the TTL makes the plugin unroutable and emits a change
+	select {
+	case c := <-changes:
+		if c.Current != connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY || c.Removed {
+			t.Errorf("Expected change to UNHEALTHY, got %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected stale plugin to become UNHEALTHY")
+	}
+	if server.ShouldRouteTraffic("cache-x7k2") {
+		t.Error("Expected no traffic to stale plugin")
+	}
+
+	// A new report recovers it
+	if _, err := server.ReportHealth(runtimeContext("cache-x7k2"), healthy); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+	if c := <-changes; c.Previous != connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY {
+		t.Errorf("Expected recovery from UNHEALTHY, got %+v", c)
+	}
+	if !server.ShouldRouteTraffic("cache-x7k2") {
+		t.Error("Expected traffic after recovery")
+	}
+
+	// Staying silent past RemoveAfter removes the plugin
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case c := <-changes:
+			if !c.Removed {
+				continue
+			}
+			if server.GetHealthState("cache-x7k2") != nil {
+				t.Error("Expected health state removed")
+			}
+			return
+		case <-deadline:
+			t.Fatal("Expected stale plugin to be removed")
+		}
+	}
+}
+
+func TestLifecycleServer_DefaultHeartbeatUnregistersProvider(t *testing.T) {
+	lifecycle := NewLifecycleServer()
+	defer lifecycle.Close()
+	registry := NewServiceRegistry(lifecycle)
+
+	lifecycle.SetDefaultHeartbeat(HeartbeatConfig{
+		Interval:    10 * time.Millisecond,
+		RemoveAfter: 20 * time.Millisecond,
+	})
+
+	// Registers but never reports health
+	regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+		ServiceType:  "cache",
+		Version:      "1.0.0",
+		EndpointPath: "/cache.v1.Cache/",
+	})
+	if _, err := registry.RegisterService(runtimeContext("cache-x7k2"), regReq); err != nil {
+		t.Fatalf("RegisterService failed: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(registry.GetAllProviders("cache")) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected silent provider to be unregistered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLifecycleServer_WatchPluginHealth(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	defer lifecycle.Close()
+	registry := NewServiceRegistry(lifecycle)
+	server := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	watcherToken, _ := generateToken()
+	registerTestToken(handshake, "dashboard-a1b2", watcherToken)
+
+	healthy := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+	})
+	if _, err := lifecycle.ReportHealth(runtimeContext("cache-x7k2"), healthy); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := connectpluginv1connect.NewPluginLifecycleClient(http.DefaultClient, server.URL)
+	req := connect.NewRequest(&connectpluginv1.WatchPluginHealthRequest{RuntimeId: "cache-x7k2"})
+	req.Header().Set(RuntimeIDHeader, "dashboard-a1b2")
+	req.Header().Set("Authorization", "Bearer "+watcherToken)
+	stream, err := client.WatchPluginHealth(ctx, req)
+	if err != nil {
+		t.Fatalf("WatchPluginHealth failed: %v", err)
+	}
+	defer stream.Close()
+
+	// The stream starts with the current state
+	if !stream.Receive() {
+		t.Fatalf("Expected initial event: %v", stream.Err())
+	}
+	initial := stream.Msg()
+	if !initial.Initial || initial.State != connectpluginv1.HealthState_HEALTH_STATE_HEALTHY {
+		t.Errorf("Expected initial HEALTHY event, got %v", initial)
+	}
+	if initial.ReportedAt == nil {
+		t.Error("Expected reported_at on initial event")
+	}
+
+	// Transitions of other plugins are filtered out
+	if _, err := lifecycle.ReportHealth(runtimeContext("logger-abc1"), healthy); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+
+	degraded := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State:                   connectpluginv1.HealthState_HEALTH_STATE_DEGRADED,
+		Reason:                  "logger dependency unavailable",
+		UnavailableDependencies: []string{"logger"},
+	})
+	if _, err := lifecycle.ReportHealth(runtimeContext("cache-x7k2"), degraded); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+
+	if !stream.Receive() {
+		t.Fatalf("Expected transition event: %v", stream.Err())
+	}
+	event := stream.Msg()
+	if event.RuntimeId != "cache-x7k2" || event.Initial {
+		t.Errorf("Expected transition of cache-x7k2, got %v", event)
+	}
+	if event.PreviousState != connectpluginv1.HealthState_HEALTH_STATE_HEALTHY ||
+		event.State != connectpluginv1.HealthState_HEALTH_STATE_DEGRADED {
+		t.Errorf("Expected HEALTHY → DEGRADED, got %v → %v", event.PreviousState, event.State)
+	}
+	if event.Reason != "logger dependency unavailable" || len(event.UnavailableDependencies) != 1 {
+		t.Errorf("Expected reason and dependencies, got %v", event)
+	}
+	if event.ChangedAt == nil {
+		t.Error("Expected changed_at on transition event")
+	}
+}
+
+func TestLifecycleServer_WatchPluginHealthUnauthenticated(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	server := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	client := connectpluginv1connect.NewPluginLifecycleClient(http.DefaultClient, server.URL)
+	stream, err := client.WatchPluginHealth(context.Background(), connect.NewRequest(&connectpluginv1.WatchPluginHealthRequest{}))
 	if err == nil {
-		t.Error("Expected error when X-Plugin-Runtime-ID header missing")
+		defer stream.Close()
+		stream.Receive()
+		err = stream.Err()
+	}
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", err)
+	}
+}
+
+// probedPlugin is a PluginControl handler whose health and responsiveness
+// tests control.
+type probedPlugin struct {
+	connectpluginv1connect.UnimplementedPluginControlHandler
+
+	mu    sync.Mutex
+	state connectpluginv1.HealthState
+	hang  bool
+}
+
+func (p *probedPlugin) set(state connectpluginv1.HealthState, hang bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state, p.hang = state, hang
+}
+
+func (p *probedPlugin) GetHealth(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.GetHealthRequest],
+) (*connect.Response[connectpluginv1.GetHealthResponse], error) {
+	p.mu.Lock()
+	state, hang := p.state, p.hang
+	p.mu.Unlock()
+
+	if hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return connect.NewResponse(&connectpluginv1.GetHealthResponse{State: state}), nil
+}
+
+func startProbedPlugin(t *testing.T) (*probedPlugin, string) {
+	t.Helper()
+
+	plugin := &probedPlugin{state: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY}
+	mux := http.NewServeMux()
+	mux.Handle(connectpluginv1connect.NewPluginControlHandler(plugin))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return plugin, server.URL
+}
+
+func TestLifecycleServer_ProbeDetectsHungPlugin(t *testing.T) {
+	server := NewLifecycleServer()
+	defer server.Close()
+
+	changes := make(chan HealthChange, 10)
+	server.OnHealthChange(func(c HealthChange) { changes <- c })
+
+	plugin, endpoint := startProbedPlugin(t)
+
+	// The plugin keeps reporting HEALTHY
+	healthy := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+	})
+	if _, err := server.ReportHealth(runtimeContext("cache-x7k2"), healthy); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+	<-changes
+
+	server.SetProbe("cache-x7k2", endpoint, ProbeConfig{
+		Interval:         10 * time.Millisecond,
+		Timeout:          20 * time.Millisecond,
+		FailureThreshold: 2,
+	})
+
+	waitForChange := func(want connectpluginv1.HealthState) HealthChange {
+		t.Helper()
+		deadline := time.After(2 * time.Second)
+		for {
+			select {
+			case c := <-changes:
+				if c.Current == want {
+					return c
+				}
+			case <-deadline:
+				t.Fatalf("Expected change to %v", want)
+			}
+		}
+	}
+
+	// A probe reporting DEGRADED is worse than the self-reported state
+	plugin.set(connectpluginv1.HealthState_HEALTH_STATE_DEGRADED, false)
+	waitForChange(connectpluginv1.HealthState_HEALTH_STATE_DEGRADED)
+
+	// A hung plugin is taken out of rotation despite its reports
+	plugin.set(connectpluginv1.HealthState_HEALTH_STATE_HEALTHY, true)
+	c := waitForChange(connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY)
+	if c.Previous != connectpluginv1.HealthState_HEALTH_STATE_DEGRADED {
+		t.Errorf("Expected change from DEGRADED, got %+v", c)
+	}
+	if _, err := server.ReportHealth(runtimeContext("cache-x7k2"), healthy); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+	if server.ShouldRouteTraffic("cache-x7k2") {
+		t.Error("Expected no traffic to plugin failing probes")
+	}
+	status := server.GetProbeStatus("cache-x7k2")
+	if status == nil || !status.Failing || status.LastError == "" {
+		t.Errorf("Expected failing probe status, got %+v", status)
+	}
+
+	// Answering probes again restores it
+	plugin.set(connectpluginv1.HealthState_HEALTH_STATE_HEALTHY, false)
+	waitForChange(connectpluginv1.HealthState_HEALTH_STATE_HEALTHY)
+	if !server.ShouldRouteTraffic("cache-x7k2") {
+		t.Error("Expected traffic after probes recover")
+	}
+}
+
+func TestLifecycleServer_DefaultProbeFollowsRouterEndpoints(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	defer lifecycle.Close()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	lifecycle.SetDefaultProbe(ProbeConfig{Interval: 10 * time.Millisecond})
+
+	_, endpoint := startProbedPlugin(t)
+	router.RegisterPluginEndpoint("cache-x7k2", endpoint)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status := lifecycle.GetProbeStatus("cache-x7k2")
+		if status != nil && status.State == connectpluginv1.HealthState_HEALTH_STATE_HEALTHY {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected plugin to be probed, got %+v", status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	router.UnregisterPluginEndpoint("cache-x7k2")
+	if status := lifecycle.GetProbeStatus("cache-x7k2"); status != nil {
+		t.Errorf("Expected probing to stop, got %+v", status)
+	}
+}
+
+func TestLifecycleServer_FlapDetection(t *testing.T) {
+	server := NewLifecycleServer()
+	defer server.Close()
+
+	changes := make(chan HealthChange, 10)
+	server.OnHealthChange(func(c HealthChange) { changes <- c })
+
+	server.SetHealthHistory(HealthHistoryConfig{
+		FlapThreshold: 3,
+		FlapWindow:    time.Minute,
+		StableFor:     50 * time.Millisecond,
+	})
+
+	report := func(state connectpluginv1.HealthState) {
+		t.Helper()
+		req := connect.NewRequest(&connectpluginv1.ReportHealthRequest{State: state})
+		if _, err := server.ReportHealth(runtimeContext("cache-x7k2"), req); err != nil {
+			t.Fatalf("ReportHealth failed: %v", err)
+		}
+		<-changes
+	}
+
+	report(connectpluginv1.HealthState_HEALTH_STATE_HEALTHY)
+	report(connectpluginv1.HealthState_HEALTH_STATE_DEGRADED)
+	if !server.ShouldRouteTraffic("cache-x7k2") {
+		t.Fatal("Expected traffic before flapping")
+	}
+
+	// The third transition in the window marks the plugin flapping
+	report(connectpluginv1.HealthState_HEALTH_STATE_HEALTHY)
+	if !server.IsFlapping("cache-x7k2") || server.ShouldRouteTraffic("cache-x7k2") {
+		t.Fatal("Expected flapping plugin out of rotation")
+	}
+
+	history := server.HealthHistory("cache-x7k2")
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 history entries, got %d", len(history))
+	}
+	last := history[2]
+	if !last.Flapping || !strings.Contains(last.Reason, "flapping") {
+		t.Errorf("Expected last entry to explain flapping, got %+v", last)
+	}
+	if history[0].Flapping || history[1].Flapping {
+		t.Error("Expected earlier entries not flapping")
+	}
+
+	// Staying stable returns it to rotation
+	select {
+	case c := <-changes:
+		if c.Flapping || c.Previous != c.Current {
+			t.Errorf("Expected end of flapping, got %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected flapping to end")
+	}
+	if !server.ShouldRouteTraffic("cache-x7k2") {
+		t.Error("Expected traffic once stable")
+	}
+	if n := len(server.HealthHistory("cache-x7k2")); n != 4 {
+		t.Errorf("Expected end of flapping recorded, got %d entries", n)
+	}
+}
+
+func TestLifecycleServer_HealthHistoryBounded(t *testing.T) {
+	server := NewLifecycleServer()
+	server.SetHealthHistory(HealthHistoryConfig{Size: 2})
+
+	for _, state := range []connectpluginv1.HealthState{
+		connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+		connectpluginv1.HealthState_HEALTH_STATE_DEGRADED,
+		connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+	} {
+		req := connect.NewRequest(&connectpluginv1.ReportHealthRequest{State: state})
+		if _, err := server.ReportHealth(runtimeContext("cache-x7k2"), req); err != nil {
+			t.Fatalf("ReportHealth failed: %v", err)
+		}
+	}
+
+	history := server.HealthHistory("cache-x7k2")
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+	if history[0].Current != connectpluginv1.HealthState_HEALTH_STATE_DEGRADED ||
+		history[1].Current != connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY {
+		t.Errorf("Expected most recent entries, got %+v", history)
+	}
+	if server.IsFlapping("cache-x7k2") {
+		t.Error("Expected flap detection disabled by default")
 	}
 }
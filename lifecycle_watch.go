@@ -0,0 +1,190 @@
+package connectplugin
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// pluginHealthWatcher is a WatchPluginHealth stream.
+// Holds at most one pending event per plugin: newer transitions replace
+// older unsent ones.
+type pluginHealthWatcher struct {
+	runtimeID string // "" watches all plugins
+
+	mu      sync.Mutex
+	pending map[string]*connectpluginv1.PluginHealthEvent
+	order   []string      // runtime IDs with pending events, oldest first
+	notify  chan struct{} // capacity 1, signals pending events
+}
+
+func newPluginHealthWatcher(runtimeID string) *pluginHealthWatcher {
+	return &pluginHealthWatcher{
+		runtimeID: runtimeID,
+		pending:   make(map[string]*connectpluginv1.PluginHealthEvent),
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// WatchPluginHealth implements the watch RPC.
+// Streams health transitions for one plugin (runtime_id) or all plugins,
+// starting with the current state of each.
+//
+// Events are never dropped: if the watcher falls behind, a plugin's
+// intermediate transitions are coalesced into one event whose
+// previous_state is the last state the watcher saw.
+func (l *LifecycleServer) WatchPluginHealth(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.WatchPluginHealthRequest],
+	stream *connect.ServerStream[connectpluginv1.PluginHealthEvent],
+) error {
+	// Use the runtime ID verified by RuntimeAuthInterceptor
+	if _, err := authenticatedRuntimeID(ctx); err != nil {
+		return err
+	}
+
+	watcher := newPluginHealthWatcher(req.Msg.RuntimeId)
+
+	l.mu.Lock()
+	l.watchers[watcher] = struct{}{}
+
+	// Send current states. A watch on one plugin always gets an initial
+	// event, UNSPECIFIED if the plugin is unknown.
+	now := time.Now()
+	for _, runtimeID := range l.runtimeIDsLocked() {
+		if watcher.runtimeID != "" && runtimeID != watcher.runtimeID {
+			continue
+		}
+		if state := l.healthStateLocked(runtimeID, now); state != nil {
+			watcher.publish(initialHealthEvent(runtimeID, state))
+		}
+	}
+	if watcher.runtimeID != "" && len(watcher.pending) == 0 {
+		watcher.publish(&connectpluginv1.PluginHealthEvent{RuntimeId: watcher.runtimeID, Initial: true})
+	}
+
+	l.mu.Unlock()
+
+	// Cleanup on exit
+	defer func() {
+		l.mu.Lock()
+		delete(l.watchers, watcher)
+		l.mu.Unlock()
+	}()
+
+	// Stream events
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-watcher.notify:
+			for _, event := range watcher.take() {
+				if err := stream.Send(event); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// runtimeIDsLocked returns the sorted runtime IDs with health or heartbeat
+// tracking.
+// Caller must hold lock.
+func (l *LifecycleServer) runtimeIDsLocked() []string {
+	seen := make(map[string]bool, len(l.states))
+	for runtimeID := range l.states {
+		seen[runtimeID] = true
+	}
+	for runtimeID := range l.lastSeen {
+		seen[runtimeID] = true
+	}
+
+	ids := make([]string, 0, len(seen))
+	for runtimeID := range seen {
+		ids = append(ids, runtimeID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// publish queues an event for the watcher if it matches, coalescing with a
+// pending event for the same plugin. Never blocks.
+func (w *pluginHealthWatcher) publish(event *connectpluginv1.PluginHealthEvent) {
+	if w.runtimeID != "" && event.RuntimeId != w.runtimeID {
+		return
+	}
+
+	w.mu.Lock()
+	if prior, ok := w.pending[event.RuntimeId]; ok {
+		// Keep the state the watcher last saw as the previous state
+		merged := proto.Clone(event).(*connectpluginv1.PluginHealthEvent)
+		merged.PreviousState = prior.PreviousState
+		merged.Initial = prior.Initial && event.Initial
+		event = merged
+	} else {
+		w.order = append(w.order, event.RuntimeId)
+	}
+	w.pending[event.RuntimeId] = event
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+		// Wake-up already pending
+	}
+}
+
+// take returns and clears the pending events, oldest first.
+func (w *pluginHealthWatcher) take() []*connectpluginv1.PluginHealthEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := make([]*connectpluginv1.PluginHealthEvent, 0, len(w.order))
+	for _, runtimeID := range w.order {
+		events = append(events, w.pending[runtimeID])
+	}
+	w.pending = make(map[string]*connectpluginv1.PluginHealthEvent)
+	w.order = nil
+	return events
+}
+
+// healthEvent converts a health change to its wire form.
+func healthEvent(change HealthChange) *connectpluginv1.PluginHealthEvent {
+	return &connectpluginv1.PluginHealthEvent{
+		RuntimeId:               change.RuntimeID,
+		State:                   change.Current,
+		PreviousState:           change.Previous,
+		Reason:                  change.Reason,
+		UnavailableDependencies: change.UnavailableDependencies,
+		ChangedAt:               timestampOrNil(change.ChangedAt),
+		ReportedAt:              timestampOrNil(change.ReportedAt),
+		Removed:                 change.Removed,
+	}
+}
+
+// initialHealthEvent describes a plugin's current state at the start of a watch.
+func initialHealthEvent(runtimeID string, state *PluginHealthState) *connectpluginv1.PluginHealthEvent {
+	return &connectpluginv1.PluginHealthEvent{
+		RuntimeId:               runtimeID,
+		State:                   state.State,
+		Reason:                  state.Reason,
+		UnavailableDependencies: state.UnavailableDependencies,
+		ReportedAt:              timestampOrNil(state.ReportedAt),
+		Initial:                 true,
+	}
+}
+
+// timestampOrNil converts t, leaving zero times unset.
+func timestampOrNil(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
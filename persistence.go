@@ -0,0 +1,195 @@
+package connectplugin
+
+import (
+	"fmt"
+	"time"
+
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+)
+
+// StateStore persists host state (service registrations, namespace
+// assignments, runtime tokens and health) so a restarted host can restore it without plugins re-handshaking.
+//
+// Mutations are recorded as StateRecords. Implementations fold records into a
+// PersistedState and are free to compact them (see FileStateStore).
+type StateStore interface {
+	// Load returns the current persisted state.
+	// Returns an empty state if nothing has been persisted yet.
+	Load() (*PersistedState, error)
+
+	// Append durably records a single state mutation.
+	Append(rec StateRecord) error
+
+	// Close flushes and releases resources held by the store.
+	Close() error
+}
+
+// StateRecordKind identifies the type of mutation in a StateRecord.
+type StateRecordKind string
+
+const (
+	// RecordRegisterService records a service registration.
+	RecordRegisterService StateRecordKind = "register_service"
+
+	// RecordUnregisterService records removal of a registration.
+	RecordUnregisterService StateRecordKind = "unregister_service"
+
+	// RecordIssueToken records a runtime token issued during handshake.
+	RecordIssueToken StateRecordKind = "issue_token"
+
+	// RecordRevokeToken records removal of a runtime token (expiry or revocation).
+	RecordRevokeToken StateRecordKind = "revoke_token"
+
+	// RecordReportHealth records a plugin health report.
+	RecordReportHealth StateRecordKind = "report_health"
+
+	// RecordSetNamespace records a plugin's namespace assignment. An empty
+	// namespace removes it.
+	RecordSetNamespace StateRecordKind = "set_namespace"
+)
+
+// StateRecord is a single persisted state mutation.
+// Only the fields relevant to Kind are set.
+type StateRecord struct {
+	Kind StateRecordKind `json:"kind"`
+
+	// RuntimeID identifies the plugin for token, health and namespace records.
+	RuntimeID string `json:"runtime_id,omitempty"`
+
+	// RegistrationID identifies the registration for unregister records.
+	RegistrationID string `json:"registration_id,omitempty"`
+
+	// Provider is set for register_service records.
+	Provider *ServiceProvider `json:"provider,omitempty"`
+
+	// Token is set for issue_token records.
+	Token *PersistedToken `json:"token,omitempty"`
+
+	// Health is set for report_health records.
+	Health *PersistedHealth `json:"health,omitempty"`
+
+	// Namespace is set for set_namespace records.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PersistedToken is the durable form of a runtime token.
+// The token itself is stored, so state files must be protected (0600).
+type PersistedToken struct {
+	Token     string    `json:"token"`
+	SelfID    string    `json:"self_id,omitempty"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PersistedHealth is the durable form of a plugin's reported health.
+type PersistedHealth struct {
+	State                   connectpluginv1.HealthState `json:"state"`
+	Reason                  string                      `json:"reason,omitempty"`
+	UnavailableDependencies []string                    `json:"unavailable_dependencies,omitempty"`
+	ReportedAt              time.Time                   `json:"reported_at"`
+}
+
+// PersistedState is the materialized host state.
+type PersistedState struct {
+	// Registrations maps registration_id to provider.
+	Registrations map[string]*ServiceProvider `json:"registrations"`
+
+	// Tokens maps runtime_id to its runtime token and lease deadline.
+	Tokens map[string]*PersistedToken `json:"tokens"`
+
+	// Health maps runtime_id to its last reported health.
+	Health map[string]*PersistedHealth `json:"health"`
+
+	// Namespaces maps runtime_id to its namespace (default namespace omitted).
+	Namespaces map[string]string `json:"namespaces"`
+}
+
+// NewPersistedState creates an empty persisted state.
+func NewPersistedState() *PersistedState {
+	return &PersistedState{
+		Registrations: make(map[string]*ServiceProvider),
+		Tokens:        make(map[string]*PersistedToken),
+		Health:        make(map[string]*PersistedHealth),
+		Namespaces:    make(map[string]string),
+	}
+}
+
+// validate checks that the fields required by the record's kind are set.
+func (rec StateRecord) validate() error {
+	switch rec.Kind {
+	case RecordRegisterService:
+		if rec.Provider == nil {
+			return fmt.Errorf("%s record missing provider", rec.Kind)
+		}
+	case RecordIssueToken:
+		if rec.Token == nil {
+			return fmt.Errorf("%s record missing token", rec.Kind)
+		}
+	case RecordReportHealth:
+		if rec.Health == nil {
+			return fmt.Errorf("%s record missing health", rec.Kind)
+		}
+	case RecordUnregisterService, RecordRevokeToken, RecordSetNamespace:
+	default:
+		return fmt.Errorf("unknown state record kind %q", rec.Kind)
+	}
+	return nil
+}
+
+// Apply folds a record into the state.
+// Applying the same record twice is harmless, so replaying a log on top of a
+// snapshot that already contains some of its records is safe.
+func (s *PersistedState) Apply(rec StateRecord) error {
+	if err := rec.validate(); err != nil {
+		return err
+	}
+
+	switch rec.Kind {
+	case RecordRegisterService:
+		s.Registrations[rec.Provider.RegistrationID] = rec.Provider
+	case RecordUnregisterService:
+		delete(s.Registrations, rec.RegistrationID)
+	case RecordIssueToken:
+		s.Tokens[rec.RuntimeID] = rec.Token
+	case RecordRevokeToken:
+		delete(s.Tokens, rec.RuntimeID)
+	case RecordReportHealth:
+		s.Health[rec.RuntimeID] = rec.Health
+	case RecordSetNamespace:
+		if rec.Namespace == "" {
+			delete(s.Namespaces, rec.RuntimeID)
+		} else {
+			s.Namespaces[rec.RuntimeID] = rec.Namespace
+		}
+	}
+	return nil
+}
+
+// RestoreState loads persisted state from the store into the given servers and
+// attaches the store so subsequent mutations are persisted.
+// Any of the servers may be nil. Expired tokens are not restored.
+//
+// Router endpoints are not persisted: plugins added with Platform.AddPlugin
+// must be added again after a restart, while self-registered plugins are
+// routed via the base_url metadata stored with their registration.
+func RestoreState(store StateStore, handshake *HandshakeServer, registry *ServiceRegistry, lifecycle *LifecycleServer) error {
+	state, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load persisted state: %w", err)
+	}
+
+	if handshake != nil {
+		handshake.restore(state.Tokens)
+		handshake.SetStateStore(store)
+	}
+	if registry != nil {
+		registry.restore(state.Registrations, state.Namespaces)
+		registry.SetStateStore(store)
+	}
+	if lifecycle != nil {
+		lifecycle.restore(state.Health)
+		lifecycle.SetStateStore(store)
+	}
+
+	return nil
+}
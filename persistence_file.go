@@ -0,0 +1,342 @@
+package connectplugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileStateSnapshotName = "snapshot.json"
+	fileStateLogName      = "state.log"
+
+	// DefaultCompactThreshold is the number of log records after which
+	// FileStateStore writes a snapshot and truncates the log.
+	DefaultCompactThreshold = 1000
+)
+
+// FileStateStoreConfig configures a FileStateStore.
+type FileStateStoreConfig struct {
+	// Dir is the directory holding the snapshot and log files.
+	// Created if it does not exist. Required.
+	Dir string
+
+	// CompactThreshold is the number of appended records after which the
+	// store snapshots its state and truncates the log.
+	// Default: 1000 (DefaultCompactThreshold)
+	CompactThreshold int
+
+	// SnapshotInterval, if set, also snapshots on a timer.
+	// Default: 0 (count-based compaction only)
+	SnapshotInterval time.Duration
+
+	// NoSync disables fsync after each append.
+	// Faster, but the last records may be lost on power failure.
+	// Health reports are never synced individually; they reach disk with the
+	// next synced record or snapshot.
+	NoSync bool
+}
+
+// FileStateStore is a StateStore backed by an append-only log plus periodic
+// snapshots in a directory.
+//
+// On open, the snapshot is loaded and the log replayed on top of it. A
+// partially written final log line (crash mid-append) is ignored.
+type FileStateStore struct {
+	cfg FileStateStoreConfig
+
+	mu      sync.Mutex
+	state   *PersistedState
+	log     *os.File
+	pending int // records appended since last snapshot
+	closed  bool
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewFileStateStore opens (or creates) a file-based state store.
+func NewFileStateStore(cfg FileStateStoreConfig) (*FileStateStore, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("%w: FileStateStoreConfig.Dir is required", ErrInvalidConfig)
+	}
+	if cfg.CompactThreshold <= 0 {
+		cfg.CompactThreshold = DefaultCompactThreshold
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state dir: %w", err)
+	}
+
+	s := &FileStateStore{
+		cfg:    cfg,
+		state:  NewPersistedState(),
+		stopCh: make(chan struct{}),
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+
+	logFile, err := os.OpenFile(s.path(fileStateLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state log: %w", err)
+	}
+	s.log = logFile
+
+	if cfg.SnapshotInterval > 0 {
+		s.wg.Add(1)
+		go s.snapshotLoop()
+	}
+
+	return s, nil
+}
+
+// Load returns a copy of the current persisted state.
+func (s *FileStateStore) Load() (*PersistedState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Round-trip through JSON for a deep copy
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return nil, err
+	}
+	state := NewPersistedState()
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Append writes a record to the log and applies it to the in-memory state.
+func (s *FileStateStore) Append(rec StateRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("state store is closed")
+	}
+
+	// Reject invalid records before they reach the log, where they would
+	// fail every subsequent replay
+	if err := rec.validate(); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode state record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := s.log.Write(line); err != nil {
+		return fmt.Errorf("failed to write state log: %w", err)
+	}
+	// Health is soft state that the next report replaces, so it is not worth
+	// an fsync per report
+	if !s.cfg.NoSync && rec.Kind != RecordReportHealth {
+		if err := s.log.Sync(); err != nil {
+			return fmt.Errorf("failed to sync state log: %w", err)
+		}
+	}
+
+	if err := s.state.Apply(rec); err != nil {
+		return err
+	}
+
+	// The record is durable from here on: a failed compaction must not
+	// report it as lost. It is retried on the next append, tick or Close.
+	s.pending++
+	if s.pending >= s.cfg.CompactThreshold {
+		if err := s.snapshotLocked(); err != nil {
+			log.Printf("[PERSIST] Failed to compact state log: %v", err)
+		}
+	}
+	return nil
+}
+
+// Snapshot writes the current state to the snapshot file and truncates the log.
+func (s *FileStateStore) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("state store is closed")
+	}
+	return s.snapshotLocked()
+}
+
+// Close writes a final snapshot and closes the log.
+func (s *FileStateStore) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapErr := s.snapshotLocked()
+	s.closed = true
+	closeErr := s.log.Close()
+	return errors.Join(snapErr, closeErr)
+}
+
+// snapshotLocked atomically replaces the snapshot and truncates the log.
+// If the process dies between the rename and the truncate, the log is replayed
+// on top of the new snapshot on next open, which is safe (records are idempotent).
+// Caller must hold lock.
+func (s *FileStateStore) snapshotLocked() error {
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmpPath := s.path(fileStateSnapshotName + ".tmp")
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path(fileStateSnapshotName)); err != nil {
+		return fmt.Errorf("failed to install snapshot: %w", err)
+	}
+
+	if err := s.log.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate state log: %w", err)
+	}
+	s.pending = 0
+	return nil
+}
+
+// snapshotLoop snapshots on SnapshotInterval until Close.
+func (s *FileStateStore) snapshotLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.pending > 0 {
+				_ = s.snapshotLocked() // Retried on next tick or Close
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// loadSnapshot reads the snapshot file, if present.
+func (s *FileStateStore) loadSnapshot() error {
+	data, err := os.ReadFile(s.path(fileStateSnapshotName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	state := NewPersistedState()
+	if err := json.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	// Maps may be missing from older or hand-written snapshots
+	if state.Registrations == nil {
+		state.Registrations = make(map[string]*ServiceProvider)
+	}
+	if state.Tokens == nil {
+		state.Tokens = make(map[string]*PersistedToken)
+	}
+	if state.Health == nil {
+		state.Health = make(map[string]*PersistedHealth)
+	}
+	if state.Namespaces == nil {
+		state.Namespaces = make(map[string]string)
+	}
+
+	s.state = state
+	return nil
+}
+
+// replayLog applies log records on top of the loaded snapshot.
+func (s *FileStateStore) replayLog() error {
+	f, err := os.Open(s.path(fileStateLogName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open state log: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var complete int64 // offset after the last complete record
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) == 0 {
+				return nil
+			}
+			// A trailing line without newline is a torn write - drop it so
+			// the next record isn't appended to the fragment
+			if err := os.Truncate(s.path(fileStateLogName), complete); err != nil {
+				return fmt.Errorf("failed to truncate torn state log record: %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read state log: %w", err)
+		}
+		complete += int64(len(line))
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec StateRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("corrupt state log record: %w", err)
+		}
+		if err := s.state.Apply(rec); err != nil {
+			return fmt.Errorf("failed to replay state log: %w", err)
+		}
+		s.pending++
+	}
+}
+
+func (s *FileStateStore) path(name string) string {
+	return filepath.Join(s.cfg.Dir, name)
+}
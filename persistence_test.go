@@ -0,0 +1,339 @@
+package connectplugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+)
+
+func TestFileStateStore_AppendAndReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+
+	provider := &ServiceProvider{
+		RegistrationID: "reg-1",
+		RuntimeID:      "logger-a-x7k2",
+		ServiceType:    "logger",
+		Version:        "1.0.0",
+		EndpointPath:   "/logger.v1.Logger/",
+		RegisteredAt:   time.Now(),
+	}
+	records := []StateRecord{
+		{Kind: RecordRegisterService, Provider: provider},
+		{Kind: RecordIssueToken, RuntimeID: "logger-a-x7k2", Token: &PersistedToken{Token: "tok", ExpiresAt: time.Now().Add(time.Hour)}},
+		{Kind: RecordReportHealth, RuntimeID: "logger-a-x7k2", Health: &PersistedHealth{State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY}},
+	}
+	for _, rec := range records {
+		if err := store.Append(rec); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	// Simulate a crash: reopen without Close, so state comes from the log only
+	store.log.Close()
+
+	reopened, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	state, err := reopened.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if got := state.Registrations["reg-1"]; got == nil || got.ServiceType != "logger" {
+		t.Errorf("Expected logger registration to be restored, got %+v", got)
+	}
+	if got := state.Tokens["logger-a-x7k2"]; got == nil || got.Token != "tok" {
+		t.Errorf("Expected token to be restored, got %+v", got)
+	}
+	if got := state.Health["logger-a-x7k2"]; got == nil || got.State != connectpluginv1.HealthState_HEALTH_STATE_HEALTHY {
+		t.Errorf("Expected health to be restored, got %+v", got)
+	}
+}
+
+func TestFileStateStore_RejectsInvalidRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+
+	if err := store.Append(StateRecord{Kind: RecordRegisterService}); err == nil {
+		t.Fatal("Expected Append to reject a register record without a provider")
+	}
+	if err := store.Append(StateRecord{Kind: "bogus"}); err == nil {
+		t.Fatal("Expected Append to reject an unknown record kind")
+	}
+	store.log.Close()
+
+	// Rejected records must not reach the log, or every reopen would fail
+	data, err := os.ReadFile(filepath.Join(dir, fileStateLogName))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("Expected empty log, got %q", data)
+	}
+
+	reopened, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	reopened.Close()
+}
+
+func TestFileStateStore_Compaction(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, CompactThreshold: 3, NoSync: true})
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		store.Append(StateRecord{Kind: RecordIssueToken, RuntimeID: id, Token: &PersistedToken{Token: id}})
+	}
+	store.Append(StateRecord{Kind: RecordRevokeToken, RuntimeID: "a"})
+
+	// Threshold hit after 3 records: snapshot written, log holds only the last 2
+	if _, err := os.Stat(filepath.Join(dir, fileStateSnapshotName)); err != nil {
+		t.Fatalf("Expected snapshot file: %v", err)
+	}
+	if store.pending != 2 {
+		t.Errorf("Expected 2 pending log records after compaction, got %d", store.pending)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	state, _ := reopened.Load()
+	if len(state.Tokens) != 3 {
+		t.Errorf("Expected 3 tokens, got %d", len(state.Tokens))
+	}
+	if _, ok := state.Tokens["a"]; ok {
+		t.Error("Expected revoked token to stay removed")
+	}
+}
+
+func TestFileStateStore_CompactionFailureKeepsAppend(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, CompactThreshold: 1, NoSync: true})
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+	defer store.Close()
+
+	// A directory in the way of the temporary snapshot makes compaction fail
+	blocker := filepath.Join(dir, fileStateSnapshotName+".tmp")
+	if err := os.Mkdir(blocker, 0o700); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	rec := StateRecord{Kind: RecordIssueToken, RuntimeID: "a", Token: &PersistedToken{Token: "a"}}
+	if err := store.Append(rec); err != nil {
+		t.Fatalf("Expected append to succeed despite failed compaction, got %v", err)
+	}
+	if store.pending != 1 {
+		t.Errorf("Expected the record still pending compaction, got %d", store.pending)
+	}
+	if state, _ := store.Load(); state.Tokens["a"] == nil {
+		t.Error("Expected the record applied")
+	}
+
+	// The next append retries the snapshot
+	os.Remove(blocker)
+	store.Append(StateRecord{Kind: RecordRevokeToken, RuntimeID: "b"})
+	if store.pending != 0 {
+		t.Errorf("Expected compaction retried on the next append, got %d pending", store.pending)
+	}
+}
+
+func TestFileStateStore_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _ := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	store.Append(StateRecord{Kind: RecordIssueToken, RuntimeID: "a", Token: &PersistedToken{Token: "a"}})
+	store.log.Close()
+
+	// Partial record without trailing newline (crash mid-append)
+	f, _ := os.OpenFile(filepath.Join(dir, fileStateLogName), os.O_APPEND|os.O_WRONLY, 0o600)
+	f.WriteString(`{"kind":"issue_token","runtime_id":"b"`)
+	f.Close()
+
+	reopened, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	if err != nil {
+		t.Fatalf("Expected torn final record to be ignored, got: %v", err)
+	}
+
+	state, _ := reopened.Load()
+	if len(state.Tokens) != 1 {
+		t.Errorf("Expected 1 token, got %d", len(state.Tokens))
+	}
+
+	// Records appended after recovery must not merge with the torn fragment
+	if err := reopened.Append(StateRecord{Kind: RecordIssueToken, RuntimeID: "c", Token: &PersistedToken{Token: "c"}}); err != nil {
+		t.Fatalf("Append after recovery failed: %v", err)
+	}
+	reopened.Close()
+
+	again, err := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	if err != nil {
+		t.Fatalf("Second reopen after torn write failed: %v", err)
+	}
+	defer again.Close()
+
+	state, _ = again.Load()
+	if len(state.Tokens) != 2 || state.Tokens["a"] == nil || state.Tokens["c"] == nil {
+		t.Errorf("Expected tokens a and c, got %v", state.Tokens)
+	}
+}
+
+func TestRestoreState_HostRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	// First host instance
+	store, _ := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	if err := RestoreState(store, handshake, registry, lifecycle); err != nil {
+		t.Fatalf("RestoreState failed: %v", err)
+	}
+
+	hsResp, err := handshake.Handshake(context.Background(), connect.NewRequest(&connectpluginv1.HandshakeRequest{
+		CoreProtocolVersion: 1,
+		AppProtocolVersion:  1,
+		MagicCookieKey:      DefaultMagicCookieKey,
+		MagicCookieValue:    DefaultMagicCookieValue,
+		SelfId:              "logger-plugin",
+	}))
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	runtimeID := hsResp.Msg.RuntimeId
+	token := hsResp.Msg.RuntimeToken
+
+	regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+		ServiceType:  "logger",
+		Version:      "1.0.0",
+		EndpointPath: "/logger.v1.Logger/",
+	})
+	if _, err := registry.RegisterService(runtimeContext(runtimeID), regReq); err != nil {
+		t.Fatalf("RegisterService failed: %v", err)
+	}
+
+	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State: connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+	})
+	lifecycle.ReportHealth(runtimeContext(runtimeID), healthReq)
+
+	store.Close()
+
+	// Second host instance restores from the same directory
+	store2, _ := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	defer store2.Close()
+	handshake2 := NewHandshakeServer(&ServeConfig{})
+	lifecycle2 := NewLifecycleServer()
+	registry2 := NewServiceRegistry(lifecycle2)
+	if err := RestoreState(store2, handshake2, registry2, lifecycle2); err != nil {
+		t.Fatalf("RestoreState failed: %v", err)
+	}
+
+	if !handshake2.ValidateToken(runtimeID, token) {
+		t.Error("Expected runtime token to remain valid after restart")
+	}
+	if providers := registry2.GetAllProviders("logger"); len(providers) != 1 || providers[0].RuntimeID != runtimeID {
+		t.Errorf("Expected logger registration to be restored, got %v", providers)
+	}
+	if lifecycle2.ShouldRouteTraffic(runtimeID) {
+		t.Error("Expected restored UNHEALTHY state to block traffic")
+	}
+}
+
+func TestRestoreState_Namespaces(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _ := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	registry := NewServiceRegistry(nil)
+	if err := RestoreState(store, nil, registry, nil); err != nil {
+		t.Fatalf("RestoreState failed: %v", err)
+	}
+	registry.SetNamespace("logger-a", "tenant-a")
+	registry.SetNamespace("cache-a", "tenant-a")
+	req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+		ServiceType:  "logger",
+		Version:      "1.0.0",
+		EndpointPath: "/logger.v1.Logger/",
+	})
+	if _, err := registry.RegisterService(runtimeContext("logger-a"), req); err != nil {
+		t.Fatalf("RegisterService failed: %v", err)
+	}
+
+	// Clearing an assignment (plugin removed) is persisted too
+	registry.SetNamespace("cache-a", "")
+	store.Close()
+
+	store2, _ := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	defer store2.Close()
+	registry2 := NewServiceRegistry(nil)
+	if err := RestoreState(store2, nil, registry2, nil); err != nil {
+		t.Fatalf("RestoreState failed: %v", err)
+	}
+
+	if ns := registry2.Namespace("logger-a"); ns != "tenant-a" {
+		t.Errorf("Expected logger-a in tenant-a after restart, got %q", ns)
+	}
+	if ns := registry2.Namespace("cache-a"); ns != "" {
+		t.Errorf("Expected cache-a assignment cleared, got %q", ns)
+	}
+	if providers := registry2.GetAllProvidersInNamespace("tenant-a", "logger"); len(providers) != 1 {
+		t.Errorf("Expected logger restored in tenant-a, got %v", providers)
+	}
+
+	// The plugin's later calls stay scoped to its namespace
+	resp, err := registry2.DiscoverService(runtimeContext("logger-a"), connect.NewRequest(&connectpluginv1.DiscoverServiceRequest{
+		ServiceType: "logger",
+	}))
+	if err != nil || resp.Msg.Endpoint.ProviderId != "logger-a" {
+		t.Errorf("Expected logger-a discovered in tenant-a, got %v, %v", resp, err)
+	}
+}
+
+func TestRestoreState_SkipsExpiredTokens(t *testing.T) {
+	store, _ := NewFileStateStore(FileStateStoreConfig{Dir: t.TempDir(), NoSync: true})
+	defer store.Close()
+
+	store.Append(StateRecord{
+		Kind:      RecordIssueToken,
+		RuntimeID: "expired-plugin",
+		Token:     &PersistedToken{Token: "old", ExpiresAt: time.Now().Add(-time.Minute)},
+	})
+
+	handshake := NewHandshakeServer(&ServeConfig{})
+	RestoreState(store, handshake, nil, nil)
+
+	if handshake.ValidateToken("expired-plugin", "old") {
+		t.Error("Expected expired token not to be restored")
+	}
+}
@@ -3,33 +3,65 @@ package connectplugin
 import (
 	"context"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
 	"github.com/masegraye/connect-plugin-go/internal/depgraph"
 )
 
 // Platform manages the lifecycle of plugins in a multi-plugin environment.
 // It coordinates the dependency graph, service registry, and health tracking.
+// It is safe for concurrent use; operations on different plugins run in
+// parallel, while concurrent removals or replacements of the same plugin fail.
 type Platform struct {
 	depGraph        *depgraph.Graph
 	registry        *ServiceRegistry
 	lifecycleServer *LifecycleServer
 	router          *ServiceRouter
 
+	mu sync.RWMutex
+
 	// Plugin instances
 	plugins map[string]*PluginInstance
+
+	// busy marks plugins with a removal or replacement in progress
+	busy map[string]bool
+
+	// listeners are notified of plugin lifecycle events
+	listeners []func(PlatformEvent)
+
+	// healthChanged is closed and replaced on every health change
+	healthChanged chan struct{}
+
+	// stopped holds dependents stopped by RemovePlugin until the services
+	// they require are available again
+	stopped map[string]*stoppedPlugin
+
+	// degraded maps runtime_id to the services a dependent marked DEGRADED
+	// by RemovePlugin waits for
+	degraded map[string][]string
+
+	// restarting is set while stopped dependents are being restarted
+	restarting bool
 }
 
 // PluginInstance represents a running plugin.
 type PluginInstance struct {
 	RuntimeID string
 	SelfID    string
+	Namespace string // Registry namespace ("" = default)
 	Metadata  PluginMetadata
 	Endpoint  string // Internal endpoint (e.g., "http://localhost:8081")
 	Token     string // Runtime token for this plugin
 
 	// Control client for calling plugin's PluginControl service
 	control *PluginControlClient
+
+	// config is the configuration the plugin was added with
+	config PluginConfig
 }
 
 // PluginConfig is the configuration for adding a plugin to the platform.
@@ -43,8 +75,98 @@ type PluginConfig struct {
 	// Endpoint is the plugin's internal HTTP endpoint
 	Endpoint string
 
+	// Namespace isolates the plugin's services from other tenants.
+	// Empty uses the default namespace.
+	Namespace string
+
 	// Metadata includes service declarations
 	Metadata PluginMetadata
+
+	// HostSecret is the secret provisioned to the plugin at launch. It is
+	// presented on SetRuntimeIdentity and control calls (Shutdown, Drain,
+	// ...) so the plugin can tell the host from other callers.
+	// Empty sends no secret.
+	HostSecret string
+}
+
+// RemoveOptions configures RemovePluginWithOptions.
+type RemoveOptions struct {
+	// DrainTimeout bounds how long the plugin's in-flight calls, including
+	// streams, may take to finish before it is shut down.
+	// Default: 30s
+	DrainTimeout time.Duration
+
+	// Dependents decides what happens to plugins that require a service
+	// only this plugin provides.
+	// Default: DependentsIgnore
+	Dependents DependentPolicy
+}
+
+func (o RemoveOptions) withDefaults() RemoveOptions {
+	if o.DrainTimeout <= 0 {
+		o.DrainTimeout = 30 * time.Second
+	}
+	return o
+}
+
+// RemoveResult reports how a plugin was removed.
+type RemoveResult struct {
+	// Drain reports how the plugin's in-flight calls were drained.
+	Drain DrainResult
+
+	// Impact is the impact analysis the removal acted on.
+	Impact *depgraph.ImpactAnalysis
+
+	// Stopped lists the dependents stopped (DependentsStop).
+	Stopped []string
+
+	// Degraded lists the dependents marked DEGRADED (DependentsDegrade).
+	Degraded []string
+}
+
+// ReplaceOptions configures ReplacePluginWithOptions.
+type ReplaceOptions struct {
+	// HealthTimeout bounds how long the new version may take to become
+	// healthy.
+	// Default: 30s
+	HealthTimeout time.Duration
+
+	// DrainTimeout bounds how long the old version's in-flight calls may
+	// take to finish before it is shut down.
+	// Default: 30s
+	DrainTimeout time.Duration
+
+	// BakePeriod is how long the new version must stay healthy after the
+	// switch before the old version is shut down. If it becomes unhealthy
+	// sooner, the old version is restored. Negative skips verification.
+	// Default: 10s
+	BakePeriod time.Duration
+}
+
+func (o ReplaceOptions) withDefaults() ReplaceOptions {
+	if o.HealthTimeout <= 0 {
+		o.HealthTimeout = 30 * time.Second
+	}
+	if o.DrainTimeout <= 0 {
+		o.DrainTimeout = 30 * time.Second
+	}
+	if o.BakePeriod == 0 {
+		o.BakePeriod = 10 * time.Second
+	}
+	return o
+}
+
+// ReplaceResult reports how a plugin was replaced.
+type ReplaceResult struct {
+	// RuntimeID is the runtime ID of the new version.
+	RuntimeID string
+
+	// Drain reports how the old version's in-flight calls were drained.
+	Drain DrainResult
+
+	// RolledBack is true if the new version failed verification after the
+	// switch and the old version was restored.
+	RolledBack bool
 }
 
 // NewPlatform creates a new platform instance.
@@ -53,13 +175,21 @@ func NewPlatform(
 	lifecycle *LifecycleServer,
 	router *ServiceRouter,
 ) *Platform {
-	return &Platform{
+	p := &Platform{
 		depGraph:        depgraph.New(),
 		registry:        registry,
 		lifecycleServer: lifecycle,
 		router:          router,
 		plugins:         make(map[string]*PluginInstance),
+		busy:            make(map[string]bool),
+		healthChanged:   make(chan struct{}),
+		stopped:         make(map[string]*stoppedPlugin),
+		degraded:        make(map[string][]string),
 	}
+	if lifecycle != nil {
+		lifecycle.OnHealthChange(p.handleHealthChange)
+	}
+	return p
 }
 
 // Registry returns the service registry.
@@ -77,42 +207,80 @@ func (p *Platform) Router() *ServiceRouter {
 	return p.router
 }
 
+// GetPlugin returns a copy of a plugin instance, or nil if not found.
+func (p *Platform) GetPlugin(runtimeID string) *PluginInstance {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	instance, ok := p.plugins[runtimeID]
+	if !ok {
+		return nil
+	}
+	copied := *instance
+	return &copied
+}
+
+// Plugins returns copies of all plugin instances.
+func (p *Platform) Plugins() []*PluginInstance {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	instances := make([]*PluginInstance, 0, len(p.plugins))
+	for _, instance := range p.plugins {
+		copied := *instance
+		instances = append(instances, &copied)
+	}
+	return instances
+}
+
+// beginOp marks a plugin busy for a removal or replacement.
+// Returns the plugin instance, or an error if it is unknown or busy.
+func (p *Platform) beginOp(runtimeID string) (*PluginInstance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	instance, ok := p.plugins[runtimeID]
+	if !ok {
+		return nil, fmt.Errorf("plugin not found: %s", runtimeID)
+	}
+	if p.busy[runtimeID] {
+		return nil, fmt.Errorf("plugin %s: another operation is in progress", runtimeID)
+	}
+	p.busy[runtimeID] = true
+	return instance, nil
+}
+
+// endOp clears the busy mark set by beginOp.
+func (p *Platform) endOp(runtimeID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.busy, runtimeID)
+}
+
 // AddPlugin adds a plugin to the platform at runtime (managed deployment).
 // The platform calls the plugin's PluginIdentity service to coordinate registration.
-func (p *Platform) AddPlugin(ctx context.Context, config PluginConfig) error {
+func (p *Platform) AddPlugin(ctx context.Context, config PluginConfig) (err error) {
+	selfID, runtimeID := config.SelfID, ""
+	defer func() {
+		if err != nil {
+			p.emit(PlatformEvent{Type: PluginFailed, RuntimeID: runtimeID, SelfID: selfID, Err: err})
+		}
+	}()
+
 	// 1. Call plugin's GetPluginInfo() to retrieve metadata
 	// This is the bidirectional handshake for Model A
-	infoClient := NewPluginIdentityClient(config.Endpoint, nil)
+	infoClient := NewPluginIdentityClient(config.Endpoint, nil, WithHostSecret(config.HostSecret))
 	infoResp, err := infoClient.GetPluginInfo(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get plugin info: %w", err)
 	}
 
 	// Use metadata from plugin response (trust the plugin's declarations)
-	selfID := infoResp.SelfId
-	if selfID == "" {
-		selfID = config.SelfID // Fallback to config
-	}
-
-	// Convert proto types to internal types
-	provides := make([]ServiceDeclaration, len(infoResp.Provides))
-	for i, p := range infoResp.Provides {
-		provides[i] = ServiceDeclaration{
-			Type:    p.Type,
-			Version: p.Version,
-			Path:    p.Path,
-		}
-	}
-
-	requires := make([]ServiceDependency, len(infoResp.Requires))
-	for i, r := range infoResp.Requires {
-		requires[i] = ServiceDependency{
-			Type:               r.Type,
-			MinVersion:         r.MinVersion,
-			RequiredForStartup: r.RequiredForStartup,
-			WatchForChanges:    r.WatchForChanges,
-		}
+	if infoResp.SelfId != "" {
+		selfID = infoResp.SelfId // config.SelfID is the fallback
 	}
+	metadata := metadataFromPluginInfo(infoResp)
+	provides, requires := metadata.Provides, metadata.Requires
 
 	// 2. Validate dependencies are available
 	for _, dep := range requires {
@@ -123,7 +291,7 @@ func (p *Platform) AddPlugin(ctx context.Context, config PluginConfig) error {
 	}
 
 	// 3. Generate runtime identity
-	runtimeID, err := generateRuntimeID(selfID)
+	runtimeID, err = generateRuntimeID(selfID)
 	if err != nil {
 		return fmt.Errorf("failed to generate runtime ID: %w", err)
 	}
@@ -132,8 +300,22 @@ func (p *Platform) AddPlugin(ctx context.Context, config PluginConfig) error {
 		return fmt.Errorf("failed to generate runtime token: %w", err)
 	}
 
+	// Scope the plugin's services to its namespace before it registers any
+	if err := p.registry.SetNamespace(runtimeID, config.Namespace); err != nil {
+		return fmt.Errorf("plugin %q: %w", selfID, err)
+	}
+
+	// Register the token with the host so the plugin can authenticate
+	// its registry and lifecycle calls
+	if p.router != nil && p.router.handshakeServer != nil {
+		if err := p.router.handshakeServer.issueToken(runtimeID, selfID, runtimeToken); err != nil {
+			return fmt.Errorf("failed to issue runtime token: %w", err)
+		}
+	}
+
 	// 4. Call plugin's SetRuntimeIdentity() to assign identity
 	if err := infoClient.SetRuntimeIdentity(ctx, runtimeID, runtimeToken, ""); err != nil {
+		p.releaseIdentity(runtimeID)
 		return fmt.Errorf("failed to set runtime identity: %w", err)
 	}
 
@@ -141,15 +323,12 @@ func (p *Platform) AddPlugin(ctx context.Context, config PluginConfig) error {
 	instance := &PluginInstance{
 		RuntimeID: runtimeID,
 		SelfID:    selfID,
-		Metadata: PluginMetadata{
-			Name:     infoResp.Metadata["name"],
-			Version:  infoResp.Metadata["version"],
-			Provides: provides,
-			Requires: requires,
-		},
-		Endpoint: config.Endpoint,
-		Token:    runtimeToken,
-		control:  NewPluginControlClient(config.Endpoint, nil),
+		Namespace: config.Namespace,
+		Metadata:  metadata,
+		Endpoint:  config.Endpoint,
+		Token:     runtimeToken,
+		control:   NewPluginControlClient(config.Endpoint, nil, WithHostSecret(config.HostSecret)),
+		config:    config,
 	}
 
 	// 6. Add to dependency graph
@@ -175,11 +354,13 @@ func (p *Platform) AddPlugin(ctx context.Context, config PluginConfig) error {
 	}
 
 	p.depGraph.Add(depNode)
+	p.router.SetCallerDependencies(runtimeID, requiredServiceTypes(requires))
+	p.emit(PlatformEvent{Type: PluginAdded, RuntimeID: runtimeID, SelfID: selfID})
 
 	// 7. Wait for plugin to register services and become healthy
 	// Plugin should call RegisterService() and ReportHealth() using the assigned runtime_id
 	if err := p.waitForHealthy(ctx, runtimeID, 30*time.Second); err != nil {
-		p.depGraph.Remove(runtimeID)
+		p.abandon(ctx, instance, "plugin did not become healthy")
 		return fmt.Errorf("plugin %q did not become healthy: %w", selfID, err)
 	}
 
@@ -187,90 +368,265 @@ func (p *Platform) AddPlugin(ctx context.Context, config PluginConfig) error {
 	p.router.RegisterPluginEndpoint(runtimeID, config.Endpoint)
 
 	// 9. Store plugin instance
+	p.mu.Lock()
 	p.plugins[runtimeID] = instance
+	p.mu.Unlock()
+
+	p.emit(PlatformEvent{Type: PluginHealthy, RuntimeID: runtimeID, SelfID: selfID})
 
+	// Its services may be what stopped or degraded dependents wait for
+	p.resumeDependents()
 	return nil
 }
 
-// RemovePlugin removes a plugin from the platform.
-// Analyzes impact and notifies dependent plugins before removal.
+// RemovePlugin removes a plugin from the platform with default options.
+// See RemovePluginWithOptions.
 func (p *Platform) RemovePlugin(ctx context.Context, runtimeID string) error {
-	instance, ok := p.plugins[runtimeID]
-	if !ok {
-		return fmt.Errorf("plugin not found: %s", runtimeID)
+	_, err := p.RemovePluginWithOptions(ctx, runtimeID, RemoveOptions{})
+	return err
+}
+
+// RemovePluginWithOptions removes a plugin from the platform. Its services
+// are unregistered first, so watchers see them go away and the router stops
+// sending it new calls. In-flight calls are then drained, bounded by
+// DrainTimeout, before the plugin is asked to shut down.
+//
+// Plugins that require a service only this plugin provides are handled
+// according to opts.Dependents before the plugin is removed.
+func (p *Platform) RemovePluginWithOptions(ctx context.Context, runtimeID string, opts RemoveOptions) (*RemoveResult, error) {
+	opts = opts.withDefaults()
+
+	instance, err := p.beginOp(runtimeID)
+	if err != nil {
+		return nil, err
 	}
+	defer p.endOp(runtimeID)
 
-	// 1. Compute impact
+	// 1. Compute impact and apply the dependents policy
 	impact := p.depGraph.GetImpact(runtimeID)
+	result := &RemoveResult{Impact: impact}
+	if len(impact.AffectedPlugins) > 0 {
+		switch opts.Dependents {
+		case DependentsRefuse:
+			return nil, fmt.Errorf("plugin %s is required by %s",
+				runtimeID, strings.Join(impact.AffectedPlugins, ", "))
+		case DependentsStop:
+			result.Stopped = p.stopDependents(ctx, instance, impact, opts.DrainTimeout)
+		case DependentsDegrade:
+			result.Degraded = p.degradeDependents(instance, impact)
+		}
+	}
+
+	// 2. Unregister services, drain and shut down the plugin
+	result.Drain = p.takeDown(ctx, instance, opts.DrainTimeout, func(control *PluginControlClient) {
+		if _, err := control.Shutdown(ctx, 30, "plugin removed"); err != nil {
+			// Log error but continue with removal
+		}
+	})
 
-	// 2. Notify watchers that services are going away
-	// Plugins watching these services will receive UNAVAILABLE events when we unregister
-	_ = impact.AffectedServices // Services will be unregistered below
+	p.emit(PlatformEvent{Type: PluginRemoved, RuntimeID: runtimeID, SelfID: instance.SelfID, Drain: &result.Drain})
+	return result, nil
+}
 
-	// 3. Grace period for plugins to adapt (5 seconds)
-	time.Sleep(5 * time.Second)
+// takeDown unregisters a plugin's services so watchers are notified and no
+// new calls reach it, drains its in-flight calls, lets halt stop the plugin
+// itself, and removes it from the platform.
+func (p *Platform) takeDown(ctx context.Context, instance *PluginInstance, drainTimeout time.Duration, halt func(*PluginControlClient)) DrainResult {
+	drain := p.withdraw(ctx, instance.RuntimeID, drainTimeout)
+	p.discard(instance, halt)
+	return drain
+}
 
-	// 4. Unregister all services from this plugin
+// withdraw unregisters a plugin's services and drains its in-flight calls.
+// The plugin keeps running and can be brought back (see ReplacePlugin).
+func (p *Platform) withdraw(ctx context.Context, runtimeID string, drainTimeout time.Duration) DrainResult {
 	p.registry.UnregisterPluginServices(runtimeID)
+	return p.drain(ctx, runtimeID, drainTimeout)
+}
 
-	// 5. Request graceful shutdown
+// discard lets halt stop a withdrawn plugin, releases its runtime identity
+// and removes it from the platform.
+func (p *Platform) discard(instance *PluginInstance, halt func(*PluginControlClient)) {
+	runtimeID := instance.RuntimeID
 	if instance.control != nil {
-		_, err := instance.control.Shutdown(ctx, 30, "plugin removed")
-		if err != nil {
-			// Log error but continue with removal
-		}
+		halt(instance.control)
 	}
 
-	// 6. Remove from dependency graph
 	p.depGraph.Remove(runtimeID)
+	p.router.SetCallerDependencies(runtimeID, nil)
+	p.router.UnregisterPluginEndpoint(runtimeID)
+	_ = p.SetCanary(runtimeID, 0)
+	p.lifecycleServer.ClearDegraded(runtimeID)
+	p.releaseIdentity(runtimeID)
 
-	// 7. Remove from plugins map
+	p.mu.Lock()
 	delete(p.plugins, runtimeID)
+	delete(p.degraded, runtimeID)
+	p.mu.Unlock()
+}
 
-	return nil
+// abandon takes down a plugin that failed to start, including any services
+// it registered, and shuts it down.
+func (p *Platform) abandon(ctx context.Context, instance *PluginInstance, reason string) {
+	// Clean up even if the caller gave up
+	ctx = context.WithoutCancel(ctx)
+
+	p.takeDown(ctx, instance, 0, func(control *PluginControlClient) {
+		control.Shutdown(ctx, 10, reason)
+	})
 }
 
-// ReplacePlugin replaces a plugin with a new version (hot reload).
-// Uses blue-green deployment: start new, switch routes, stop old.
-func (p *Platform) ReplacePlugin(ctx context.Context, runtimeID string, newConfig PluginConfig) error {
-	oldInstance, ok := p.plugins[runtimeID]
+// releaseIdentity revokes a plugin's runtime token, so it can no longer call
+// the host, and its namespace assignment.
+func (p *Platform) releaseIdentity(runtimeID string) {
+	if p.router != nil && p.router.handshakeServer != nil {
+		p.router.handshakeServer.revokeToken(runtimeID)
+	}
+	_ = p.registry.SetNamespace(runtimeID, "")
+}
+
+// SetCanary routes percent of the traffic for each service type the plugin
+// provides to it, leaving the rest to the other providers. Use it to replace
+// a plugin progressively: AddPlugin the new version, raise the percentage in
+// steps, then RemovePlugin the old version. A percent of 0 removes the split.
+// Splits for other plugins and shadow settings are kept.
+func (p *Platform) SetCanary(runtimeID string, percent int) error {
+	// Hold the lock across the policy read-modify-write so concurrent
+	// canary changes for the same service type don't lose splits
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	instance, ok := p.plugins[runtimeID]
 	if !ok {
 		return fmt.Errorf("plugin not found: %s", runtimeID)
 	}
 
-	// 1. Start new version in parallel
-	newRuntimeID, err := generateRuntimeID(newConfig.SelfID)
+	for _, svc := range instance.Metadata.Provides {
+		policy := &TrafficPolicy{}
+		if existing := p.registry.TrafficPolicyInNamespace(instance.Namespace, svc.Type); existing != nil {
+			policy.Shadow = existing.Shadow
+			for _, split := range existing.Splits {
+				if split.RuntimeID != runtimeID {
+					policy.Splits = append(policy.Splits, split)
+				}
+			}
+		}
+		if percent > 0 {
+			policy.Splits = append(policy.Splits, TrafficSplit{RuntimeID: runtimeID, Percent: percent})
+		}
+
+		if len(policy.Splits) == 0 && policy.Shadow == nil {
+			policy = nil
+		}
+		if err := p.registry.SetTrafficPolicyInNamespace(instance.Namespace, svc.Type, policy); err != nil {
+			return fmt.Errorf("service %q: %w", svc.Type, err)
+		}
+	}
+	return nil
+}
+
+// ReplacePlugin replaces a plugin with a new version (hot reload) with
+// default options. See ReplacePluginWithOptions.
+func (p *Platform) ReplacePlugin(ctx context.Context, runtimeID string, newConfig PluginConfig) error {
+	_, err := p.ReplacePluginWithOptions(ctx, runtimeID, newConfig, ReplaceOptions{})
+	return err
+}
+
+// ReplacePluginWithOptions replaces a plugin with a new version (hot reload).
+// Uses blue-green deployment: start new, switch routes, drain and stop old.
+//
+// The new version's declarations are fetched with GetPluginInfo and checked
+// against the old version before anything changes: it must still provide
+// every service the old version did, in versions its dependents accept, and
+// the services it requires must be available. After the switch the old
+// version is kept until the new one has stayed healthy for BakePeriod;
+// otherwise the switch is rolled back and the new version removed.
+func (p *Platform) ReplacePluginWithOptions(ctx context.Context, runtimeID string, newConfig PluginConfig, opts ReplaceOptions) (result *ReplaceResult, err error) {
+	opts = opts.withDefaults()
+
+	oldInstance, err := p.beginOp(runtimeID)
 	if err != nil {
-		return fmt.Errorf("failed to generate runtime ID: %w", err)
+		return nil, err
+	}
+	defer p.endOp(runtimeID)
+
+	selfID, newRuntimeID := newConfig.SelfID, ""
+	defer func() {
+		if err != nil {
+			p.emit(PlatformEvent{Type: PluginFailed, RuntimeID: newRuntimeID, SelfID: selfID, Err: err})
+		}
+	}()
+
+	// 1. Fetch the new version's declarations
+	infoClient := NewPluginIdentityClient(newConfig.Endpoint, nil, WithHostSecret(newConfig.HostSecret))
+	infoResp, err := infoClient.GetPluginInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plugin info: %w", err)
+	}
+	if infoResp.SelfId != "" {
+		selfID = infoResp.SelfId
+	}
+	metadata := metadataFromPluginInfo(infoResp)
+
+	// 2. Pre-flight compatibility checks
+	if err := p.checkReplacement(runtimeID, oldInstance, metadata); err != nil {
+		return nil, fmt.Errorf("new version of %s is not compatible: %w", runtimeID, err)
+	}
+
+	// 3. Assign the new version its runtime identity
+	newRuntimeID, err = generateRuntimeID(selfID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate runtime ID: %w", err)
 	}
 	newToken, err := generateToken()
 	if err != nil {
-		return fmt.Errorf("failed to generate token: %w", err)
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	// New version stays in the old namespace unless one is given
+	namespace := newConfig.Namespace
+	if namespace == "" {
+		namespace = oldInstance.Namespace
+	}
+	if err := p.registry.SetNamespace(newRuntimeID, namespace); err != nil {
+		return nil, fmt.Errorf("plugin %q: %w", selfID, err)
+	}
+	if p.router != nil && p.router.handshakeServer != nil {
+		if err := p.router.handshakeServer.issueToken(newRuntimeID, selfID, newToken); err != nil {
+			return nil, fmt.Errorf("failed to issue runtime token: %w", err)
+		}
+	}
+	if err := infoClient.SetRuntimeIdentity(ctx, newRuntimeID, newToken, ""); err != nil {
+		p.releaseIdentity(newRuntimeID)
+		return nil, fmt.Errorf("failed to set runtime identity: %w", err)
 	}
 
 	newInstance := &PluginInstance{
 		RuntimeID: newRuntimeID,
-		SelfID:    newConfig.SelfID,
-		Metadata:  newConfig.Metadata,
+		SelfID:    selfID,
+		Namespace: namespace,
+		Metadata:  metadata,
 		Endpoint:  newConfig.Endpoint,
 		Token:     newToken,
-		control:   NewPluginControlClient(newConfig.Endpoint, nil),
+		control:   NewPluginControlClient(newConfig.Endpoint, nil, WithHostSecret(newConfig.HostSecret)),
+		config:    newConfig,
 	}
+	newInstance.config.Namespace = namespace
 
-	// 2. Add to dependency graph
+	// 4. Add to dependency graph
 	newNode := &depgraph.Node{
 		RuntimeID: newRuntimeID,
-		SelfID:    newConfig.SelfID,
+		SelfID:    selfID,
 	}
 
-	for _, svc := range newConfig.Metadata.Provides {
+	for _, svc := range metadata.Provides {
 		newNode.Provides = append(newNode.Provides, depgraph.ServiceDeclaration{
 			Type:    svc.Type,
 			Version: svc.Version,
 		})
 	}
 
-	for _, dep := range newConfig.Metadata.Requires {
+	for _, dep := range metadata.Requires {
 		newNode.Requires = append(newNode.Requires, depgraph.ServiceDependency{
 			Type:               dep.Type,
 			MinVersion:         dep.MinVersion,
@@ -280,37 +636,50 @@ func (p *Platform) ReplacePlugin(ctx context.Context, runtimeID string, newConfi
 	}
 
 	p.depGraph.Add(newNode)
+	p.router.SetCallerDependencies(newRuntimeID, requiredServiceTypes(metadata.Requires))
 
-	// 3. Wait for new version to become healthy
-	if err := p.waitForHealthy(ctx, newRuntimeID, 30*time.Second); err != nil {
-		p.depGraph.Remove(newRuntimeID)
-		return fmt.Errorf("new version did not become healthy: %w", err)
+	// 5. Wait for new version to become healthy
+	if err := p.waitForHealthy(ctx, newRuntimeID, opts.HealthTimeout); err != nil {
+		p.abandon(ctx, newInstance, "new version did not become healthy")
+		return nil, fmt.Errorf("new version did not become healthy: %w", err)
 	}
 
-	// 4. Register new plugin endpoint in router
+	// 6. Register new plugin endpoint in router
 	p.router.RegisterPluginEndpoint(newRuntimeID, newConfig.Endpoint)
 
-	// 5. Atomic switch in registry
-	// TODO: Implement SwitchProvider in registry
-	// For now, services are already registered by the new plugin
-
-	// 6. Drain old version (finish in-flight requests)
-	p.drainPlugin(oldInstance, 30*time.Second)
-
-	// 7. Request shutdown of old version
-	if oldInstance.control != nil {
-		oldInstance.control.Shutdown(ctx, 10, "replaced with new version")
+	// 7. Switch traffic: the new version registered its services itself;
+	// unregistering the old ones stops routing new calls to it. The old
+	// version keeps running until the switch is verified
+	previous := p.registry.GetServicesBy(runtimeID)
+	drain := p.withdraw(ctx, runtimeID, opts.DrainTimeout)
+	result = &ReplaceResult{RuntimeID: newRuntimeID, Drain: drain}
+
+	// 8. Verify the new version for the bake period, or roll back
+	if err := p.verifyReplacement(ctx, newRuntimeID, opts.BakePeriod); err != nil {
+		p.rollbackReplacement(ctx, oldInstance, previous, newInstance, opts.DrainTimeout)
+		result.RolledBack = true
+		return result, fmt.Errorf("new version failed verification, rolled back to %s: %w", runtimeID, err)
 	}
 
-	// 8. Remove old version from graph and registry
-	p.registry.UnregisterPluginServices(runtimeID)
-	p.depGraph.Remove(runtimeID)
+	// 9. Shut down and remove old version
+	p.discard(oldInstance, func(control *PluginControlClient) {
+		control.Shutdown(ctx, 10, "replaced with new version")
+	})
 
-	// 9. Update plugins map
-	delete(p.plugins, runtimeID)
+	// 10. Update plugins map
+	p.mu.Lock()
 	p.plugins[newRuntimeID] = newInstance
-
-	return nil
+	p.mu.Unlock()
+
+	p.emit(PlatformEvent{
+		Type:              PluginReplaced,
+		RuntimeID:         newRuntimeID,
+		SelfID:            selfID,
+		PreviousRuntimeID: runtimeID,
+		Drain:             &drain,
+	})
+	p.resumeDependents()
+	return result, nil
 }
 
 // GetImpact returns the impact analysis for removing a plugin.
@@ -355,36 +724,82 @@ func (p *Platform) AddToDependencyGraph(
 	}
 
 	p.depGraph.Add(node)
+	p.router.SetCallerDependencies(runtimeID, requiredServiceTypes(requires))
+	p.resumeDependents()
+}
+
+// metadataFromPluginInfo converts a plugin's GetPluginInfo declarations.
+func metadataFromPluginInfo(info *connectpluginv1.GetPluginInfoResponse) PluginMetadata {
+	metadata := PluginMetadata{
+		Name:     info.Metadata["name"],
+		Version:  info.Metadata["version"],
+		Provides: make([]ServiceDeclaration, len(info.Provides)),
+		Requires: make([]ServiceDependency, len(info.Requires)),
+	}
+	for i, p := range info.Provides {
+		metadata.Provides[i] = ServiceDeclaration{
+			Type:    p.Type,
+			Version: p.Version,
+			Path:    p.Path,
+		}
+	}
+	for i, r := range info.Requires {
+		metadata.Requires[i] = ServiceDependency{
+			Type:               r.Type,
+			MinVersion:         r.MinVersion,
+			RequiredForStartup: r.RequiredForStartup,
+			WatchForChanges:    r.WatchForChanges,
+		}
+	}
+	return metadata
 }
 
-// waitForHealthy waits for a plugin to report healthy state.
+// requiredServiceTypes returns the service types a plugin declared it requires.
+func requiredServiceTypes(requires []ServiceDependency) []string {
+	types := make([]string, 0, len(requires))
+	for _, dep := range requires {
+		types = append(types, dep.Type)
+	}
+	return types
+}
+
+// waitForHealthy waits for a plugin to report healthy state. It wakes on
+// health changes rather than polling.
 func (p *Platform) waitForHealthy(ctx context.Context, runtimeID string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
 	for {
+		// Take the wakeup channel before checking so no change is missed
+		p.mu.RLock()
+		changed := p.healthChanged
+		p.mu.RUnlock()
+
+		state := p.lifecycleServer.GetHealthState(runtimeID)
+		if state != nil && state.State == connectpluginv1.HealthState_HEALTH_STATE_HEALTHY {
+			return nil
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-
-		case <-ticker.C:
-			if time.Now().After(deadline) {
-				return fmt.Errorf("timeout waiting for plugin to become healthy")
-			}
-
-			// Check health state
-			state := p.lifecycleServer.GetHealthState(runtimeID)
-			if state != nil && state.State == 1 { // HEALTH_STATE_HEALTHY
-				return nil
-			}
+		case <-timer.C:
+			return fmt.Errorf("timeout waiting for plugin to become healthy")
+		case <-changed:
 		}
 	}
 }
 
-// drainPlugin waits for in-flight requests to complete.
-func (p *Platform) drainPlugin(instance *PluginInstance, timeout time.Duration) {
-	// Simple implementation: just wait
-	// TODO: Track in-flight requests and wait for them to complete
-	time.Sleep(timeout)
+// drain stops routing new calls to a plugin and waits up to timeout for its
+// in-flight calls to finish.
+func (p *Platform) drain(ctx context.Context, runtimeID string, timeout time.Duration) DrainResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := p.router.DrainProvider(ctx, runtimeID)
+	if !result.Drained {
+		log.Printf("[PLATFORM] %s: %d calls still in flight after %v; shutting down anyway",
+			runtimeID, result.InFlight, result.Duration.Round(time.Millisecond))
+	}
+	return result
 }
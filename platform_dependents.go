@@ -0,0 +1,277 @@
+package connectplugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/masegraye/connect-plugin-go/internal/depgraph"
+)
+
+// DependentPolicy decides what RemovePluginWithOptions does with plugins
+// that require a service only the removed plugin provides (the
+// AffectedPlugins of its impact analysis).
+type DependentPolicy int
+
+const (
+	// DependentsIgnore removes the plugin and leaves its dependents running.
+	DependentsIgnore DependentPolicy = iota
+
+	// DependentsRefuse fails the removal if any plugin requires its services.
+	DependentsRefuse
+
+	// DependentsStop stops the dependents first, in reverse dependency
+	// order: their services are unregistered and drained and they are
+	// paused. Their processes keep running; once the services they require
+	// are available again they are resumed and added back in startup order.
+	// A dependent that cannot be added back is shut down.
+	DependentsStop
+
+	// DependentsDegrade marks the dependents DEGRADED until the services
+	// they require are available again. Watchers of the removed services
+	// and of the dependents' own services are notified through
+	// WatchService.
+	DependentsDegrade
+)
+
+// String returns the policy name.
+func (d DependentPolicy) String() string {
+	switch d {
+	case DependentsIgnore:
+		return "ignore"
+	case DependentsRefuse:
+		return "refuse"
+	case DependentsStop:
+		return "stop"
+	case DependentsDegrade:
+		return "degrade"
+	default:
+		return "unknown"
+	}
+}
+
+// stoppedPlugin is a dependent stopped by RemovePlugin.
+type stoppedPlugin struct {
+	instance *PluginInstance
+
+	// waitingFor are the removed services the plugin (transitively) needs
+	waitingFor []string
+
+	// requires are the plugin's own startup requirements
+	requires []string
+
+	// order is the plugin's position in the startup order when stopped
+	order int
+}
+
+// StoppedPlugins returns the runtime IDs of dependents stopped by
+// RemovePlugin that wait for a service to come back.
+func (p *Platform) StoppedPlugins() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ids := make([]string, 0, len(p.stopped))
+	for runtimeID := range p.stopped {
+		ids = append(ids, runtimeID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// unavailableServices returns the services that no plugin other than
+// runtimeID provides.
+func (p *Platform) unavailableServices(runtimeID string, impact *depgraph.ImpactAnalysis) []string {
+	var services []string
+	for _, serviceType := range impact.AffectedServices {
+		others := false
+		for _, providerID := range p.depGraph.GetProviders(serviceType) {
+			if providerID != runtimeID {
+				others = true
+				break
+			}
+		}
+		if !others {
+			services = append(services, serviceType)
+		}
+	}
+	return services
+}
+
+// stopDependents stops the plugins affected by removing target, dependents
+// of dependents first. Returns the runtime IDs stopped.
+func (p *Platform) stopDependents(ctx context.Context, target *PluginInstance, impact *depgraph.ImpactAnalysis, drainTimeout time.Duration) []string {
+	waitingFor := p.unavailableServices(target.RuntimeID, impact)
+	reason := fmt.Sprintf("required plugin %s removed", target.SelfID)
+
+	position := make(map[string]int)
+	if order, err := p.depGraph.StartupOrder(); err == nil {
+		for i, runtimeID := range order {
+			position[runtimeID] = i
+		}
+	}
+	affected := append([]string(nil), impact.AffectedPlugins...)
+	sort.SliceStable(affected, func(i, j int) bool {
+		return position[affected[i]] > position[affected[j]]
+	})
+
+	var stopped []string
+	for _, runtimeID := range affected {
+		instance, err := p.beginOp(runtimeID)
+		if err != nil {
+			log.Printf("[PLATFORM] cannot stop dependent %s: %v", runtimeID, err)
+			continue
+		}
+
+		p.takeDown(ctx, instance, drainTimeout, func(control *PluginControlClient) {
+			if err := control.Pause(ctx, reason); err != nil {
+				log.Printf("[PLATFORM] failed to pause %s: %v", runtimeID, err)
+			}
+		})
+
+		p.mu.Lock()
+		p.stopped[runtimeID] = &stoppedPlugin{
+			instance:   instance,
+			waitingFor: waitingFor,
+			requires:   startupRequirements(instance.Metadata.Requires),
+			order:      position[runtimeID],
+		}
+		p.mu.Unlock()
+		p.endOp(runtimeID)
+
+		stopped = append(stopped, runtimeID)
+		p.emit(PlatformEvent{Type: PluginStopped, RuntimeID: runtimeID, SelfID: instance.SelfID, Reason: reason})
+	}
+	return stopped
+}
+
+// degradeDependents marks the plugins affected by removing target DEGRADED.
+// Returns the runtime IDs marked.
+func (p *Platform) degradeDependents(target *PluginInstance, impact *depgraph.ImpactAnalysis) []string {
+	waitingFor := p.unavailableServices(target.RuntimeID, impact)
+	reason := fmt.Sprintf("required plugin %s removed", target.SelfID)
+
+	var degraded []string
+	for _, runtimeID := range impact.AffectedPlugins {
+		p.mu.Lock()
+		instance, ok := p.plugins[runtimeID]
+		if ok {
+			p.degraded[runtimeID] = waitingFor
+		}
+		p.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		// Transitive dependents lose no service directly
+		var unavailable []string
+		for _, serviceType := range startupRequirements(instance.Metadata.Requires) {
+			for _, missing := range waitingFor {
+				if serviceType == missing {
+					unavailable = append(unavailable, serviceType)
+				}
+			}
+		}
+		p.lifecycleServer.MarkDegraded(runtimeID, reason, unavailable)
+
+		degraded = append(degraded, runtimeID)
+		p.emit(PlatformEvent{Type: PluginDegraded, RuntimeID: runtimeID, SelfID: instance.SelfID, Reason: reason})
+	}
+	return degraded
+}
+
+// resumeDependents clears the DEGRADED mark of dependents whose services are
+// back and starts restarting stopped dependents that can run again.
+func (p *Platform) resumeDependents() {
+	p.mu.Lock()
+	var cleared []string
+	for runtimeID, waitingFor := range p.degraded {
+		if p.servicesAvailable(waitingFor) {
+			delete(p.degraded, runtimeID)
+			cleared = append(cleared, runtimeID)
+		}
+	}
+	restart := !p.restarting && p.nextStoppedLocked() != nil
+	if restart {
+		p.restarting = true
+	}
+	p.mu.Unlock()
+
+	for _, runtimeID := range cleared {
+		p.lifecycleServer.ClearDegraded(runtimeID)
+	}
+	if restart {
+		go p.restartStopped()
+	}
+}
+
+// restartStopped adds stopped dependents back one at a time, in startup
+// order, until none can run. A plugin that fails to restart is shut down
+// rather than left paused; AddPlugin reports it with PluginFailed.
+func (p *Platform) restartStopped() {
+	for {
+		p.mu.Lock()
+		next := p.nextStoppedLocked()
+		if next == nil {
+			p.restarting = false
+			p.mu.Unlock()
+			return
+		}
+		delete(p.stopped, next.instance.RuntimeID)
+		p.mu.Unlock()
+
+		ctx := context.Background()
+		if next.instance.control != nil {
+			if err := next.instance.control.Resume(ctx); err != nil {
+				log.Printf("[PLATFORM] failed to resume %s: %v", next.instance.RuntimeID, err)
+			}
+		}
+		if err := p.AddPlugin(ctx, next.instance.config); err != nil {
+			log.Printf("[PLATFORM] failed to restart %s: %v", next.instance.SelfID, err)
+			if next.instance.control != nil {
+				if _, err := next.instance.control.Shutdown(ctx, 10, "restart failed"); err != nil {
+					log.Printf("[PLATFORM] failed to shut down %s: %v", next.instance.RuntimeID, err)
+				}
+			}
+		}
+	}
+}
+
+// nextStoppedLocked returns the first stopped dependent in startup order
+// whose services are available, or nil.
+// Caller must hold lock.
+func (p *Platform) nextStoppedLocked() *stoppedPlugin {
+	var next *stoppedPlugin
+	for _, stopped := range p.stopped {
+		if !p.servicesAvailable(stopped.waitingFor) || !p.servicesAvailable(stopped.requires) {
+			continue
+		}
+		if next == nil || stopped.order < next.order ||
+			(stopped.order == next.order && stopped.instance.RuntimeID < next.instance.RuntimeID) {
+			next = stopped
+		}
+	}
+	return next
+}
+
+// servicesAvailable reports whether every service type has a provider.
+func (p *Platform) servicesAvailable(serviceTypes []string) bool {
+	for _, serviceType := range serviceTypes {
+		if !p.depGraph.HasService(serviceType) {
+			return false
+		}
+	}
+	return true
+}
+
+// startupRequirements returns the service types required for startup.
+func startupRequirements(requires []ServiceDependency) []string {
+	var types []string
+	for _, dep := range requires {
+		if dep.RequiredForStartup {
+			types = append(types, dep.Type)
+		}
+	}
+	return types
+}
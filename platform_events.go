@@ -0,0 +1,146 @@
+package connectplugin
+
+import (
+	"time"
+
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+)
+
+// PlatformEventType identifies a plugin lifecycle event published by Platform.
+type PlatformEventType int
+
+const (
+	// PluginAdded: the plugin was assigned its runtime identity and added to
+	// the dependency graph. It is not routed traffic until PluginHealthy.
+	PluginAdded PlatformEventType = iota + 1
+
+	// PluginHealthy: the plugin became healthy, either when it finished
+	// joining the platform or when it recovered.
+	PluginHealthy
+
+	// PluginFailed: an operation on the plugin failed (Err is set), or a
+	// running plugin became unhealthy.
+	PluginFailed
+
+	// PluginRemoved: the plugin was removed from the platform.
+	PluginRemoved
+
+	// PluginReplaced: the plugin replaced PreviousRuntimeID.
+	PluginReplaced
+
+	// PluginStopped: the plugin was stopped because a plugin it requires
+	// was removed (DependentsStop). It is added back, under a new runtime
+	// ID, once the services it requires are available again.
+	PluginStopped
+
+	// PluginDegraded: the plugin was marked DEGRADED because a plugin it
+	// requires was removed (DependentsDegrade).
+	PluginDegraded
+)
+
+// String returns the event type name.
+func (t PlatformEventType) String() string {
+	switch t {
+	case PluginAdded:
+		return "added"
+	case PluginHealthy:
+		return "healthy"
+	case PluginFailed:
+		return "failed"
+	case PluginRemoved:
+		return "removed"
+	case PluginReplaced:
+		return "replaced"
+	case PluginStopped:
+		return "stopped"
+	case PluginDegraded:
+		return "degraded"
+	default:
+		return "unknown"
+	}
+}
+
+// PlatformEvent describes a change to a plugin managed by Platform.
+type PlatformEvent struct {
+	Type      PlatformEventType
+	RuntimeID string
+	SelfID    string
+
+	// PreviousRuntimeID is the replaced plugin (PluginReplaced only).
+	PreviousRuntimeID string
+
+	// Reason describes the event, e.g. the reported health reason.
+	Reason string
+
+	// Err is the error that failed an operation (PluginFailed only).
+	Err error
+
+	// Drain reports how the plugin's in-flight calls were drained
+	// (PluginRemoved and PluginReplaced only; for PluginReplaced it
+	// describes PreviousRuntimeID).
+	Drain *DrainResult
+
+	// Time is when the event happened.
+	Time time.Time
+}
+
+// OnEvent registers a callback for plugin lifecycle events.
+//
+// Callbacks run synchronously, outside the platform's lock, on the goroutine
+// that produced the event: the caller of a Platform operation, a plugin's
+// ReportHealth RPC, or the lifecycle heartbeat monitor. A slow callback
+// delays that RPC or operation, so callbacks should return quickly and hand
+// longer work off to their own goroutine. Events produced concurrently are
+// delivered concurrently and in no particular order, so callbacks must be
+// safe for concurrent use.
+func (p *Platform) OnEvent(fn func(PlatformEvent)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listeners = append(p.listeners, fn)
+}
+
+// emit delivers an event to the registered callbacks.
+func (p *Platform) emit(event PlatformEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	p.mu.RLock()
+	listeners := p.listeners
+	p.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
+
+// handleHealthChange wakes health waiters and turns health changes of
+// running plugins into PluginHealthy and PluginFailed events.
+func (p *Platform) handleHealthChange(change HealthChange) {
+	p.mu.Lock()
+	close(p.healthChanged)
+	p.healthChanged = make(chan struct{})
+	instance, ok := p.plugins[change.RuntimeID]
+	p.mu.Unlock()
+
+	// Plugins still joining report through AddPlugin
+	if !ok || change.Removed || change.Previous == change.Current {
+		return
+	}
+
+	event := PlatformEvent{
+		RuntimeID: change.RuntimeID,
+		SelfID:    instance.SelfID,
+		Reason:    change.Reason,
+		Time:      change.ChangedAt,
+	}
+	switch {
+	case change.Current == connectpluginv1.HealthState_HEALTH_STATE_HEALTHY:
+		event.Type = PluginHealthy
+	case change.Current == connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY:
+		event.Type = PluginFailed
+	default:
+		return
+	}
+	p.emit(event)
+}
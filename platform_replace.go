@@ -0,0 +1,132 @@
+package connectplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+)
+
+// checkReplacement checks that a new version with the given metadata can
+// take over from runtimeID: it provides every service the old version did,
+// in versions the old version's dependents accept, and the services it
+// requires for startup are provided by other plugins.
+func (p *Platform) checkReplacement(runtimeID string, old *PluginInstance, metadata PluginMetadata) error {
+	// The graph has the old version's declarations even if it was not added
+	// through AddPlugin
+	oldProvides := old.Metadata.Provides
+	if node := p.depGraph.GetNode(runtimeID); node != nil {
+		oldProvides = make([]ServiceDeclaration, len(node.Provides))
+		for i, svc := range node.Provides {
+			oldProvides[i] = ServiceDeclaration{Type: svc.Type, Version: svc.Version}
+		}
+	}
+
+	newVersions := make(map[string]string)
+	for _, svc := range metadata.Provides {
+		newVersions[svc.Type] = svc.Version
+	}
+
+	var problems []string
+	for _, svc := range oldProvides {
+		version, ok := newVersions[svc.Type]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("no longer provides %q", svc.Type))
+			continue
+		}
+		for _, dependentID := range p.depGraph.Dependents(svc.Type) {
+			node := p.depGraph.GetNode(dependentID)
+			if dependentID == runtimeID || node == nil {
+				continue
+			}
+			for _, dep := range node.Requires {
+				if dep.Type == svc.Type && !versionSatisfies(version, dep.MinVersion) {
+					problems = append(problems, fmt.Sprintf("%q version %s does not satisfy %s (requires %s)",
+						svc.Type, version, dependentID, dep.MinVersion))
+				}
+			}
+		}
+	}
+
+	for _, dep := range metadata.Requires {
+		if dep.RequiredForStartup && !p.serviceProvided(dep.Type, dep.MinVersion, runtimeID) {
+			problems = append(problems, fmt.Sprintf("required service %q not available", dep.Type))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// serviceProvided reports whether a plugin other than excludeRuntimeID
+// provides serviceType in a version meeting minVersion.
+func (p *Platform) serviceProvided(serviceType, minVersion, excludeRuntimeID string) bool {
+	for _, providerID := range p.depGraph.GetProviders(serviceType) {
+		node := p.depGraph.GetNode(providerID)
+		if providerID == excludeRuntimeID || node == nil {
+			continue
+		}
+		for _, svc := range node.Provides {
+			if svc.Type == serviceType && versionSatisfies(svc.Version, minVersion) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyReplacement watches a new version for the bake period. Fails if it
+// stops being routed traffic (unhealthy, missed heartbeats, failing probes
+// or flapping) or ctx ends first. A negative period skips verification.
+func (p *Platform) verifyReplacement(ctx context.Context, runtimeID string, period time.Duration) error {
+	if period < 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(period)
+	defer timer.Stop()
+
+	for {
+		// Take the wakeup channel before checking so no change is missed
+		p.mu.RLock()
+		changed := p.healthChanged
+		p.mu.RUnlock()
+
+		if !p.lifecycleServer.ShouldRouteTraffic(runtimeID) {
+			state := p.lifecycleServer.GetHealthState(runtimeID)
+			if state != nil && state.State == connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY {
+				return fmt.Errorf("new version became unhealthy: %s", state.Reason)
+			}
+			return fmt.Errorf("new version stopped receiving traffic")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case <-changed:
+		}
+	}
+}
+
+// rollbackReplacement restores the old version after a failed switch and
+// removes the new one.
+func (p *Platform) rollbackReplacement(ctx context.Context, old *PluginInstance, previous []*ServiceProvider, failed *PluginInstance, drainTimeout time.Duration) {
+	// Finish the rollback even if the caller gave up
+	ctx = context.WithoutCancel(ctx)
+
+	p.router.ResumeProvider(old.RuntimeID)
+	p.registry.reinstate(previous)
+
+	p.takeDown(ctx, failed, drainTimeout, func(control *PluginControlClient) {
+		control.Shutdown(ctx, 10, "replacement rolled back")
+	})
+	log.Printf("[PLATFORM] rolled back replacement of %s: removed %s", old.RuntimeID, failed.RuntimeID)
+}
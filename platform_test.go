@@ -2,6 +2,12 @@ package connectplugin
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -190,6 +196,7 @@ func TestPlatform_RemovePlugin(t *testing.T) {
 		SelfID:    "logger",
 		Endpoint:  "http://localhost:8081",
 	}
+	registerTestToken(handshake, runtimeID, "logger-token")
 
 	// Register service
 	regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
@@ -197,8 +204,7 @@ func TestPlatform_RemovePlugin(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/logger.v1.Logger/",
 	})
-	regReq.Header().Set("X-Plugin-Runtime-ID", runtimeID)
-	registry.RegisterService(context.Background(), regReq)
+	registry.RegisterService(runtimeContext(runtimeID), regReq)
 
 	// Verify service is registered
 	if !registry.HasService("logger", "1.0.0") {
@@ -226,6 +232,66 @@ func TestPlatform_RemovePlugin(t *testing.T) {
 	if _, ok := platform.plugins[runtimeID]; ok {
 		t.Error("Expected plugin to be removed from map")
 	}
+
+	// Verify its runtime token is revoked
+	if handshake.ValidateToken(runtimeID, "logger-token") {
+		t.Error("Expected runtime token to be revoked")
+	}
+}
+
+func TestPlatform_AddPluginRevokesTokenOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	handshake := NewHandshakeServer(&ServeConfig{})
+	handshake.SetStateStore(store)
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+
+	var mu sync.Mutex
+	var failed string
+	platform.OnEvent(func(e PlatformEvent) {
+		if e.Type == PluginFailed {
+			mu.Lock()
+			failed = e.RuntimeID
+			mu.Unlock()
+		}
+	})
+
+	// The plugin's host has no registry, so it never becomes healthy
+	host := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(host.Close)
+	endpoint := startKVPlugin(t, host.URL, PluginServeConfig{}, kvMetadata("1.0.0"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: endpoint}); err == nil {
+		t.Fatal("Expected AddPlugin to fail")
+	}
+
+	mu.Lock()
+	runtimeID := failed
+	mu.Unlock()
+	if runtimeID == "" {
+		t.Fatal("Expected PluginFailed with the assigned runtime ID")
+	}
+	if handshake.selfID(runtimeID) != "" {
+		t.Error("Expected runtime token revoked after failed AddPlugin")
+	}
+	store.Close()
+
+	// The revocation is persisted
+	store2, _ := NewFileStateStore(FileStateStoreConfig{Dir: dir, NoSync: true})
+	defer store2.Close()
+	handshake2 := NewHandshakeServer(&ServeConfig{})
+	lifecycle2 := NewLifecycleServer()
+	if err := RestoreState(store2, handshake2, NewServiceRegistry(lifecycle2), lifecycle2); err != nil {
+		t.Fatalf("RestoreState failed: %v", err)
+	}
+	if handshake2.selfID(runtimeID) != "" {
+		t.Error("Expected revoked token not to be restored")
+	}
 }
 
 func TestPlatform_RemovePlugin_NotFound(t *testing.T) {
@@ -266,8 +332,7 @@ func TestPlatform_ReplacePlugin_CreatesNewInstance(t *testing.T) {
 	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
 	})
-	healthReq.Header().Set("X-Plugin-Runtime-ID", oldRuntimeID)
-	lifecycle.ReportHealth(context.Background(), healthReq)
+	lifecycle.ReportHealth(runtimeContext(oldRuntimeID), healthReq)
 
 	// Replace with new version
 	newConfig := PluginConfig{
@@ -296,3 +361,667 @@ func TestPlatform_ReplacePlugin_CreatesNewInstance(t *testing.T) {
 
 	// Note: In real usage, the new plugin would report health and replace would succeed
 }
+
+func TestPlatform_SetCanary(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+
+	platform.plugins["kv-new"] = &PluginInstance{
+		RuntimeID: "kv-new",
+		SelfID:    "kv",
+		Metadata: PluginMetadata{
+			Provides: []ServiceDeclaration{{Type: "kv", Version: "2.0.0"}},
+		},
+	}
+
+	if err := platform.SetCanary("kv-new", 5); err != nil {
+		t.Fatalf("SetCanary failed: %v", err)
+	}
+	policy := registry.TrafficPolicy("kv")
+	if policy == nil || len(policy.Splits) != 1 || policy.Splits[0].RuntimeID != "kv-new" || policy.Splits[0].Percent != 5 {
+		t.Fatalf("Expected 5%% split to kv-new, got %+v", policy)
+	}
+
+	// Raising the percentage replaces the split
+	platform.SetCanary("kv-new", 50)
+	if policy := registry.TrafficPolicy("kv"); len(policy.Splits) != 1 || policy.Splits[0].Percent != 50 {
+		t.Errorf("Expected single 50%% split, got %+v", policy)
+	}
+
+	// Removing the plugin clears its split
+	if err := platform.RemovePlugin(context.Background(), "kv-new"); err != nil {
+		t.Fatalf("RemovePlugin failed: %v", err)
+	}
+	if policy := registry.TrafficPolicy("kv"); policy != nil {
+		t.Errorf("Expected traffic policy removed, got %+v", policy)
+	}
+
+	if err := platform.SetCanary("missing", 10); err == nil {
+		t.Error("Expected error for unknown plugin")
+	}
+}
+
+func TestPlatform_ConcurrentAddAndEvents(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	host := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	var mu sync.Mutex
+	events := make(map[string][]PlatformEventType)
+	platform.OnEvent(func(event PlatformEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events[event.RuntimeID] = append(events[event.RuntimeID], event.Type)
+	})
+
+	// The first plugin turns unhealthy once told to
+	var unhealthy atomic.Bool
+	health := func(ctx context.Context) *connectpluginv1.GetHealthResponse {
+		if unhealthy.Load() {
+			return &connectpluginv1.GetHealthResponse{State: connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY, Reason: "disk full"}
+		}
+		return &connectpluginv1.GetHealthResponse{State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY}
+	}
+
+	endpoints := make([]string, 2)
+	for i, selfID := range []string{"logger-plugin", "cache-plugin"} {
+		endpoints[i], _ = startServePlugin(t, &PluginServeConfig{
+			ClientConfig: ClientConfig{
+				HostURL: host.URL,
+				SelfID:  selfID,
+				Metadata: PluginMetadata{
+					Provides: []ServiceDeclaration{{Type: selfID, Version: "1.0.0", Path: "/" + selfID + "/"}},
+				},
+			},
+			Health:         health,
+			HealthInterval: 20 * time.Millisecond,
+			StopCh:         make(chan struct{}),
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint string) {
+			defer wg.Done()
+			if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: endpoint}); err != nil {
+				t.Errorf("AddPlugin failed: %v", err)
+			}
+		}(endpoint)
+	}
+	wg.Wait()
+
+	plugins := platform.Plugins()
+	if len(plugins) != 2 {
+		t.Fatalf("Expected 2 plugins, got %d", len(plugins))
+	}
+	mu.Lock()
+	for _, instance := range plugins {
+		got := events[instance.RuntimeID]
+		if len(got) != 2 || got[0] != PluginAdded || got[1] != PluginHealthy {
+			t.Errorf("Expected added, healthy for %s, got %v", instance.RuntimeID, got)
+		}
+	}
+	mu.Unlock()
+
+	// Running plugins that turn unhealthy publish PluginFailed
+	unhealthy.Store(true)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		failed := 0
+		for _, got := range events {
+			if got[len(got)-1] == PluginFailed {
+				failed++
+			}
+		}
+		mu.Unlock()
+		if failed == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected PluginFailed for both plugins, got %v", events)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Concurrent removals of the same plugin: one wins
+	runtimeID := plugins[0].RuntimeID
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- platform.RemovePlugin(ctx, runtimeID) }()
+	}
+	if err1, err2 := <-errs, <-errs; (err1 == nil) == (err2 == nil) {
+		t.Errorf("Expected exactly one removal to succeed, got %v and %v", err1, err2)
+	}
+	if platform.GetPlugin(runtimeID) != nil {
+		t.Error("Expected plugin removed")
+	}
+	mu.Lock()
+	if got := events[runtimeID]; got[len(got)-1] != PluginRemoved {
+		t.Errorf("Expected PluginRemoved last, got %v", got)
+	}
+	mu.Unlock()
+}
+
+func TestPlatform_RemovePluginDrainsInFlightCalls(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+
+	started := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+	runtimeID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-unblock
+		}))
+	platform.plugins[runtimeID] = &PluginInstance{RuntimeID: runtimeID, SelfID: "kv"}
+
+	var events []PlatformEvent
+	platform.OnEvent(func(e PlatformEvent) { events = append(events, e) })
+
+	newRequest := newTestCaller(t, handshake)
+	go router.ServeHTTP(httptest.NewRecorder(), newRequest("POST", "/services/kv/"+runtimeID+"/Get", nil))
+	<-started
+
+	// Removal waits for the call, then finishes without a fixed grace period
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(unblock)
+	}()
+	start := time.Now()
+	result, err := platform.RemovePluginWithOptions(context.Background(), runtimeID, RemoveOptions{DrainTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("RemovePluginWithOptions failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Expected removal to finish when the call did, took %v", elapsed)
+	}
+	if !result.Drain.Drained {
+		t.Errorf("Expected in-flight call drained, got %+v", result.Drain)
+	}
+
+	if len(events) != 1 || events[0].Type != PluginRemoved || events[0].Drain == nil || !events[0].Drain.Drained {
+		t.Errorf("Expected PluginRemoved event with drain result, got %+v", events)
+	}
+}
+
+// addDependencyTestPlugins adds a kv provider and a cache plugin that
+// requires kv to the platform's graph and plugin map.
+func addDependencyTestPlugins(platform *Platform) {
+	platform.AddToDependencyGraph("kv-1", "kv",
+		[]ServiceDeclaration{{Type: "kv", Version: "1.0.0"}}, nil)
+	platform.AddToDependencyGraph("cache-1", "cache",
+		[]ServiceDeclaration{{Type: "cache", Version: "1.0.0"}},
+		[]ServiceDependency{{Type: "kv", RequiredForStartup: true}})
+	for _, instance := range []*PluginInstance{
+		{RuntimeID: "kv-1", SelfID: "kv"},
+		{RuntimeID: "cache-1", SelfID: "cache", Metadata: PluginMetadata{
+			Requires: []ServiceDependency{{Type: "kv", RequiredForStartup: true}},
+		}},
+	} {
+		platform.plugins[instance.RuntimeID] = instance
+	}
+}
+
+func TestPlatform_RemovePluginRefusesRequiredDependents(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	addDependencyTestPlugins(platform)
+
+	_, err := platform.RemovePluginWithOptions(context.Background(), "kv-1", RemoveOptions{Dependents: DependentsRefuse})
+	if err == nil {
+		t.Fatal("Expected removal refused while cache requires kv")
+	}
+	if platform.GetPlugin("kv-1") == nil || !platform.depGraph.HasService("kv") {
+		t.Error("Expected refused removal to leave the plugin in place")
+	}
+
+	// Without required dependents the removal goes ahead
+	if _, err := platform.RemovePluginWithOptions(context.Background(), "cache-1", RemoveOptions{Dependents: DependentsRefuse}); err != nil {
+		t.Fatalf("RemovePluginWithOptions failed: %v", err)
+	}
+}
+
+func TestPlatform_RemovePluginDegradesDependents(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	addDependencyTestPlugins(platform)
+
+	lifecycle.ReportHealth(runtimeContext("cache-1"), connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+	}))
+
+	var events []PlatformEvent
+	platform.OnEvent(func(e PlatformEvent) { events = append(events, e) })
+
+	result, err := platform.RemovePluginWithOptions(context.Background(), "kv-1", RemoveOptions{Dependents: DependentsDegrade})
+	if err != nil {
+		t.Fatalf("RemovePluginWithOptions failed: %v", err)
+	}
+	if len(result.Degraded) != 1 || result.Degraded[0] != "cache-1" {
+		t.Errorf("Expected cache-1 degraded, got %v", result.Degraded)
+	}
+	state := lifecycle.GetHealthState("cache-1")
+	if state.State != connectpluginv1.HealthState_HEALTH_STATE_DEGRADED ||
+		len(state.UnavailableDependencies) != 1 || state.UnavailableDependencies[0] != "kv" {
+		t.Errorf("Expected cache-1 DEGRADED waiting for kv, got %+v", state)
+	}
+
+	// The plugin's own reports do not clear the mark
+	lifecycle.ReportHealth(runtimeContext("cache-1"), connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+	}))
+	if state := lifecycle.GetHealthState("cache-1"); state.State != connectpluginv1.HealthState_HEALTH_STATE_DEGRADED {
+		t.Errorf("Expected cache-1 still DEGRADED, got %v", state.State)
+	}
+
+	// A new kv provider restores it
+	platform.AddToDependencyGraph("kv-2", "kv", []ServiceDeclaration{{Type: "kv", Version: "1.0.0"}}, nil)
+	if state := lifecycle.GetHealthState("cache-1"); state.State != connectpluginv1.HealthState_HEALTH_STATE_HEALTHY {
+		t.Errorf("Expected cache-1 HEALTHY once kv is back, got %v", state.State)
+	}
+
+	var types []PlatformEventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []PlatformEventType{PluginDegraded, PluginRemoved, PluginHealthy}
+	if len(types) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("Expected events %v, got %v", want, types)
+			break
+		}
+	}
+}
+
+func TestPlatform_RemovePluginStopsAndRestartsDependents(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	host := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	servePlugin := func(selfID string, metadata PluginMetadata) string {
+		endpoint, _ := startServePlugin(t, &PluginServeConfig{
+			ClientConfig: ClientConfig{HostURL: host.URL, SelfID: selfID, Metadata: metadata},
+			StopCh:       make(chan struct{}),
+		})
+		return endpoint
+	}
+	kvMetadata := PluginMetadata{
+		Name:     "kv",
+		Version:  "1.0.0",
+		Provides: []ServiceDeclaration{{Type: "kv", Version: "1.0.0", Path: "/kv.v1.KV/"}},
+	}
+	cacheEndpoint := servePlugin("cache", PluginMetadata{
+		Name:     "cache",
+		Version:  "1.0.0",
+		Provides: []ServiceDeclaration{{Type: "cache", Version: "1.0.0", Path: "/cache.v1.Cache/"}},
+		Requires: []ServiceDependency{{Type: "kv", RequiredForStartup: true}},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: servePlugin("kv", kvMetadata)}); err != nil {
+		t.Fatalf("AddPlugin kv failed: %v", err)
+	}
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: cacheEndpoint}); err != nil {
+		t.Fatalf("AddPlugin cache failed: %v", err)
+	}
+
+	events := make(chan PlatformEvent, 16)
+	platform.OnEvent(func(e PlatformEvent) { events <- e })
+
+	kvID := platform.depGraph.GetProviders("kv")[0]
+	cacheID := platform.depGraph.GetProviders("cache")[0]
+	result, err := platform.RemovePluginWithOptions(ctx, kvID, RemoveOptions{Dependents: DependentsStop})
+	if err != nil {
+		t.Fatalf("RemovePluginWithOptions failed: %v", err)
+	}
+	if len(result.Stopped) != 1 || result.Stopped[0] != cacheID {
+		t.Fatalf("Expected cache stopped, got %v", result.Stopped)
+	}
+	if e := <-events; e.Type != PluginStopped || e.RuntimeID != cacheID {
+		t.Errorf("Expected cache stopped before kv is removed, got %v %s", e.Type, e.RuntimeID)
+	}
+	if e := <-events; e.Type != PluginRemoved || e.RuntimeID != kvID {
+		t.Errorf("Expected kv removed, got %v %s", e.Type, e.RuntimeID)
+	}
+	if stopped := platform.StoppedPlugins(); len(stopped) != 1 || stopped[0] != cacheID {
+		t.Errorf("Expected cache waiting for kv, got %v", stopped)
+	}
+	if platform.GetPlugin(cacheID) != nil || registry.HasService("cache", "") {
+		t.Error("Expected cache removed from the platform")
+	}
+
+	// When kv is back, cache is added again
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: servePlugin("kv", kvMetadata)}); err != nil {
+		t.Fatalf("AddPlugin kv failed: %v", err)
+	}
+	for {
+		select {
+		case e := <-events:
+			if e.Type == PluginHealthy && e.SelfID == "cache" {
+				if len(platform.StoppedPlugins()) != 0 {
+					t.Errorf("Expected no stopped plugins, got %v", platform.StoppedPlugins())
+				}
+				if !registry.HasService("cache", "") {
+					t.Error("Expected cache services registered again")
+				}
+				return
+			}
+			if e.Type == PluginFailed {
+				t.Fatalf("Restart failed: %v", e.Err)
+			}
+		case <-ctx.Done():
+			t.Fatal("Expected cache restarted")
+		}
+	}
+}
+
+func TestPlatform_StoppedDependentShutDownWhenRestartFails(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	host := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	kvMetadata := kvMetadata("1.0.0")
+	kvEndpoint := func() string {
+		endpoint, _ := startServePlugin(t, &PluginServeConfig{
+			ClientConfig: ClientConfig{HostURL: host.URL, SelfID: "kv", Metadata: kvMetadata},
+			StopCh:       make(chan struct{}),
+		})
+		return endpoint
+	}
+	cacheEndpoint, cacheDone := startServePlugin(t, &PluginServeConfig{
+		ClientConfig: ClientConfig{HostURL: host.URL, SelfID: "cache", Metadata: PluginMetadata{
+			Name:     "cache",
+			Version:  "1.0.0",
+			Provides: []ServiceDeclaration{{Type: "cache", Version: "1.0.0", Path: "/cache.v1.Cache/"}},
+			Requires: []ServiceDependency{{Type: "kv", RequiredForStartup: true}},
+		}},
+		StopCh: make(chan struct{}),
+	})
+
+	// The cache plugin refuses its identity once it has been stopped
+	var refuse atomic.Bool
+	target, _ := http.NewRequest(http.MethodGet, cacheEndpoint, nil)
+	proxy := httputil.NewSingleHostReverseProxy(target.URL)
+	cache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if refuse.Load() && strings.HasSuffix(r.URL.Path, "/SetRuntimeIdentity") {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(cache.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: kvEndpoint()}); err != nil {
+		t.Fatalf("AddPlugin kv failed: %v", err)
+	}
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: cache.URL}); err != nil {
+		t.Fatalf("AddPlugin cache failed: %v", err)
+	}
+
+	kvID := platform.depGraph.GetProviders("kv")[0]
+	if _, err := platform.RemovePluginWithOptions(ctx, kvID, RemoveOptions{Dependents: DependentsStop}); err != nil {
+		t.Fatalf("RemovePluginWithOptions failed: %v", err)
+	}
+	refuse.Store(true)
+
+	events := make(chan PlatformEvent, 16)
+	platform.OnEvent(func(e PlatformEvent) { events <- e })
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: kvEndpoint()}); err != nil {
+		t.Fatalf("AddPlugin kv failed: %v", err)
+	}
+
+	// The failed restart is reported and the paused plugin is shut down
+	for failed := false; !failed; {
+		select {
+		case e := <-events:
+			failed = e.Type == PluginFailed && e.SelfID == "cache"
+		case <-ctx.Done():
+			t.Fatal("Expected cache restart to fail")
+		}
+	}
+	select {
+	case err := <-cacheDone:
+		if err != nil {
+			t.Errorf("ServePlugin returned error: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected cache plugin shut down after the failed restart")
+	}
+	if stopped := platform.StoppedPlugins(); len(stopped) != 0 {
+		t.Errorf("Expected no stopped plugins, got %v", stopped)
+	}
+}
+
+// startKVPlugin serves a managed kv plugin for replacement tests.
+func startKVPlugin(t *testing.T, hostURL string, cfg PluginServeConfig, metadata PluginMetadata) string {
+	t.Helper()
+
+	cfg.ClientConfig = ClientConfig{HostURL: hostURL, SelfID: "kv", Metadata: metadata}
+	cfg.StopCh = make(chan struct{})
+	endpoint, _ := startServePlugin(t, &cfg)
+	return endpoint
+}
+
+func kvMetadata(version string, requires ...ServiceDependency) PluginMetadata {
+	return PluginMetadata{
+		Name:     "kv",
+		Version:  version,
+		Provides: []ServiceDeclaration{{Type: "kv", Version: version, Path: "/kv.v1.KV/"}},
+		Requires: requires,
+	}
+}
+
+func TestPlatform_ReplacePluginChecksCompatibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata PluginMetadata
+		wantErr  string
+	}{
+		{"missing service", PluginMetadata{Name: "kv", Version: "2.0.0"}, `no longer provides "kv"`},
+		{"version too old", kvMetadata("1.0.0"), "does not satisfy cache-1"},
+		{"missing dependency", kvMetadata("2.1.0", ServiceDependency{Type: "metrics", RequiredForStartup: true}), `required service "metrics" not available`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handshake := NewHandshakeServer(&ServeConfig{})
+			lifecycle := NewLifecycleServer()
+			registry := NewServiceRegistry(lifecycle)
+			router := NewServiceRouter(handshake, registry, lifecycle)
+			platform := NewPlatform(registry, lifecycle, router)
+			host := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+			platform.AddToDependencyGraph("kv-1", "kv", []ServiceDeclaration{{Type: "kv", Version: "2.0.0"}}, nil)
+			platform.AddToDependencyGraph("cache-1", "cache", nil,
+				[]ServiceDependency{{Type: "kv", MinVersion: "2.0.0", RequiredForStartup: true}})
+			platform.plugins["kv-1"] = &PluginInstance{RuntimeID: "kv-1", SelfID: "kv"}
+
+			var events []PlatformEvent
+			platform.OnEvent(func(e PlatformEvent) { events = append(events, e) })
+
+			endpoint := startKVPlugin(t, host.URL, PluginServeConfig{}, tt.metadata)
+			_, err := platform.ReplacePluginWithOptions(context.Background(), "kv-1", PluginConfig{Endpoint: endpoint}, ReplaceOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+
+			// Nothing changed: the old version stays, the new one got no identity
+			if platform.GetPlugin("kv-1") == nil || len(platform.depGraph.GetProviders("kv")) != 1 {
+				t.Error("Expected the old version kept as the only kv provider")
+			}
+			if len(events) != 1 || events[0].Type != PluginFailed || events[0].RuntimeID != "" {
+				t.Errorf("Expected a single PluginFailed event before identity was assigned, got %+v", events)
+			}
+		})
+	}
+}
+
+func TestPlatform_ReplacePluginUnhealthyIsTakenDown(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	host := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := platform.AddPlugin(ctx, PluginConfig{
+		Endpoint:  startKVPlugin(t, host.URL, PluginServeConfig{}, kvMetadata("1.0.0")),
+		Namespace: "team-a",
+	}); err != nil {
+		t.Fatalf("AddPlugin failed: %v", err)
+	}
+	oldID := platform.depGraph.GetProviders("kv")[0]
+
+	var mu sync.Mutex
+	var failedID string
+	platform.OnEvent(func(e PlatformEvent) {
+		if e.Type == PluginFailed {
+			mu.Lock()
+			failedID = e.RuntimeID
+			mu.Unlock()
+		}
+	})
+
+	// The new version registers its services but never becomes healthy
+	cfg := PluginServeConfig{
+		ClientConfig: ClientConfig{HostURL: host.URL, SelfID: "kv", Metadata: kvMetadata("1.1.0")},
+		StopCh:       make(chan struct{}),
+		Health: func(ctx context.Context) *connectpluginv1.GetHealthResponse {
+			return &connectpluginv1.GetHealthResponse{State: connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY}
+		},
+	}
+	endpoint, done := startServePlugin(t, &cfg)
+
+	_, err := platform.ReplacePluginWithOptions(ctx, oldID, PluginConfig{Endpoint: endpoint}, ReplaceOptions{HealthTimeout: 300 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "did not become healthy") {
+		t.Fatalf("Expected health failure, got %v", err)
+	}
+
+	mu.Lock()
+	newID := failedID
+	mu.Unlock()
+	if newID == "" {
+		t.Fatal("Expected PluginFailed with the new runtime ID")
+	}
+	if services := registry.GetServicesBy(newID); len(services) != 0 {
+		t.Errorf("Expected the new version's services unregistered, got %d", len(services))
+	}
+	if platform.depGraph.GetNode(newID) != nil {
+		t.Error("Expected the new version removed from the graph")
+	}
+	if handshake.selfID(newID) != "" || registry.Namespace(newID) != "" {
+		t.Error("Expected the new version's token and namespace released")
+	}
+	if platform.GetPlugin(oldID) == nil || len(registry.GetServicesBy(oldID)) != 1 {
+		t.Error("Expected the old version to keep serving")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the new version to be shut down")
+	}
+}
+
+func TestPlatform_ReplacePluginBakeAndRollback(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	host := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: startKVPlugin(t, host.URL, PluginServeConfig{}, kvMetadata("1.0.0"))}); err != nil {
+		t.Fatalf("AddPlugin failed: %v", err)
+	}
+	oldID := platform.depGraph.GetProviders("kv")[0]
+
+	// The new version fails once it has taken over
+	var switched atomic.Bool
+	failing := startKVPlugin(t, host.URL, PluginServeConfig{
+		HealthInterval: 10 * time.Millisecond,
+		Health: func(ctx context.Context) *connectpluginv1.GetHealthResponse {
+			if len(registry.GetServicesBy(oldID)) == 0 {
+				switched.Store(true)
+				return &connectpluginv1.GetHealthResponse{
+					State:  connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
+					Reason: "out of memory",
+				}
+			}
+			return &connectpluginv1.GetHealthResponse{State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY}
+		},
+	}, kvMetadata("1.1.0"))
+
+	result, err := platform.ReplacePluginWithOptions(ctx, oldID, PluginConfig{Endpoint: failing}, ReplaceOptions{BakePeriod: 5 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "out of memory") {
+		t.Fatalf("Expected rollback after the new version became unhealthy, got %v", err)
+	}
+	if !switched.Load() || result == nil || !result.RolledBack {
+		t.Fatalf("Expected rolled back result after the switch, got %+v", result)
+	}
+
+	// The old version serves kv again; the new one is gone
+	if platform.GetPlugin(oldID) == nil || platform.GetPlugin(result.RuntimeID) != nil {
+		t.Error("Expected the old version restored and the new one removed")
+	}
+	if providers := platform.depGraph.GetProviders("kv"); len(providers) != 1 || providers[0] != oldID {
+		t.Errorf("Expected only %s in the graph, got %v", oldID, providers)
+	}
+	provider, err := registry.SelectProvider("kv", "")
+	if err != nil || provider.RuntimeID != oldID {
+		t.Errorf("Expected kv routed to %s again, got %v, %v", oldID, provider, err)
+	}
+	if router.IsDraining(oldID) {
+		t.Error("Expected old version no longer draining")
+	}
+
+	// A healthy new version passes the bake period and replaces the old one
+	result, err = platform.ReplacePluginWithOptions(ctx, oldID,
+		PluginConfig{Endpoint: startKVPlugin(t, host.URL, PluginServeConfig{}, kvMetadata("1.2.0"))},
+		ReplaceOptions{BakePeriod: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("ReplacePluginWithOptions failed: %v", err)
+	}
+	if result.RolledBack || platform.GetPlugin(oldID) != nil || platform.GetPlugin(result.RuntimeID) == nil {
+		t.Errorf("Expected %s replaced by %s, got %+v", oldID, result.RuntimeID, result)
+	}
+	if provider, err := registry.SelectProvider("kv", ""); err != nil || provider.RuntimeID != result.RuntimeID {
+		t.Errorf("Expected kv routed to %s, got %v, %v", result.RuntimeID, provider, err)
+	}
+}
@@ -0,0 +1,286 @@
+package connectplugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
+)
+
+// ControlConfig configures a PluginControlServer.
+type ControlConfig struct {
+	// Health reports the plugin's health for GetHealth.
+	// Default: HEALTHY
+	Health func(ctx context.Context) *connectpluginv1.GetHealthResponse
+
+	// Reconfigure applies a configuration blob. Returning an error rejects
+	// it: connect errors keep their code, others become InvalidArgument.
+	// Default: nil (Reconfigure returns Unimplemented)
+	Reconfigure func(ctx context.Context, config []byte, contentType string) error
+
+	// Shutdown is called after a Shutdown request is acknowledged.
+	// Default: nil (acknowledge only)
+	Shutdown func(gracePeriod time.Duration, reason string)
+}
+
+// PluginControlServer implements the PluginControl service on the plugin
+// side. Wrap the plugin's service handlers with Middleware so that Drain
+// and Pause apply to them:
+//
+//	control := connectplugin.NewPluginControlServer(connectplugin.ControlConfig{})
+//	mux.Handle(control.Handler())
+//	mux.Handle(path, control.Middleware(handler))
+type PluginControlServer struct {
+	config ControlConfig
+
+	mu       sync.Mutex
+	inFlight int64
+	draining bool
+	paused   bool
+	version  string
+
+	// changed is closed and replaced when draining or paused changes
+	changed chan struct{}
+
+	// idle is closed when inFlight drops to zero (nil if nobody waits)
+	idle chan struct{}
+}
+
+// NewPluginControlServer creates a plugin-side PluginControl server.
+func NewPluginControlServer(config ControlConfig) *PluginControlServer {
+	return &PluginControlServer{
+		config:  config,
+		changed: make(chan struct{}),
+	}
+}
+
+// Handler returns the path and handler for the PluginControl service.
+func (s *PluginControlServer) Handler(opts ...connect.HandlerOption) (string, http.Handler) {
+	return connectpluginv1connect.NewPluginControlHandler(s, opts...)
+}
+
+// Middleware tracks in-flight requests to next. While draining, new
+// requests are rejected with Unavailable; while paused, they wait until
+// Resume or until their context ends.
+func (s *PluginControlServer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := s.begin(req.Context()); err != nil {
+			writeConnectError(w, req, err)
+			return
+		}
+		defer s.end()
+		next.ServeHTTP(w, req)
+	})
+}
+
+// begin admits a request, waiting while paused.
+func (s *PluginControlServer) begin(ctx context.Context) *connect.Error {
+	for {
+		s.mu.Lock()
+		if s.draining {
+			s.mu.Unlock()
+			return connect.NewError(connect.CodeUnavailable, errors.New("plugin is draining"))
+		}
+		if !s.paused {
+			s.inFlight++
+			s.mu.Unlock()
+			return nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			code := connect.CodeCanceled
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				code = connect.CodeDeadlineExceeded
+			}
+			return connect.NewError(code, errors.New("plugin is paused"))
+		}
+	}
+}
+
+// end completes a request admitted by begin.
+func (s *PluginControlServer) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inFlight--
+	if s.inFlight == 0 && s.idle != nil {
+		close(s.idle)
+		s.idle = nil
+	}
+}
+
+// setStateLocked updates draining and paused, waking paused requests.
+// Caller must hold lock.
+func (s *PluginControlServer) setStateLocked(draining, paused bool) {
+	if s.draining == draining && s.paused == paused {
+		return
+	}
+	s.draining, s.paused = draining, paused
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// InFlight returns the number of requests in flight.
+func (s *PluginControlServer) InFlight() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
+
+// Draining reports whether the plugin is draining.
+func (s *PluginControlServer) Draining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
+}
+
+// Paused reports whether the plugin is paused.
+func (s *PluginControlServer) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// ConfigVersion returns the version of the last configuration applied.
+func (s *PluginControlServer) ConfigVersion() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// WaitIdle blocks until no requests are in flight. Returns false if ctx
+// ended first.
+func (s *PluginControlServer) WaitIdle(ctx context.Context) bool {
+	s.mu.Lock()
+	if s.inFlight == 0 {
+		s.mu.Unlock()
+		return true
+	}
+	if s.idle == nil {
+		s.idle = make(chan struct{})
+	}
+	idle := s.idle
+	s.mu.Unlock()
+
+	select {
+	case <-idle:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// GetHealth implements the PluginControl GetHealth RPC.
+func (s *PluginControlServer) GetHealth(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.GetHealthRequest],
+) (*connect.Response[connectpluginv1.GetHealthResponse], error) {
+	if s.config.Health != nil {
+		return connect.NewResponse(s.config.Health(ctx)), nil
+	}
+	return connect.NewResponse(&connectpluginv1.GetHealthResponse{
+		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+	}), nil
+}
+
+// Shutdown implements the PluginControl Shutdown RPC.
+// New requests are rejected from now on; the Shutdown callback decides
+// when the process exits.
+func (s *PluginControlServer) Shutdown(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.ShutdownRequest],
+) (*connect.Response[connectpluginv1.ShutdownResponse], error) {
+	s.mu.Lock()
+	s.setStateLocked(true, false)
+	s.mu.Unlock()
+
+	if s.config.Shutdown != nil {
+		gracePeriod := time.Duration(req.Msg.GracePeriodSeconds) * time.Second
+		go s.config.Shutdown(gracePeriod, req.Msg.Reason)
+	}
+
+	return connect.NewResponse(&connectpluginv1.ShutdownResponse{
+		Acknowledged: true,
+	}), nil
+}
+
+// Drain implements the PluginControl Drain RPC.
+func (s *PluginControlServer) Drain(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.DrainRequest],
+) (*connect.Response[connectpluginv1.DrainResponse], error) {
+	s.mu.Lock()
+	s.setStateLocked(true, false)
+	s.mu.Unlock()
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(req.Msg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	drained := s.WaitIdle(waitCtx)
+
+	return connect.NewResponse(&connectpluginv1.DrainResponse{
+		Drained:  drained,
+		InFlight: s.InFlight(),
+	}), nil
+}
+
+// Pause implements the PluginControl Pause RPC.
+func (s *PluginControlServer) Pause(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.PauseRequest],
+) (*connect.Response[connectpluginv1.PauseResponse], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.draining {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("plugin is draining"))
+	}
+	s.setStateLocked(false, true)
+	return connect.NewResponse(&connectpluginv1.PauseResponse{}), nil
+}
+
+// Resume implements the PluginControl Resume RPC.
+func (s *PluginControlServer) Resume(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.ResumeRequest],
+) (*connect.Response[connectpluginv1.ResumeResponse], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setStateLocked(false, false)
+	return connect.NewResponse(&connectpluginv1.ResumeResponse{}), nil
+}
+
+// Reconfigure implements the PluginControl Reconfigure RPC.
+func (s *PluginControlServer) Reconfigure(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.ReconfigureRequest],
+) (*connect.Response[connectpluginv1.ReconfigureResponse], error) {
+	if s.config.Reconfigure == nil {
+		return nil, connect.NewError(connect.CodeUnimplemented, errors.New("plugin does not support reconfiguration"))
+	}
+
+	if err := s.config.Reconfigure(ctx, req.Msg.Config, req.Msg.ContentType); err != nil {
+		var connectErr *connect.Error
+		if errors.As(err, &connectErr) {
+			return nil, connectErr
+		}
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	s.mu.Lock()
+	s.version = req.Msg.Version
+	s.mu.Unlock()
+
+	return connect.NewResponse(&connectpluginv1.ReconfigureResponse{
+		Version: req.Msg.Version,
+	}), nil
+}
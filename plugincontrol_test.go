@@ -0,0 +1,170 @@
+package connectplugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// startControlledPlugin serves a PluginControlServer and a service handler
+// that blocks until release is closed.
+func startControlledPlugin(t *testing.T, config ControlConfig) (*PluginControlServer, *PluginControlClient, string, chan struct{}) {
+	t.Helper()
+
+	release := make(chan struct{})
+	control := NewPluginControlServer(config)
+
+	mux := http.NewServeMux()
+	mux.Handle(control.Handler())
+	mux.Handle("/svc.v1.Svc/", control.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return control, NewPluginControlClient(server.URL, http.DefaultClient), server.URL + "/svc.v1.Svc/Call", release
+}
+
+func TestPluginControl_Drain(t *testing.T) {
+	control, client, url, release := startControlledPlugin(t, ControlConfig{})
+	ctx := context.Background()
+
+	// One request in flight
+	done := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(url, "application/json", nil)
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	for control.InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Drain times out while the request is in flight
+	drained, inFlight, err := client.Drain(ctx, 0, "test")
+	if err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if drained || inFlight != 1 {
+		t.Errorf("Expected 1 request in flight, got drained=%v inFlight=%d", drained, inFlight)
+	}
+
+	// New work is rejected while draining
+	resp, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while draining, got %d", resp.StatusCode)
+	}
+
+	// Drain completes once in-flight work finishes, even with a timeout
+	// under a second
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	drained, inFlight, err = client.Drain(ctx, 100*time.Millisecond, "test")
+	if err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if !drained || inFlight != 0 {
+		t.Errorf("Expected drained, got drained=%v inFlight=%d", drained, inFlight)
+	}
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("Expected in-flight request to complete, got %d", code)
+	}
+
+	// Resume accepts work again
+	if err := client.Resume(ctx); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if control.Draining() {
+		t.Error("Expected draining to end on Resume")
+	}
+}
+
+func TestPluginControl_PauseResume(t *testing.T) {
+	control, client, url, release := startControlledPlugin(t, ControlConfig{})
+	close(release)
+	ctx := context.Background()
+
+	if err := client.Pause(ctx, "maintenance"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if !control.Paused() {
+		t.Fatal("Expected plugin paused")
+	}
+
+	// Requests wait while paused
+	done := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(url, "application/json", nil)
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+
+	select {
+	case code := <-done:
+		t.Fatalf("Expected request to wait while paused, got %d", code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := client.Resume(ctx); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("Expected request to complete after Resume, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected request to proceed after Resume")
+	}
+}
+
+func TestPluginControl_Reconfigure(t *testing.T) {
+	var applied []byte
+	control, client, _, _ := startControlledPlugin(t, ControlConfig{
+		Reconfigure: func(ctx context.Context, config []byte, contentType string) error {
+			if contentType != "application/json" {
+				return errors.New("unsupported content type")
+			}
+			applied = config
+			return nil
+		},
+	})
+	ctx := context.Background()
+
+	version, err := client.Reconfigure(ctx, []byte(`{"ttl":30}`), "application/json", "v2")
+	if err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if version != "v2" || control.ConfigVersion() != "v2" || string(applied) != `{"ttl":30}` {
+		t.Errorf("Expected config v2 applied, got version=%q applied=%q", version, applied)
+	}
+
+	// Rejected configs keep the previous version
+	_, err = client.Reconfigure(ctx, []byte("ttl: 30"), "application/yaml", "v3")
+	if connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+	if control.ConfigVersion() != "v2" {
+		t.Errorf("Expected version v2 kept, got %q", control.ConfigVersion())
+	}
+}
@@ -16,13 +16,14 @@ type PluginIdentityClient struct {
 }
 
 // NewPluginIdentityClient creates a client for calling a plugin's PluginIdentity service.
-func NewPluginIdentityClient(baseURL string, httpClient connect.HTTPClient) *PluginIdentityClient {
+// Pass WithHostSecret for plugins that require host authentication.
+func NewPluginIdentityClient(baseURL string, httpClient connect.HTTPClient, opts ...connect.ClientOption) *PluginIdentityClient {
 	if httpClient == nil {
 		httpClient = &http.Client{}
 	}
 
 	return &PluginIdentityClient{
-		client: connectpluginv1connect.NewPluginIdentityClient(httpClient, baseURL),
+		client: connectpluginv1connect.NewPluginIdentityClient(httpClient, baseURL, opts...),
 	}
 }
 
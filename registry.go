@@ -3,8 +3,11 @@ package connectplugin
 import (
 	"context"
 	"fmt"
+	"log"
 	"math/rand"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,10 +35,15 @@ const (
 
 // ServiceRegistry manages plugin-to-plugin service discovery.
 // Tracks service providers and handles registration/discovery.
+//
+// Plugins can be assigned to a namespace (tenant) with SetNamespace. Service
+// types are scoped to a namespace: a plugin only discovers, watches and is
+// routed to providers in its own namespace. Plugins without a namespace share
+// the default ("") namespace.
 type ServiceRegistry struct {
 	mu sync.RWMutex
 
-	// providers maps service type to list of providers
+	// providers maps service key (see serviceKey) to list of providers
 	providers map[string][]*ServiceProvider
 
 	// registrations maps registration_id to provider (for unregister)
@@ -44,30 +52,53 @@ type ServiceRegistry struct {
 	// selection maps service type to selection strategy (host config)
 	selection map[string]SelectionStrategy
 
-	// roundRobinIndex tracks position for round-robin selection
+	// traffic maps service key to traffic policy (host config)
+	traffic map[string]*TrafficPolicy
+
+	// roundRobinIndex tracks position for round-robin selection (by service key)
 	roundRobinIndex map[string]int
 
 	// allowedServices maps runtime_id to allowed service types for authorization
 	allowedServices map[string][]string
 
+	// namespaces maps runtime_id to its namespace (host config)
+	namespaces map[string]string
+
+	// admins holds identities that may unregister any registration
+	admins map[string]bool
+
 	// lifecycleServer for checking provider health
 	lifecycleServer *LifecycleServer
 
-	// watchers tracks clients watching service types
+	// watchers tracks clients watching service keys
 	watchers map[string][]*serviceWatcher
+
+	// revision is the last assigned state revision (shared across service types)
+	revision uint64
+
+	// revisions maps service key to the revision of its latest change
+	revisions map[string]uint64
+
+	// store persists registrations (nil = in-memory only)
+	store StateStore
 }
 
 // serviceWatcher represents a client watching a service type.
+// Holds at most one pending event: newer states replace older unsent ones.
 type serviceWatcher struct {
-	ch     chan *connectpluginv1.WatchServiceEvent
-	ctx    context.Context
-	cancel context.CancelFunc
+	mu      sync.Mutex
+	pending *connectpluginv1.WatchServiceEvent
+	notify  chan struct{} // capacity 1, signals pending event
+	ctx     context.Context
+	cancel  context.CancelFunc
 }
 
 // ServiceProvider represents a registered service provider.
+// The registration is owned by RuntimeID.
 type ServiceProvider struct {
 	RegistrationID string
 	RuntimeID      string
+	Namespace      string
 	ServiceType    string
 	Version        string
 	EndpointPath   string
@@ -77,14 +108,45 @@ type ServiceProvider struct {
 
 // NewServiceRegistry creates a new service registry.
 func NewServiceRegistry(lifecycle *LifecycleServer) *ServiceRegistry {
-	return &ServiceRegistry{
+	r := &ServiceRegistry{
 		providers:       make(map[string][]*ServiceProvider),
 		registrations:   make(map[string]*ServiceProvider),
 		selection:       make(map[string]SelectionStrategy),
+		traffic:         make(map[string]*TrafficPolicy),
 		roundRobinIndex: make(map[string]int),
 		allowedServices: make(map[string][]string),
+		namespaces:      make(map[string]string),
+		admins:          make(map[string]bool),
 		lifecycleServer: lifecycle,
 		watchers:        make(map[string][]*serviceWatcher),
+		// Seed from the clock so revisions are never reused across host restarts
+		revision:  uint64(time.Now().UnixNano()),
+		revisions: make(map[string]uint64),
+	}
+	if lifecycle != nil {
+		lifecycle.OnHealthChange(r.handleHealthChange)
+	}
+	return r
+}
+
+// handleHealthChange notifies watchers of a plugin's services when its
+// health changes, and drops the registrations of plugins removed for
+// missing heartbeats.
+func (r *ServiceRegistry) handleHealthChange(change HealthChange) {
+	if change.Removed {
+		r.UnregisterPluginServices(change.RuntimeID)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, providers := range r.providers {
+		for _, provider := range providers {
+			if provider.RuntimeID == change.RuntimeID {
+				r.notifyWatchersLocked(key)
+				break
+			}
+		}
 	}
 }
 
@@ -96,6 +158,62 @@ func (r *ServiceRegistry) SetAllowedServices(runtimeID string, serviceTypes []st
 	r.allowedServices[runtimeID] = serviceTypes
 }
 
+// SetNamespace assigns a plugin to a namespace (tenant).
+// Services it registers, discovers or watches are scoped to that namespace.
+// Should be called before the plugin registers services. An empty namespace
+// restores the default namespace; the Platform does this when it removes a
+// plugin. Assignments are persisted with the attached StateStore.
+func (r *ServiceRegistry) SetNamespace(runtimeID, namespace string) error {
+	if namespace != "" {
+		if err := ValidateNamespace(namespace); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.namespaces[runtimeID] == namespace {
+		return nil
+	}
+	if r.store != nil {
+		rec := StateRecord{Kind: RecordSetNamespace, RuntimeID: runtimeID, Namespace: namespace}
+		if err := r.store.Append(rec); err != nil {
+			return fmt.Errorf("failed to persist namespace: %w", err)
+		}
+	}
+	if namespace == "" {
+		delete(r.namespaces, runtimeID)
+	} else {
+		r.namespaces[runtimeID] = namespace
+	}
+	return nil
+}
+
+// Namespace returns the namespace assigned to a plugin ("" for the default namespace).
+func (r *ServiceRegistry) Namespace(runtimeID string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.namespaces[runtimeID]
+}
+
+// SetAdminIdentities sets the authenticated identities that may unregister
+// any registration, not only their own.
+func (r *ServiceRegistry) SetAdminIdentities(identities ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.admins = make(map[string]bool, len(identities))
+	for _, identity := range identities {
+		r.admins[identity] = true
+	}
+}
+
+// isAdmin reports whether an identity is an admin identity.
+func (r *ServiceRegistry) isAdmin(identity string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.admins[identity]
+}
+
 // SetSelectionStrategy configures the selection strategy for a service type.
 // This is called by the host during configuration.
 func (r *ServiceRegistry) SetSelectionStrategy(serviceType string, strategy SelectionStrategy) {
@@ -123,13 +241,10 @@ func (r *ServiceRegistry) RegisterService(
 		return nil, connect.NewError(connect.CodeInvalidArgument, err)
 	}
 
-	// Extract runtime_id from request headers
-	runtimeID := req.Header().Get("X-Plugin-Runtime-ID")
-	if runtimeID == "" {
-		return nil, connect.NewError(
-			connect.CodeInvalidArgument,
-			fmt.Errorf("X-Plugin-Runtime-ID header required"),
-		)
+	// Use the runtime ID verified by RuntimeAuthInterceptor
+	runtimeID, err := authenticatedRuntimeID(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	r.mu.Lock()
@@ -161,6 +276,7 @@ func (r *ServiceRegistry) RegisterService(
 	provider := &ServiceProvider{
 		RegistrationID: registrationID,
 		RuntimeID:      runtimeID,
+		Namespace:      r.namespaces[runtimeID],
 		ServiceType:    req.Msg.ServiceType,
 		Version:        req.Msg.Version,
 		EndpointPath:   req.Msg.EndpointPath,
@@ -168,14 +284,27 @@ func (r *ServiceRegistry) RegisterService(
 		RegisteredAt:   time.Now(),
 	}
 
+	// Persist before applying so a restarted host never loses an acknowledged registration
+	if r.store != nil {
+		if err := r.store.Append(StateRecord{Kind: RecordRegisterService, Provider: provider}); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to persist registration: %w", err))
+		}
+	}
+
 	// Add to providers list (multi-provider support)
-	r.providers[req.Msg.ServiceType] = append(r.providers[req.Msg.ServiceType], provider)
+	key := provider.serviceKey()
+	r.providers[key] = append(r.providers[key], provider)
 
 	// Store registration for unregister lookup
 	r.registrations[registrationID] = provider
 
 	// Notify watchers that service is now available
-	r.notifyWatchersLocked(req.Msg.ServiceType)
+	r.notifyWatchersLocked(key)
+
+	// Start the provider's heartbeat window, if heartbeats are required
+	if r.lifecycleServer != nil {
+		r.lifecycleServer.trackHeartbeat(runtimeID)
+	}
 
 	return connect.NewResponse(&connectpluginv1.RegisterServiceResponse{
 		RegistrationId: registrationID,
@@ -183,10 +312,18 @@ func (r *ServiceRegistry) RegisterService(
 }
 
 // UnregisterService handles service unregistration.
+// Only the plugin that owns the registration or an admin identity
+// (see SetAdminIdentities) may unregister it.
 func (r *ServiceRegistry) UnregisterService(
 	ctx context.Context,
 	req *connect.Request[connectpluginv1.UnregisterServiceRequest],
 ) (*connect.Response[connectpluginv1.UnregisterServiceResponse], error) {
+	auth := GetAuthContext(ctx)
+	if auth == nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated,
+			fmt.Errorf("authentication required"))
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -199,12 +336,33 @@ func (r *ServiceRegistry) UnregisterService(
 		)
 	}
 
+	// Check ownership
+	if !r.admins[auth.Identity] {
+		runtimeID, err := authenticatedRuntimeID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if provider.RuntimeID != runtimeID {
+			return nil, connect.NewError(
+				connect.CodePermissionDenied,
+				fmt.Errorf("plugin %s does not own registration %s", runtimeID, req.Msg.RegistrationId),
+			)
+		}
+	}
+
+	if r.store != nil {
+		rec := StateRecord{Kind: RecordUnregisterService, RegistrationID: req.Msg.RegistrationId}
+		if err := r.store.Append(rec); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to persist unregistration: %w", err))
+		}
+	}
+
 	// Remove from providers list
-	serviceType := provider.ServiceType
-	providers := r.providers[serviceType]
+	key := provider.serviceKey()
+	providers := r.providers[key]
 	for i, p := range providers {
 		if p.RegistrationID == req.Msg.RegistrationId {
-			r.providers[serviceType] = append(providers[:i], providers[i+1:]...)
+			r.providers[key] = append(providers[:i], providers[i+1:]...)
 			break
 		}
 	}
@@ -213,7 +371,7 @@ func (r *ServiceRegistry) UnregisterService(
 	delete(r.registrations, req.Msg.RegistrationId)
 
 	// Notify watchers about service state change
-	r.notifyWatchersLocked(serviceType)
+	r.notifyWatchersLocked(key)
 
 	return connect.NewResponse(&connectpluginv1.UnregisterServiceResponse{}), nil
 }
@@ -233,32 +391,136 @@ func (r *ServiceRegistry) UnregisterPluginServices(runtimeID string) {
 	}
 
 	// Remove each registration
+	changed := make(map[string]bool)
 	for _, regID := range toRemove {
 		provider := r.registrations[regID]
-		serviceType := provider.ServiceType
+		key := provider.serviceKey()
+		changed[key] = true
 
 		// Remove from providers list
-		providers := r.providers[serviceType]
+		providers := r.providers[key]
 		for i, p := range providers {
 			if p.RegistrationID == regID {
-				r.providers[serviceType] = append(providers[:i], providers[i+1:]...)
+				r.providers[key] = append(providers[:i], providers[i+1:]...)
 				break
 			}
 		}
 
 		// Remove from registrations map
 		delete(r.registrations, regID)
+
+		r.persistUnregisterLocked(regID)
+	}
+
+	// Notify watchers about removed providers
+	for key := range changed {
+		r.notifyWatchersLocked(key)
+	}
+}
+
+// reinstate re-adds registrations removed by UnregisterPluginServices, e.g.
+// when a replacement is rolled back. Registration IDs are kept.
+func (r *ServiceRegistry) reinstate(providers []*ServiceProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, provider := range providers {
+		if _, exists := r.registrations[provider.RegistrationID]; exists {
+			continue
+		}
+		if r.store != nil {
+			if err := r.store.Append(StateRecord{Kind: RecordRegisterService, Provider: provider}); err != nil {
+				log.Printf("[PERSIST] Failed to record registration of %s: %v", provider.RegistrationID, err)
+			}
+		}
+		key := provider.serviceKey()
+		r.providers[key] = append(r.providers[key], provider)
+		r.registrations[provider.RegistrationID] = provider
+		r.notifyWatchersLocked(key)
+	}
+}
+
+// SetStateStore attaches a store that registrations are persisted to.
+// Use RestoreState to also load previously persisted registrations.
+func (r *ServiceRegistry) SetStateStore(store StateStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store = store
+}
+
+// restore loads persisted registrations and namespace assignments.
+// Providers are re-added in registration order so SelectionFirst is stable across restarts.
+func (r *ServiceRegistry) restore(registrations map[string]*ServiceProvider, namespaces map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for runtimeID, namespace := range namespaces {
+		r.namespaces[runtimeID] = namespace
+	}
+
+	restored := make([]*ServiceProvider, 0, len(registrations))
+	for _, provider := range registrations {
+		restored = append(restored, provider)
+	}
+	sort.Slice(restored, func(i, j int) bool {
+		return restored[i].RegisteredAt.Before(restored[j].RegisteredAt)
+	})
+
+	for _, provider := range restored {
+		if _, exists := r.registrations[provider.RegistrationID]; exists {
+			continue
+		}
+		key := provider.serviceKey()
+		r.providers[key] = append(r.providers[key], provider)
+		r.registrations[provider.RegistrationID] = provider
+		r.notifyWatchersLocked(key)
+	}
+}
+
+// persistUnregisterLocked records removal of a registration.
+// Used by host-initiated removal, which has no caller to report errors to.
+// Caller must hold lock.
+func (r *ServiceRegistry) persistUnregisterLocked(registrationID string) {
+	if r.store == nil {
+		return
+	}
+	rec := StateRecord{Kind: RecordUnregisterService, RegistrationID: registrationID}
+	if err := r.store.Append(rec); err != nil {
+		log.Printf("[PERSIST] Failed to record unregistration of %s: %v", registrationID, err)
 	}
 }
 
-// SelectProvider selects a single provider for the given service type.
+// SelectProvider selects a single provider for the given service type
+// in the default namespace.
 // This is where the host-controlled selection happens.
 func (r *ServiceRegistry) SelectProvider(serviceType string, minVersion string) (*ServiceProvider, error) {
+	return r.SelectProviderInNamespace("", serviceType, minVersion)
+}
+
+// SelectProviderInNamespace selects a single provider for the given service
+// type, considering only providers in the given namespace.
+func (r *ServiceRegistry) SelectProviderInNamespace(namespace, serviceType, minVersion string) (*ServiceProvider, error) {
+	return r.selectProvider(namespace, serviceType, minVersion, nil)
+}
+
+// selectProvider selects a provider like SelectProviderInNamespace, ignoring
+// providers for which skip returns true.
+func (r *ServiceRegistry) selectProvider(namespace, serviceType, minVersion string, skip func(*ServiceProvider) bool) (*ServiceProvider, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	// Get all providers for this service type
-	allProviders := r.providers[serviceType]
+	key := serviceKey(namespace, serviceType)
+	allProviders := r.providers[key]
+	if skip != nil {
+		candidates := make([]*ServiceProvider, 0, len(allProviders))
+		for _, p := range allProviders {
+			if !skip(p) {
+				candidates = append(candidates, p)
+			}
+		}
+		allProviders = candidates
+	}
 	if len(allProviders) == 0 {
 		return nil, fmt.Errorf("no providers for service %q", serviceType)
 	}
@@ -277,9 +539,14 @@ func (r *ServiceRegistry) SelectProvider(serviceType string, minVersion string)
 			serviceType)
 	}
 
+	// Apply traffic split (canary releases)
+	if policy := r.traffic[key]; policy != nil {
+		available = policy.splitProviders(available)
+	}
+
 	// Apply selection strategy
 	strategy := r.selection[serviceType] // Defaults to 0 (SelectionFirst)
-	return r.applyStrategy(serviceType, available, strategy), nil
+	return r.applyStrategy(key, available, strategy), nil
 }
 
 // filterCompatibleVersions filters providers by minimum version.
@@ -292,14 +559,19 @@ func (r *ServiceRegistry) filterCompatibleVersions(providers []*ServiceProvider,
 
 	compatible := make([]*ServiceProvider, 0, len(providers))
 	for _, p := range providers {
-		// Simple string comparison - TODO: use semver
-		if p.Version >= minVersion {
+		if versionSatisfies(p.Version, minVersion) {
 			compatible = append(compatible, p)
 		}
 	}
 	return compatible
 }
 
+// versionSatisfies reports whether version meets minVersion ("" = any).
+func versionSatisfies(version, minVersion string) bool {
+	// Simple string comparison - TODO: use semver
+	return minVersion == "" || version >= minVersion
+}
+
 // filterAvailable filters providers by health state.
 // Only returns providers that are Healthy or Degraded (not Unhealthy).
 func (r *ServiceRegistry) filterAvailable(providers []*ServiceProvider) []*ServiceProvider {
@@ -319,7 +591,7 @@ func (r *ServiceRegistry) filterAvailable(providers []*ServiceProvider) []*Servi
 
 // applyStrategy applies the selection strategy to choose a provider.
 // Caller must hold lock.
-func (r *ServiceRegistry) applyStrategy(serviceType string, providers []*ServiceProvider, strategy SelectionStrategy) *ServiceProvider {
+func (r *ServiceRegistry) applyStrategy(key string, providers []*ServiceProvider, strategy SelectionStrategy) *ServiceProvider {
 	if len(providers) == 0 {
 		return nil
 	}
@@ -329,9 +601,9 @@ func (r *ServiceRegistry) applyStrategy(serviceType string, providers []*Service
 		return providers[0]
 
 	case SelectionRoundRobin:
-		idx := r.roundRobinIndex[serviceType]
+		idx := r.roundRobinIndex[key]
 		provider := providers[idx%len(providers)]
-		r.roundRobinIndex[serviceType] = (idx + 1) % len(providers)
+		r.roundRobinIndex[key] = (idx + 1) % len(providers)
 		return provider
 
 	case SelectionRandom:
@@ -346,9 +618,16 @@ func (r *ServiceRegistry) applyStrategy(serviceType string, providers []*Service
 	}
 }
 
-// HasService checks if a service type is available with the given minimum version.
+// HasService checks if a service type is available with the given minimum
+// version in the default namespace.
 func (r *ServiceRegistry) HasService(serviceType string, minVersion string) bool {
-	_, err := r.SelectProvider(serviceType, minVersion)
+	return r.HasServiceInNamespace("", serviceType, minVersion)
+}
+
+// HasServiceInNamespace checks if a service type is available with the given
+// minimum version in the given namespace.
+func (r *ServiceRegistry) HasServiceInNamespace(namespace, serviceType, minVersion string) bool {
+	_, err := r.SelectProviderInNamespace(namespace, serviceType, minVersion)
 	return err == nil
 }
 
@@ -394,13 +673,21 @@ func (r *ServiceRegistry) GetServicesBy(runtimeID string) []*ServiceProvider {
 	return services
 }
 
-// GetAllProviders returns all providers for a given service type.
+// GetAllProviders returns all providers for a given service type in the
+// default namespace.
 // Does not filter by version or health.
 func (r *ServiceRegistry) GetAllProviders(serviceType string) []*ServiceProvider {
+	return r.GetAllProvidersInNamespace("", serviceType)
+}
+
+// GetAllProvidersInNamespace returns all providers for a given service type
+// in the given namespace.
+// Does not filter by version or health.
+func (r *ServiceRegistry) GetAllProvidersInNamespace(namespace, serviceType string) []*ServiceProvider {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	providers, ok := r.providers[serviceType]
+	providers, ok := r.providers[serviceKey(namespace, serviceType)]
 	if !ok {
 		return nil
 	}
@@ -416,8 +703,9 @@ func (r *ServiceRegistry) DiscoverService(
 	ctx context.Context,
 	req *connect.Request[connectpluginv1.DiscoverServiceRequest],
 ) (*connect.Response[connectpluginv1.DiscoverServiceResponse], error) {
-	// Select provider using host strategy
-	provider, err := r.SelectProvider(req.Msg.ServiceType, req.Msg.MinVersion)
+	// Select provider using host strategy, within the caller's namespace
+	namespace := r.callerNamespace(ctx)
+	provider, err := r.SelectProviderInNamespace(namespace, req.Msg.ServiceType, req.Msg.MinVersion)
 	if err != nil {
 		return nil, connect.NewError(connect.CodeNotFound, err)
 	}
@@ -432,7 +720,7 @@ func (r *ServiceRegistry) DiscoverService(
 
 	// Check if this is the only provider
 	r.mu.RLock()
-	singleProvider := len(r.providers[req.Msg.ServiceType]) == 1
+	singleProvider := len(r.providers[serviceKey(namespace, req.Msg.ServiceType)]) == 1
 	r.mu.RUnlock()
 
 	return connect.NewResponse(&connectpluginv1.DiscoverServiceResponse{
@@ -443,45 +731,52 @@ func (r *ServiceRegistry) DiscoverService(
 
 // WatchService implements the watch RPC.
 // Streams service availability updates when providers register/unregister.
+//
+// Events are never dropped: if the watcher falls behind, intermediate states
+// are coalesced and the latest state is delivered on the next send.
 func (r *ServiceRegistry) WatchService(
 	ctx context.Context,
 	req *connect.Request[connectpluginv1.WatchServiceRequest],
 	stream *connect.ServerStream[connectpluginv1.WatchServiceEvent],
 ) error {
-	serviceType := req.Msg.ServiceType
+	// Watch within the caller's namespace
+	key := serviceKey(r.callerNamespace(ctx), req.Msg.ServiceType)
 
 	r.mu.Lock()
 
 	// Create watcher
 	wctx, cancel := context.WithCancel(ctx)
 	watcher := &serviceWatcher{
-		ch:     make(chan *connectpluginv1.WatchServiceEvent, 10),
+		notify: make(chan struct{}, 1),
 		ctx:    wctx,
 		cancel: cancel,
 	}
 
 	// Register watcher
-	r.watchers[serviceType] = append(r.watchers[serviceType], watcher)
+	r.watchers[key] = append(r.watchers[key], watcher)
 
-	// Send initial state
-	initialEvent := r.buildServiceEventLocked(serviceType)
-	watcher.ch <- initialEvent
+	// Send initial state (always sent - it also opens the stream for the client).
+	// A resuming watcher that is already up to date gets it marked unchanged.
+	initialEvent := r.buildServiceEventLocked(key)
+	if req.Msg.ResumeFromRevision != 0 && req.Msg.ResumeFromRevision == initialEvent.Revision {
+		initialEvent.Unchanged = true
+	}
+	watcher.publish(initialEvent)
 
 	r.mu.Unlock()
 
 	// Cleanup on exit
 	defer func() {
 		r.mu.Lock()
-		watchers := r.watchers[serviceType]
+		watchers := r.watchers[key]
 		for i, w := range watchers {
 			if w == watcher {
-				r.watchers[serviceType] = append(watchers[:i], watchers[i+1:]...)
+				r.watchers[key] = append(watchers[:i], watchers[i+1:]...)
 				break
 			}
 		}
 		r.mu.Unlock()
 		cancel()
-		close(watcher.ch)
 	}()
 
 	// Stream events
@@ -490,9 +785,10 @@ func (r *ServiceRegistry) WatchService(
 		case <-ctx.Done():
 			return nil
 
-		case event, ok := <-watcher.ch:
-			if !ok {
-				return nil
+		case <-watcher.notify:
+			event := watcher.take()
+			if event == nil {
+				continue
 			}
 
 			if err := stream.Send(event); err != nil {
@@ -502,73 +798,155 @@ func (r *ServiceRegistry) WatchService(
 	}
 }
 
-// notifyWatchersLocked notifies all watchers of a service type about state changes.
+// publish replaces the pending event with a newer one and wakes the sender.
+// Never blocks: a slow watcher only ever has the latest state pending.
+func (w *serviceWatcher) publish(event *connectpluginv1.WatchServiceEvent) {
+	w.mu.Lock()
+	w.pending = event
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+		// Wake-up already pending
+	}
+}
+
+// take returns and clears the pending event.
+func (w *serviceWatcher) take() *connectpluginv1.WatchServiceEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	event := w.pending
+	w.pending = nil
+	return event
+}
+
+// notifyWatchersLocked records a state change for a service key and
+// notifies its watchers. Must be called on every change so revisions advance
+// even when nobody is watching.
 // Caller must hold lock.
-func (r *ServiceRegistry) notifyWatchersLocked(serviceType string) {
-	event := r.buildServiceEventLocked(serviceType)
+func (r *ServiceRegistry) notifyWatchersLocked(key string) {
+	r.revision++
+	r.revisions[key] = r.revision
 
-	for _, watcher := range r.watchers[serviceType] {
-		select {
-		case watcher.ch <- event:
-		default:
-			// Watcher not reading, skip
-		}
+	watchers := r.watchers[key]
+	if len(watchers) == 0 {
+		return
+	}
+
+	event := r.buildServiceEventLocked(key)
+	for _, watcher := range watchers {
+		watcher.publish(event)
 	}
 }
 
-// buildServiceEventLocked builds a WatchServiceEvent for the current state of a service.
+// serviceRevision returns the revision of the latest provider change for a
+// service type in a namespace.
+func (r *ServiceRegistry) serviceRevision(namespace, serviceType string) uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.revisions[serviceKey(namespace, serviceType)]
+}
+
+// buildServiceEventLocked builds a WatchServiceEvent for the current state of a service key.
+// The event carries every registered provider with its own state; Endpoint is
+// the preferred provider (first healthy, else first degraded).
 // Caller must hold lock.
-func (r *ServiceRegistry) buildServiceEventLocked(serviceType string) *connectpluginv1.WatchServiceEvent {
-	// Try to select a provider
-	providers := r.providers[serviceType]
-	if len(providers) == 0 {
-		// No providers - service unavailable
-		return &connectpluginv1.WatchServiceEvent{
-			ServiceType: serviceType,
-			State:       connectpluginv1.ServiceState_SERVICE_STATE_UNAVAILABLE,
+func (r *ServiceRegistry) buildServiceEventLocked(key string) *connectpluginv1.WatchServiceEvent {
+	_, serviceType := splitServiceKey(key)
+	event := &connectpluginv1.WatchServiceEvent{
+		ServiceType: serviceType,
+		State:       connectpluginv1.ServiceState_SERVICE_STATE_UNAVAILABLE,
+		Revision:    r.revisions[key],
+	}
+
+	var degraded *connectpluginv1.ServiceEndpoint
+	for _, provider := range r.providers[key] {
+		endpoint := &connectpluginv1.ServiceEndpoint{
+			ProviderId:  provider.RuntimeID,
+			Version:     provider.Version,
+			EndpointUrl: fmt.Sprintf("/services/%s/%s", provider.ServiceType, provider.RuntimeID),
+			Metadata:    provider.Metadata,
+			State:       r.providerStateLocked(provider),
 		}
-	}
+		event.Endpoints = append(event.Endpoints, endpoint)
 
-	// Filter by health
-	available := r.filterAvailable(providers)
-	if len(available) == 0 {
-		// All providers unhealthy
-		return &connectpluginv1.WatchServiceEvent{
-			ServiceType: serviceType,
-			State:       connectpluginv1.ServiceState_SERVICE_STATE_UNAVAILABLE,
+		switch endpoint.State {
+		case connectpluginv1.ServiceState_SERVICE_STATE_AVAILABLE:
+			if event.Endpoint == nil {
+				event.Endpoint = endpoint
+				event.State = connectpluginv1.ServiceState_SERVICE_STATE_AVAILABLE
+			}
+		case connectpluginv1.ServiceState_SERVICE_STATE_DEGRADED:
+			if degraded == nil {
+				degraded = endpoint
+			}
 		}
 	}
 
-	// Get first available provider
-	provider := available[0]
+	// No healthy provider - fall back to a degraded one
+	if event.Endpoint == nil && degraded != nil {
+		event.Endpoint = degraded
+		event.State = connectpluginv1.ServiceState_SERVICE_STATE_DEGRADED
+	}
 
-	// Check if provider is degraded
-	state := connectpluginv1.ServiceState_SERVICE_STATE_AVAILABLE
-	if r.lifecycleServer != nil {
-		healthState := r.lifecycleServer.GetHealthState(provider.RuntimeID)
-		if healthState != nil && healthState.State == connectpluginv1.HealthState_HEALTH_STATE_DEGRADED {
-			state = connectpluginv1.ServiceState_SERVICE_STATE_DEGRADED
-		}
+	return event
+}
+
+// providerStateLocked maps a provider's health to a ServiceState.
+// Caller must hold lock.
+func (r *ServiceRegistry) providerStateLocked(provider *ServiceProvider) connectpluginv1.ServiceState {
+	if r.lifecycleServer == nil {
+		return connectpluginv1.ServiceState_SERVICE_STATE_AVAILABLE
+	}
+	if !r.lifecycleServer.ShouldRouteTraffic(provider.RuntimeID) {
+		return connectpluginv1.ServiceState_SERVICE_STATE_UNAVAILABLE
+	}
+	healthState := r.lifecycleServer.GetHealthState(provider.RuntimeID)
+	if healthState != nil && healthState.State == connectpluginv1.HealthState_HEALTH_STATE_DEGRADED {
+		return connectpluginv1.ServiceState_SERVICE_STATE_DEGRADED
 	}
+	return connectpluginv1.ServiceState_SERVICE_STATE_AVAILABLE
+}
 
-	// Build endpoint
-	endpoint := &connectpluginv1.ServiceEndpoint{
-		ProviderId:  provider.RuntimeID,
-		Version:     provider.Version,
-		EndpointUrl: fmt.Sprintf("/services/%s/%s", provider.ServiceType, provider.RuntimeID),
-		Metadata:    provider.Metadata,
+// callerNamespace returns the namespace of the authenticated caller.
+// Unauthenticated callers use the default namespace.
+func (r *ServiceRegistry) callerNamespace(ctx context.Context) string {
+	auth := GetAuthContext(ctx)
+	if auth == nil {
+		return ""
 	}
+	return r.Namespace(auth.Identity)
+}
 
-	return &connectpluginv1.WatchServiceEvent{
-		ServiceType: serviceType,
-		State:       state,
-		Endpoint:    endpoint,
+// serviceKey returns the key a provider is indexed under.
+func (p *ServiceProvider) serviceKey() string {
+	return serviceKey(p.Namespace, p.ServiceType)
+}
+
+// serviceKey scopes a service type to a namespace: "{namespace}/{type}",
+// or just "{type}" in the default namespace. Service types cannot contain
+// "/", so keys never collide across namespaces.
+func serviceKey(namespace, serviceType string) string {
+	if namespace == "" {
+		return serviceType
+	}
+	return namespace + "/" + serviceType
+}
+
+// splitServiceKey is the inverse of serviceKey.
+func splitServiceKey(key string) (namespace, serviceType string) {
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
 	}
+	return "", key
 }
 
 // ServiceRegistryHandler returns the path and handler for the registry service.
-func ServiceRegistryHandler(server *ServiceRegistry) (string, http.Handler) {
-	return connectpluginv1connect.NewServiceRegistryHandler(server)
+// Pass connect.WithInterceptors(NewRuntimeAuthInterceptor(handshake)) so
+// callers are authenticated with their runtime token.
+func ServiceRegistryHandler(server *ServiceRegistry, opts ...connect.HandlerOption) (string, http.Handler) {
+	return connectpluginv1connect.NewServiceRegistryHandler(server, opts...)
 }
 
 // generateRegistrationID generates a unique registration ID.
@@ -2,11 +2,14 @@ package connectplugin
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"connectrpc.com/connect"
 	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
 )
 
 func TestServiceRegistry_RegisterAndUnregister(t *testing.T) {
@@ -19,9 +22,8 @@ func TestServiceRegistry_RegisterAndUnregister(t *testing.T) {
 		EndpointPath: "/logger.v1.Logger/",
 		Metadata:     map[string]string{"provider": "logger-a"},
 	})
-	req.Header().Set("X-Plugin-Runtime-ID", "logger-a-x7k2")
 
-	resp, err := registry.RegisterService(context.Background(), req)
+	resp, err := registry.RegisterService(runtimeContext("logger-a-x7k2"), req)
 	if err != nil {
 		t.Fatalf("RegisterService failed: %v", err)
 	}
@@ -41,7 +43,7 @@ func TestServiceRegistry_RegisterAndUnregister(t *testing.T) {
 		RegistrationId: registrationID,
 	})
 
-	_, err = registry.UnregisterService(context.Background(), unreq)
+	_, err = registry.UnregisterService(runtimeContext("logger-a-x7k2"), unreq)
 	if err != nil {
 		t.Fatalf("UnregisterService failed: %v", err)
 	}
@@ -61,8 +63,7 @@ func TestServiceRegistry_MultiProvider(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/logger.v1.Logger/",
 	})
-	reqA.Header().Set("X-Plugin-Runtime-ID", "logger-a-x7k2")
-	registry.RegisterService(context.Background(), reqA)
+	registry.RegisterService(runtimeContext("logger-a-x7k2"), reqA)
 
 	// Register logger-b
 	reqB := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
@@ -70,8 +71,7 @@ func TestServiceRegistry_MultiProvider(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/logger.v1.Logger/",
 	})
-	reqB.Header().Set("X-Plugin-Runtime-ID", "logger-b-y8m3")
-	registry.RegisterService(context.Background(), reqB)
+	registry.RegisterService(runtimeContext("logger-b-y8m3"), reqB)
 
 	// Both should be registered
 	provider, err := registry.SelectProvider("logger", "1.0.0")
@@ -103,8 +103,7 @@ func TestServiceRegistry_SelectionStrategies(t *testing.T) {
 			EndpointPath: "/cache.v1.Cache/",
 			Metadata:     map[string]string{"index": string(rune('0' + i))},
 		})
-		req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
-		registry.RegisterService(context.Background(), req)
+		registry.RegisterService(runtimeContext(runtimeID), req)
 	}
 
 	// Test First strategy
@@ -143,8 +142,7 @@ func TestServiceRegistry_VersionFiltering(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/api.v1.API/",
 	})
-	req1.Header().Set("X-Plugin-Runtime-ID", "api-v1")
-	registry.RegisterService(context.Background(), req1)
+	registry.RegisterService(runtimeContext("api-v1"), req1)
 
 	// Register v2.0.0
 	req2 := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
@@ -152,8 +150,7 @@ func TestServiceRegistry_VersionFiltering(t *testing.T) {
 		Version:      "2.0.0",
 		EndpointPath: "/api.v2.API/",
 	})
-	req2.Header().Set("X-Plugin-Runtime-ID", "api-v2")
-	registry.RegisterService(context.Background(), req2)
+	registry.RegisterService(runtimeContext("api-v2"), req2)
 
 	// Request minVersion 1.0.0 - should find both, return first (v1)
 	p, err := registry.SelectProvider("api", "1.0.0")
@@ -191,30 +188,26 @@ func TestServiceRegistry_HealthFiltering(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/db.v1.DB/",
 	})
-	req1.Header().Set("X-Plugin-Runtime-ID", "db-healthy")
-	registry.RegisterService(context.Background(), req1)
+	registry.RegisterService(runtimeContext("db-healthy"), req1)
 
 	req2 := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
 		ServiceType:  "db",
 		Version:      "1.0.0",
 		EndpointPath: "/db.v1.DB/",
 	})
-	req2.Header().Set("X-Plugin-Runtime-ID", "db-unhealthy")
-	registry.RegisterService(context.Background(), req2)
+	registry.RegisterService(runtimeContext("db-unhealthy"), req2)
 
 	// Mark db-healthy as HEALTHY
 	healthReq1 := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
 	})
-	healthReq1.Header().Set("X-Plugin-Runtime-ID", "db-healthy")
-	lifecycle.ReportHealth(context.Background(), healthReq1)
+	lifecycle.ReportHealth(runtimeContext("db-healthy"), healthReq1)
 
 	// Mark db-unhealthy as UNHEALTHY
 	healthReq2 := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
 	})
-	healthReq2.Header().Set("X-Plugin-Runtime-ID", "db-unhealthy")
-	lifecycle.ReportHealth(context.Background(), healthReq2)
+	lifecycle.ReportHealth(runtimeContext("db-unhealthy"), healthReq2)
 
 	// SelectProvider should only return healthy one
 	p, err := registry.SelectProvider("db", "1.0.0")
@@ -235,16 +228,14 @@ func TestServiceRegistry_UnregisterPluginServices(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/logger.v1.Logger/",
 	})
-	req1.Header().Set("X-Plugin-Runtime-ID", "multi-plugin-abc")
-	registry.RegisterService(context.Background(), req1)
+	registry.RegisterService(runtimeContext("multi-plugin-abc"), req1)
 
 	req2 := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
 		ServiceType:  "metrics",
 		Version:      "1.0.0",
 		EndpointPath: "/metrics.v1.Metrics/",
 	})
-	req2.Header().Set("X-Plugin-Runtime-ID", "multi-plugin-abc")
-	registry.RegisterService(context.Background(), req2)
+	registry.RegisterService(runtimeContext("multi-plugin-abc"), req2)
 
 	// Both should be available
 	if !registry.HasService("logger", "1.0.0") {
@@ -266,20 +257,137 @@ func TestServiceRegistry_UnregisterPluginServices(t *testing.T) {
 	}
 }
 
-func TestServiceRegistry_MissingRuntimeID(t *testing.T) {
+func TestServiceRegistry_Unauthenticated(t *testing.T) {
 	registry := NewServiceRegistry(nil)
 
-	// Request without runtime ID should fail
 	req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
 		ServiceType:  "logger",
 		Version:      "1.0.0",
 		EndpointPath: "/logger.v1.Logger/",
 	})
-	// No X-Plugin-Runtime-ID header
+	req.Header().Set("X-Plugin-Runtime-ID", "logger-a-x7k2")
 
+	// Raw header without a verified runtime identity should fail
 	_, err := registry.RegisterService(context.Background(), req)
-	if err == nil {
-		t.Error("Expected error when X-Plugin-Runtime-ID header missing")
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestServiceRegistry_UnregisterOwnership(t *testing.T) {
+	registry := NewServiceRegistry(nil)
+	registry.SetAdminIdentities("host-admin")
+
+	register := func() string {
+		req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  "logger",
+			Version:      "1.0.0",
+			EndpointPath: "/logger.v1.Logger/",
+		})
+		resp, err := registry.RegisterService(runtimeContext("logger-a-x7k2"), req)
+		if err != nil {
+			t.Fatalf("RegisterService failed: %v", err)
+		}
+		return resp.Msg.RegistrationId
+	}
+	unregister := func(ctx context.Context, registrationID string) error {
+		_, err := registry.UnregisterService(ctx, connect.NewRequest(&connectpluginv1.UnregisterServiceRequest{
+			RegistrationId: registrationID,
+		}))
+		return err
+	}
+
+	registrationID := register()
+
+	// Unauthenticated caller
+	if err := unregister(context.Background(), registrationID); connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", err)
+	}
+
+	// Another plugin cannot remove the registration
+	if err := unregister(runtimeContext("attacker-c3d4"), registrationID); connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("Expected PermissionDenied, got %v", err)
+	}
+	if !registry.HasService("logger", "1.0.0") {
+		t.Fatal("Expected registration to survive non-owner unregister")
+	}
+
+	// Owner can
+	if err := unregister(runtimeContext("logger-a-x7k2"), registrationID); err != nil {
+		t.Fatalf("Owner unregister failed: %v", err)
+	}
+
+	// Admin can remove any registration
+	registrationID = register()
+	adminCtx := WithAuthContext(context.Background(), &AuthContext{Identity: "host-admin", Provider: "token"})
+	if err := unregister(adminCtx, registrationID); err != nil {
+		t.Fatalf("Admin unregister failed: %v", err)
+	}
+	if registry.HasService("logger", "1.0.0") {
+		t.Error("Expected logger service to be unavailable after admin unregister")
+	}
+}
+
+func TestServiceRegistry_NamespaceIsolation(t *testing.T) {
+	registry := NewServiceRegistry(nil)
+	registry.SetNamespace("logger-a", "tenant-a")
+	registry.SetNamespace("logger-b", "tenant-b")
+	registry.SetNamespace("app-a", "tenant-a")
+
+	for _, id := range []string{"logger-a", "logger-b"} {
+		req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  "logger",
+			Version:      "1.0.0",
+			EndpointPath: "/logger.v1.Logger/",
+		})
+		if _, err := registry.RegisterService(runtimeContext(id), req); err != nil {
+			t.Fatalf("RegisterService failed: %v", err)
+		}
+	}
+
+	// Each tenant only selects its own provider
+	for namespace, want := range map[string]string{"tenant-a": "logger-a", "tenant-b": "logger-b"} {
+		provider, err := registry.SelectProviderInNamespace(namespace, "logger", "")
+		if err != nil {
+			t.Fatalf("SelectProviderInNamespace(%s) failed: %v", namespace, err)
+		}
+		if provider.RuntimeID != want {
+			t.Errorf("Expected %s in %s, got %s", want, namespace, provider.RuntimeID)
+		}
+	}
+
+	// Default namespace sees neither
+	if registry.HasService("logger", "") || len(registry.GetAllProviders("logger")) != 0 {
+		t.Error("Expected no logger in default namespace")
+	}
+	if !registry.HasServiceInNamespace("tenant-a", "logger", "1.0.0") {
+		t.Error("Expected logger in tenant-a")
+	}
+	if providers := registry.GetAllProvidersInNamespace("tenant-b", "logger"); len(providers) != 1 || providers[0].RuntimeID != "logger-b" {
+		t.Errorf("Expected only logger-b in tenant-b, got %v", providers)
+	}
+
+	// Discovery is scoped to the caller's namespace
+	resp, err := registry.DiscoverService(runtimeContext("app-a"), connect.NewRequest(&connectpluginv1.DiscoverServiceRequest{
+		ServiceType: "logger",
+	}))
+	if err != nil {
+		t.Fatalf("DiscoverService failed: %v", err)
+	}
+	if resp.Msg.Endpoint.ProviderId != "logger-a" || !resp.Msg.SingleProvider {
+		t.Errorf("Expected single provider logger-a, got %+v", resp.Msg)
+	}
+
+	// Watch events are scoped too
+	registry.mu.Lock()
+	event := registry.buildServiceEventLocked(serviceKey("tenant-b", "logger"))
+	registry.mu.Unlock()
+	if event.ServiceType != "logger" || len(event.Endpoints) != 1 || event.Endpoints[0].ProviderId != "logger-b" {
+		t.Errorf("Expected tenant-b event with only logger-b, got %+v", event)
+	}
+
+	if err := registry.SetNamespace("x", "bad/namespace"); err == nil {
+		t.Error("Expected invalid namespace to be rejected")
 	}
 }
 
@@ -294,8 +402,7 @@ func TestDiscoverService_SingleEndpoint(t *testing.T) {
 			EndpointPath: "/logger.v1.Logger/",
 			Metadata:     map[string]string{"index": string(rune('0' + i))},
 		})
-		req.Header().Set("X-Plugin-Runtime-ID", runtimeID)
-		registry.RegisterService(context.Background(), req)
+		registry.RegisterService(runtimeContext(runtimeID), req)
 	}
 
 	// Discover logger (host selects one)
@@ -358,7 +465,7 @@ func TestRegistry_WatcherNotification(t *testing.T) {
 
 	// Manually create a watcher to test notification mechanism
 	watcher := &serviceWatcher{
-		ch: make(chan *connectpluginv1.WatchServiceEvent, 10),
+		notify: make(chan struct{}, 1),
 	}
 
 	registry.mu.Lock()
@@ -371,12 +478,12 @@ func TestRegistry_WatcherNotification(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/test.v1.Test/",
 	})
-	regReq.Header().Set("X-Plugin-Runtime-ID", "test-xyz")
-	registry.RegisterService(context.Background(), regReq)
+	registry.RegisterService(runtimeContext("test-xyz"), regReq)
 
 	// Watcher should receive event
 	select {
-	case event := <-watcher.ch:
+	case <-watcher.notify:
+		event := watcher.take()
 		if event.State != connectpluginv1.ServiceState_SERVICE_STATE_AVAILABLE {
 			t.Errorf("Expected AVAILABLE notification, got %v", event.State)
 		}
@@ -384,3 +491,235 @@ func TestRegistry_WatcherNotification(t *testing.T) {
 		t.Fatal("Watcher not notified of registration")
 	}
 }
+
+func TestRegistry_WatchEventCarriesAllProviders(t *testing.T) {
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+
+	for _, id := range []string{"logger-a-x7k2", "logger-b-y8m3"} {
+		req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  "logger",
+			Version:      "1.0.0",
+			EndpointPath: "/logger.v1.Logger/",
+		})
+		registry.RegisterService(runtimeContext(id), req)
+	}
+
+	// logger-a is degraded, so logger-b should be the preferred endpoint
+	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State: connectpluginv1.HealthState_HEALTH_STATE_DEGRADED,
+	})
+	lifecycle.ReportHealth(runtimeContext("logger-a-x7k2"), healthReq)
+
+	registry.mu.Lock()
+	event := registry.buildServiceEventLocked("logger")
+	registry.mu.Unlock()
+
+	if len(event.Endpoints) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %d", len(event.Endpoints))
+	}
+	if event.Endpoints[0].State != connectpluginv1.ServiceState_SERVICE_STATE_DEGRADED {
+		t.Errorf("Expected logger-a DEGRADED, got %v", event.Endpoints[0].State)
+	}
+	if event.Endpoint.ProviderId != "logger-b-y8m3" {
+		t.Errorf("Expected healthy logger-b as preferred endpoint, got %s", event.Endpoint.ProviderId)
+	}
+	if event.State != connectpluginv1.ServiceState_SERVICE_STATE_AVAILABLE {
+		t.Errorf("Expected AVAILABLE, got %v", event.State)
+	}
+	if event.Revision == 0 {
+		t.Error("Expected non-zero revision")
+	}
+}
+
+func TestRegistry_SlowWatcherGetsLatestState(t *testing.T) {
+	registry := NewServiceRegistry(nil)
+
+	watcher := &serviceWatcher{notify: make(chan struct{}, 1)}
+	registry.mu.Lock()
+	registry.watchers["logger"] = []*serviceWatcher{watcher}
+	registry.mu.Unlock()
+
+	// Many changes while the watcher isn't reading
+	var lastRevision uint64
+	for i := 0; i < 20; i++ {
+		req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  "logger",
+			Version:      "1.0.0",
+			EndpointPath: "/logger.v1.Logger/",
+		})
+		registry.RegisterService(runtimeContext("logger-"+string(rune('a'+i))), req)
+
+		registry.mu.RLock()
+		if registry.revisions["logger"] <= lastRevision {
+			t.Fatalf("Expected revision to increase, got %d after %d", registry.revisions["logger"], lastRevision)
+		}
+		lastRevision = registry.revisions["logger"]
+		registry.mu.RUnlock()
+	}
+
+	<-watcher.notify
+	event := watcher.take()
+	if len(event.Endpoints) != 20 {
+		t.Errorf("Expected coalesced event with all 20 providers, got %d", len(event.Endpoints))
+	}
+	if event.Revision != lastRevision {
+		t.Errorf("Expected latest revision %d, got %d", lastRevision, event.Revision)
+	}
+	if watcher.take() != nil {
+		t.Error("Expected no further pending events")
+	}
+}
+
+func TestRegistry_WatchServiceResume(t *testing.T) {
+	registry := NewServiceRegistry(nil)
+	path, handler := ServiceRegistryHandler(registry)
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := connectpluginv1connect.NewServiceRegistryClient(server.Client(), server.URL)
+
+	register := func(id string) {
+		req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  "logger",
+			Version:      "1.0.0",
+			EndpointPath: "/logger.v1.Logger/",
+		})
+		if _, err := registry.RegisterService(runtimeContext(id), req); err != nil {
+			t.Fatalf("RegisterService failed: %v", err)
+		}
+	}
+	register("logger-a-x7k2")
+
+	// First watch: receives current state
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	stream, err := client.WatchService(ctx, connect.NewRequest(&connectpluginv1.WatchServiceRequest{ServiceType: "logger"}))
+	if err != nil {
+		t.Fatalf("WatchService failed: %v", err)
+	}
+	if !stream.Receive() {
+		t.Fatalf("Expected initial event: %v", stream.Err())
+	}
+	revision := stream.Msg().Revision
+	cancel()
+
+	// Resume from the same revision: initial event confirms nothing changed
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err = client.WatchService(ctx, connect.NewRequest(&connectpluginv1.WatchServiceRequest{
+		ServiceType:        "logger",
+		ResumeFromRevision: revision,
+	}))
+	if err != nil {
+		t.Fatalf("WatchService failed: %v", err)
+	}
+
+	if !stream.Receive() {
+		t.Fatalf("Expected initial event: %v", stream.Err())
+	}
+	if !stream.Msg().Unchanged || stream.Msg().Revision != revision {
+		t.Errorf("Expected unchanged event at revision %d, got %+v", revision, stream.Msg())
+	}
+
+	register("logger-b-y8m3")
+
+	if !stream.Receive() {
+		t.Fatalf("Expected event after change: %v", stream.Err())
+	}
+	event := stream.Msg()
+	if event.Revision <= revision {
+		t.Errorf("Expected revision > %d, got %d", revision, event.Revision)
+	}
+	if len(event.Endpoints) != 2 {
+		t.Errorf("Expected first event after resume to include both providers, got %d", len(event.Endpoints))
+	}
+}
+
+func TestRegistry_TrafficSplit(t *testing.T) {
+	registry := NewServiceRegistry(nil)
+
+	for _, p := range []struct{ id, version string }{{"kv-old", "1.0.0"}, {"kv-new", "2.0.0"}} {
+		req := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  "kv",
+			Version:      p.version,
+			EndpointPath: "/kv.v1.KV/",
+		})
+		if _, err := registry.RegisterService(runtimeContext(p.id), req); err != nil {
+			t.Fatalf("RegisterService failed: %v", err)
+		}
+	}
+
+	count := func() map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < 1000; i++ {
+			provider, err := registry.SelectProvider("kv", "")
+			if err != nil {
+				t.Fatalf("SelectProvider failed: %v", err)
+			}
+			counts[provider.RuntimeID]++
+		}
+		return counts
+	}
+
+	// All traffic to the new version
+	if err := registry.SetTrafficPolicy("kv", &TrafficPolicy{
+		Splits: []TrafficSplit{{Version: "2.0.0", Percent: 100}},
+	}); err != nil {
+		t.Fatalf("SetTrafficPolicy failed: %v", err)
+	}
+	if counts := count(); counts["kv-new"] != 1000 {
+		t.Errorf("Expected all calls to kv-new, got %v", counts)
+	}
+
+	// 20% canary by runtime ID
+	registry.SetTrafficPolicy("kv", &TrafficPolicy{
+		Splits: []TrafficSplit{{RuntimeID: "kv-new", Percent: 20}},
+	})
+	if counts := count(); counts["kv-new"] < 100 || counts["kv-new"] > 300 {
+		t.Errorf("Expected about 20%% of calls to kv-new, got %v", counts)
+	}
+
+	// Removing the policy restores the selection strategy
+	registry.SetTrafficPolicy("kv", nil)
+	if counts := count(); counts["kv-old"] != 1000 {
+		t.Errorf("Expected SelectionFirst to pick kv-old, got %v", counts)
+	}
+
+	// A policy in another namespace doesn't apply
+	registry.SetTrafficPolicyInNamespace("team-a", "kv", &TrafficPolicy{
+		Splits: []TrafficSplit{{Version: "2.0.0", Percent: 100}},
+	})
+	if counts := count(); counts["kv-old"] != 1000 {
+		t.Errorf("Expected team-a policy to leave the default namespace alone, got %v", counts)
+	}
+	if registry.TrafficPolicy("kv") != nil || registry.TrafficPolicyInNamespace("team-a", "kv") == nil {
+		t.Error("Expected the policy to be stored for team-a only")
+	}
+}
+
+func TestRegistry_TrafficPolicyValidation(t *testing.T) {
+	registry := NewServiceRegistry(nil)
+
+	tests := []struct {
+		name   string
+		policy *TrafficPolicy
+	}{
+		{"no target", &TrafficPolicy{Splits: []TrafficSplit{{Percent: 10}}}},
+		{"percent out of range", &TrafficPolicy{Splits: []TrafficSplit{{Version: "2.0.0", Percent: 101}}}},
+		{"total over 100", &TrafficPolicy{Splits: []TrafficSplit{
+			{Version: "2.0.0", Percent: 60},
+			{Version: "3.0.0", Percent: 50},
+		}}},
+		{"shadow without target", &TrafficPolicy{Shadow: &TrafficShadow{Percent: 100}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := registry.SetTrafficPolicy("kv", tt.policy); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
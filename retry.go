@@ -83,24 +83,30 @@ func defaultIsRetryable(err error) bool {
 	}
 }
 
-// RetryInterceptor returns a Connect unary interceptor that retries failed calls.
-func RetryInterceptor(policy RetryPolicy) connect.UnaryInterceptorFunc {
-	// Set defaults
-	if policy.MaxAttempts <= 0 {
-		policy.MaxAttempts = 3
+// withDefaults returns the policy with unset fields filled in.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
 	}
-	if policy.InitialBackoff == 0 {
-		policy.InitialBackoff = 100 * time.Millisecond
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = 100 * time.Millisecond
 	}
-	if policy.MaxBackoff == 0 {
-		policy.MaxBackoff = 10 * time.Second
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = 10 * time.Second
 	}
-	if policy.BackoffMultiplier == 0 {
-		policy.BackoffMultiplier = 2.0
+	if p.BackoffMultiplier == 0 {
+		p.BackoffMultiplier = 2.0
 	}
-	if policy.IsRetryable == nil {
-		policy.IsRetryable = defaultIsRetryable
+	if p.IsRetryable == nil {
+		p.IsRetryable = defaultIsRetryable
 	}
+	return p
+}
+
+// RetryInterceptor returns a Connect unary interceptor that retries failed calls.
+func RetryInterceptor(policy RetryPolicy) connect.UnaryInterceptorFunc {
+	// Set defaults
+	policy = policy.withDefaults()
 
 	return func(next connect.UnaryFunc) connect.UnaryFunc {
 		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
@@ -2,42 +2,248 @@ package connectplugin
 
 import (
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"connectrpc.com/connect"
+)
+
+// AccessRule is an explicit router policy for a caller and service type.
+// Explicit rules take precedence over dependency enforcement.
+type AccessRule int
+
+const (
+	// AccessDefault applies dependency enforcement (if enabled).
+	AccessDefault AccessRule = iota
+
+	// AccessAllow permits the call even if the caller did not declare the dependency.
+	AccessAllow
+
+	// AccessDeny rejects the call.
+	AccessDeny
+)
+
+const (
+	// AnyProvider in place of a provider ID lets the router select the
+	// provider: /services/{type}/_any/{method...}
+	AnyProvider = "_any"
+
+	// MinVersionHeader sets the minimum provider version for _any routing.
+	MinVersionHeader = "X-Service-Min-Version"
 )
 
 // ServiceRouter routes plugin-to-plugin service calls through the host.
 // All calls follow the pattern: /services/{type}/{provider-id}/{method...}
+// Use AnyProvider as the provider ID to route to a provider selected by the
+// registry's strategy on each request.
 type ServiceRouter struct {
 	handshakeServer *HandshakeServer
 	registry        *ServiceRegistry
 	lifecycleServer *LifecycleServer
 
+	mu sync.RWMutex
+
+	// transports are shared across proxied requests
+	transports *proxyTransports
+
 	// Plugin base URLs for proxying
 	pluginEndpoints map[string]string // runtime_id → base URL
+
+	// pluginTransports maps runtime_id to a dedicated transport (e.g. in-memory)
+	pluginTransports map[string]http.RoundTripper
+
+	// enforceDependencies restricts callers to their declared service dependencies
+	enforceDependencies bool
+
+	// dependencies maps runtime_id to declared required service types
+	dependencies map[string]map[string]bool
+
+	// accessRules maps runtime_id → service type → explicit rule
+	accessRules map[string]map[string]AccessRule
+
+	// failover retries calls on other providers (nil = disabled)
+	failover *FailoverConfig
+
+	// breakers maps runtime_id to the provider's circuit breaker
+	breakers map[string]*CircuitBreaker
+
+	// config holds transport, limit and header settings
+	config RouterConfig
+
+	// slots maps runtime_id to its concurrent call slots
+	slots map[string]chan struct{}
+
+	// calls maps runtime_id to its in-flight calls and drain state
+	calls map[string]*providerCalls
+
+	// faults holds named fault injection rules
+	faults map[string]FaultRule
+
+	// cache holds responses of side-effect-free calls (nil = disabled)
+	cache *responseCache
 }
 
-// NewServiceRouter creates a new service router.
+// NewServiceRouter creates a new service router with the default RouterConfig.
 func NewServiceRouter(
 	handshake *HandshakeServer,
 	registry *ServiceRegistry,
 	lifecycle *LifecycleServer,
 ) *ServiceRouter {
+	return NewServiceRouterWithConfig(handshake, registry, lifecycle, RouterConfig{})
+}
+
+// NewServiceRouterWithConfig creates a new service router whose proxy
+// transport, limits and header handling are tuned by config.
+func NewServiceRouterWithConfig(
+	handshake *HandshakeServer,
+	registry *ServiceRegistry,
+	lifecycle *LifecycleServer,
+	config RouterConfig,
+) *ServiceRouter {
+	cfg := config.withDefaults()
+
 	return &ServiceRouter{
-		handshakeServer: handshake,
-		registry:        registry,
-		lifecycleServer: lifecycle,
-		pluginEndpoints: make(map[string]string),
+		handshakeServer:  handshake,
+		registry:         registry,
+		lifecycleServer:  lifecycle,
+		config:           cfg,
+		slots:            make(map[string]chan struct{}),
+		calls:            make(map[string]*providerCalls),
+		faults:           make(map[string]FaultRule),
+		transports:       newProxyTransports(cfg),
+		pluginEndpoints:  make(map[string]string),
+		pluginTransports: make(map[string]http.RoundTripper),
+		dependencies:     make(map[string]map[string]bool),
+		accessRules:      make(map[string]map[string]AccessRule),
+		breakers:         make(map[string]*CircuitBreaker),
 	}
 }
 
 // RegisterPluginEndpoint registers a plugin's internal endpoint for routing.
 // This is called during plugin startup to tell the router where to proxy calls.
+// Use RegisterPluginClient as well for plugins not reachable over the network.
 func (r *ServiceRouter) RegisterPluginEndpoint(runtimeID, endpoint string) {
+	r.mu.Lock()
 	r.pluginEndpoints[runtimeID] = endpoint
+	var client connect.HTTPClient
+	if transport, ok := r.pluginTransports[runtimeID]; ok {
+		client = &http.Client{Transport: transport}
+	}
+	r.mu.Unlock()
+
+	// Lets the lifecycle server probe the plugin
+	r.lifecycleServer.setProbeTarget(runtimeID, endpoint, client)
+}
+
+// RegisterPluginClient sets the HTTP client used to reach a plugin instead of
+// the router's shared TCP transports. In-memory plugins (LaunchResult.HTTPClient)
+// are dispatched through their memtransport listener without the network.
+// PluginLauncher registers in-memory plugins automatically.
+func (r *ServiceRouter) RegisterPluginClient(runtimeID string, client connect.HTTPClient) {
+	r.mu.Lock()
+	if client == nil {
+		delete(r.pluginTransports, runtimeID)
+	} else {
+		r.pluginTransports[runtimeID] = clientTransport(client)
+	}
+	r.mu.Unlock()
+
+	r.lifecycleServer.setProbeClient(runtimeID, client)
+}
+
+// UnregisterPluginEndpoint removes a plugin's endpoint and client.
+func (r *ServiceRouter) UnregisterPluginEndpoint(runtimeID string) {
+	r.mu.Lock()
+	delete(r.pluginEndpoints, runtimeID)
+	delete(r.pluginTransports, runtimeID)
+	delete(r.breakers, runtimeID)
+	delete(r.slots, runtimeID)
+	delete(r.calls, runtimeID)
+	r.mu.Unlock()
+
+	r.lifecycleServer.setProbeTarget(runtimeID, "", nil)
+}
+
+// transportFor returns the transport used to reach a provider.
+func (r *ServiceRouter) transportFor(runtimeID string, req *http.Request) http.RoundTripper {
+	r.mu.RLock()
+	transport, ok := r.pluginTransports[runtimeID]
+	r.mu.RUnlock()
+	if ok {
+		return transport
+	}
+	return r.transports.forRequest(req)
+}
+
+// SetEnforceDependencies enables dependency enforcement: a caller may only
+// reach service types it declared in PluginMetadata.Requires (see
+// SetCallerDependencies). Violations are rejected with 403.
+//
+// Only callers with a recorded declaration are restricted. Plugins that
+// self-register through the handshake declare no requirements to the host
+// and are not restricted; use SetAccessRule to limit them.
+func (r *ServiceRouter) SetEnforceDependencies(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enforceDependencies = enabled
+}
+
+// SetCallerDependencies records the service types a plugin declared it requires.
+// Platform calls this when plugins join the dependency graph.
+// An empty slice records a plugin that requires nothing; a nil slice
+// removes the record.
+func (r *ServiceRouter) SetCallerDependencies(runtimeID string, serviceTypes []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if serviceTypes == nil {
+		delete(r.dependencies, runtimeID)
+		return
+	}
+	deps := make(map[string]bool, len(serviceTypes))
+	for _, serviceType := range serviceTypes {
+		deps[serviceType] = true
+	}
+	r.dependencies[runtimeID] = deps
+}
+
+// SetAccessRule sets an explicit allow/deny rule for a caller and service type.
+// AccessDefault removes the rule.
+func (r *ServiceRouter) SetAccessRule(callerID, serviceType string, rule AccessRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rule == AccessDefault {
+		delete(r.accessRules[callerID], serviceType)
+		return
+	}
+	if r.accessRules[callerID] == nil {
+		r.accessRules[callerID] = make(map[string]AccessRule)
+	}
+	r.accessRules[callerID][serviceType] = rule
+}
+
+// authorizeCall checks whether a caller may reach a service type.
+// Returns nil if allowed, or an error describing why not.
+func (r *ServiceRouter) authorizeCall(callerID, serviceType string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	switch r.accessRules[callerID][serviceType] {
+	case AccessAllow:
+		return nil
+	case AccessDeny:
+		return fmt.Errorf("plugin %s is denied access to service type %s by policy", callerID, serviceType)
+	}
+
+	// Callers without a recorded declaration are not restricted
+	if deps, declared := r.dependencies[callerID]; r.enforceDependencies && declared && !deps[serviceType] {
+		return fmt.Errorf("plugin %s did not declare a dependency on service type %s", callerID, serviceType)
+	}
+	return nil
 }
 
 // ServeHTTP implements http.Handler for /services/* routes.
@@ -61,35 +267,66 @@ func (r *ServiceRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	providerID := parts[1]
 	method := "/" + parts[2]
 
-	// Extract caller identity from headers
-	callerID := req.Header.Get("X-Plugin-Runtime-ID")
-	if callerID == "" {
-		log.Printf("[ROUTER] Missing X-Plugin-Runtime-ID header for %s", req.URL.Path)
-		http.Error(w, "X-Plugin-Runtime-ID header required", http.StatusUnauthorized)
+	// Authenticate caller with its runtime token
+	auth, err := r.handshakeServer.authenticateRuntime(req.Header)
+	if err != nil {
+		log.Printf("[ROUTER] Unauthenticated call to %s: %v", req.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	callerID := auth.Identity
 
-	// Extract and validate token
-	authHeader := req.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Printf("[ROUTER] Missing/invalid Authorization header for %s (caller: %s)", req.URL.Path, callerID)
-		http.Error(w, "Authorization: Bearer <token> required", http.StatusUnauthorized)
+	// Check the caller may reach this service type
+	if err := r.authorizeCall(callerID, serviceType); err != nil {
+		log.Printf("[ROUTER] Forbidden: %v", err)
+		http.Error(w, "forbidden: "+err.Error(), http.StatusForbidden)
 		return
 	}
-	token := strings.TrimPrefix(authHeader, "Bearer ")
 
-	// Validate token
-	if !r.handshakeServer.ValidateToken(callerID, token) {
-		log.Printf("[ROUTER] Invalid token for caller: %s", callerID)
-		http.Error(w, "invalid token", http.StatusUnauthorized)
+	// Build the caller context; it is signed for each provider the call
+	// is sent to
+	caller, err := r.callerInfo(req, callerID)
+	if err != nil {
+		log.Printf("[ROUTER] Invalid caller context from %s: %v", callerID, err)
+		http.Error(w, "invalid caller context: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
+	req.Header.Del(CallerContextHeader)
+	req.Header.Del(OriginHeader)
 
-	// Look up provider by runtime ID
-	provider, err := r.registry.GetProviderByRuntimeID(providerID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("provider not found: %s", providerID), http.StatusNotFound)
-		return
+	// Enforce the request size limit
+	if max := r.config.MaxRequestBodySize; max > 0 {
+		if req.ContentLength > max {
+			http.Error(w, fmt.Sprintf("request body too large (max %d bytes)", max), http.StatusRequestEntityTooLarge)
+			return
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, max)
+	}
+
+	minVersion := req.Header.Get(MinVersionHeader)
+	namespace := r.registry.Namespace(callerID)
+
+	var provider *ServiceProvider
+	if providerID == AnyProvider {
+		// Select a provider per request using the configured strategy,
+		// skipping providers being drained
+		draining := r.drainingProviders()
+		provider, err = r.registry.selectProvider(namespace, serviceType, minVersion, func(p *ServiceProvider) bool {
+			return draining[p.RuntimeID]
+		})
+		if err != nil {
+			http.Error(w, "service unavailable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		providerID = provider.RuntimeID
+	} else {
+		// Look up the provider's registration for this service type
+		// Providers in other namespaces are reported as not found
+		provider = r.findProvider(providerID, serviceType)
+		if provider == nil || provider.Namespace != namespace {
+			http.Error(w, fmt.Sprintf("provider not found: %s", providerID), http.StatusNotFound)
+			return
+		}
 	}
 
 	// Check provider health
@@ -97,28 +334,70 @@ func (r *ServiceRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, "service unavailable (provider unhealthy)", http.StatusServiceUnavailable)
 		return
 	}
+	if r.IsDraining(providerID) {
+		http.Error(w, "service unavailable (provider draining)", http.StatusServiceUnavailable)
+		return
+	}
 
 	// Get provider's internal endpoint
-	// First try registered endpoint (Model A via Platform.AddPlugin)
-	baseURL, ok := r.pluginEndpoints[providerID]
+	baseURL, ok := r.providerBaseURL(provider)
 	if !ok {
-		// Fall back to metadata base_url (Model B self-registration)
-		if baseURLMeta, exists := provider.Metadata["base_url"]; exists {
-			baseURL = baseURLMeta
-		} else {
-			http.Error(w, fmt.Sprintf("provider endpoint not registered: %s", providerID), http.StatusNotFound)
+		http.Error(w, fmt.Sprintf("provider endpoint not registered: %s", providerID), http.StatusNotFound)
+		return
+	}
+
+	// Inject a configured fault, if any matches this call
+	if name, fault := r.selectFault(callerID, serviceType, providerID, method); fault != nil {
+		log.Printf("[ROUTER] %s → %s %s: injecting fault %q", callerID, providerID, method, name)
+		var handled bool
+		if w, handled = injectFault(w, req, fault); handled {
 			return
 		}
 	}
 
+	// Serve side-effect-free calls from the cache if possible
+	cached, hit, err := r.lookupCache(w, req, namespace, serviceType, providerID, caller, method)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if hit {
+		log.Printf("[ROUTER] %s → %s %s served from cache", callerID, serviceType, method)
+		return
+	}
+	if cached != nil {
+		w = cached.capture
+	}
+
+	// Mirror a copy of the call to a shadow provider if configured
+	if shadow := r.startShadow(req, namespace, caller, provider, method); shadow != nil {
+		capture := newCaptureWriter(w, shadow.maxBody)
+		w = capture
+		defer shadow.finish(capture)
+	}
+
+	r.mu.RLock()
+	failover := r.failover
+	r.mu.RUnlock()
+
 	// Log the call
 	start := time.Now()
 	log.Printf("[ROUTER] %s → %s %s (service: %s)",
 		callerID, providerID, method, serviceType)
 
 	// Proxy the request
-	targetURL := baseURL + provider.EndpointPath + strings.TrimPrefix(method, "/")
-	statusCode, err := r.proxyRequest(w, req, targetURL)
+	var statusCode int
+	served := provider
+	if failover != nil {
+		statusCode, served, err = r.proxyWithFailover(w, req, failover, caller, provider, method, minVersion)
+	} else {
+		targetURL := baseURL + provider.EndpointPath + strings.TrimPrefix(method, "/")
+		statusCode, err = r.proxyRequest(w, req, caller, provider, targetURL)
+	}
+
+	if cached != nil {
+		cached.store(served, err)
+	}
 
 	// Log completion
 	duration := time.Since(start)
@@ -131,56 +410,14 @@ func (r *ServiceRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// proxyRequest proxies an HTTP request to the target URL.
-// Returns status code and any error.
-func (r *ServiceRouter) proxyRequest(w http.ResponseWriter, req *http.Request, targetURL string) (int, error) {
-	// Create proxy request with query parameters from original request
-	fullURL := targetURL
-	if req.URL.RawQuery != "" {
-		fullURL = targetURL + "?" + req.URL.RawQuery
-	}
-
-	proxyReq, err := http.NewRequestWithContext(req.Context(), req.Method, fullURL, req.Body)
-	if err != nil {
-		http.Error(w, "failed to create proxy request", http.StatusInternalServerError)
-		return http.StatusInternalServerError, err
-	}
-
-	// Copy headers (except Authorization and X-Plugin-Runtime-ID)
-	for key, values := range req.Header {
-		// Header keys are canonicalized, so compare with canonical form
-		canonicalKey := http.CanonicalHeaderKey(key)
-		if canonicalKey == "Authorization" || canonicalKey == "X-Plugin-Runtime-Id" {
-			continue
+// findProvider returns the registration of serviceType by the given plugin.
+// The service type in the path must match so callers cannot reach a
+// provider's other services under a type they are authorized for.
+func (r *ServiceRouter) findProvider(providerID, serviceType string) *ServiceProvider {
+	for _, provider := range r.registry.GetServicesBy(providerID) {
+		if provider.ServiceType == serviceType {
+			return provider
 		}
-		for _, value := range values {
-			proxyReq.Header.Add(key, value)
-		}
-	}
-
-	// Execute proxy request
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		http.Error(w, "failed to proxy request", http.StatusBadGateway)
-		return http.StatusBadGateway, err
 	}
-	defer resp.Body.Close()
-
-	// Copy response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(key, value)
-		}
-	}
-
-	// Copy status code
-	w.WriteHeader(resp.StatusCode)
-
-	// Copy response body
-	_, copyErr := io.Copy(w, resp.Body)
-
-	return resp.StatusCode, copyErr
+	return nil
 }
@@ -0,0 +1,309 @@
+package connectplugin
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheConfig enables caching of side-effect-free calls in the ServiceRouter.
+// Entries are keyed by namespace, service type, provider, caller, caller
+// origin, procedure and request bytes, so a response is only replayed to
+// the caller it was produced for. They are dropped when the service type's provider set or health changes.
+type CacheConfig struct {
+	// MaxEntries caps the number of cached responses (LRU eviction).
+	// Default: 1024
+	MaxEntries int
+
+	// MaxBytes caps the total size of cached response bodies (LRU eviction).
+	// Default: 32 MiB
+	MaxBytes int64
+
+	// MaxEntrySize is the largest request or response body that is cached.
+	// Default: 1 MiB
+	MaxEntrySize int64
+
+	// DefaultTTL applies when neither the response's Cache-Control max-age
+	// nor ServiceTTLs sets one. Zero only caches responses that set max-age.
+	// Default: 0
+	DefaultTTL time.Duration
+
+	// ServiceTTLs overrides DefaultTTL per service type.
+	ServiceTTLs map[string]time.Duration
+
+	// IsCacheable reports whether a call may be served from the cache.
+	// Default: GET requests, which Connect uses for procedures marked
+	// idempotency_level = NO_SIDE_EFFECTS
+	IsCacheable func(serviceType, method string, req *http.Request) bool
+}
+
+// withDefaults returns the config with unset fields filled in.
+func (c CacheConfig) withDefaults() CacheConfig {
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = 1024
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = 32 << 20
+	}
+	if c.MaxEntrySize <= 0 {
+		c.MaxEntrySize = 1 << 20
+	}
+	if c.IsCacheable == nil {
+		c.IsCacheable = func(serviceType, method string, req *http.Request) bool {
+			return req.Method == http.MethodGet
+		}
+	}
+	return c
+}
+
+// SetCache enables response caching. Pass nil to disable it.
+// Replacing the config drops all cached responses.
+func (r *ServiceRouter) SetCache(config *CacheConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if config == nil {
+		r.cache = nil
+		return
+	}
+	r.cache = newResponseCache(config.withDefaults())
+}
+
+// responseCache is an LRU cache of complete responses.
+type responseCache struct {
+	config CacheConfig
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List // front = most recently used
+	bytes   int64
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	key       [sha256.Size]byte
+	revision  uint64
+	storedAt  time.Time
+	expiresAt time.Time
+	status    int
+	header    http.Header
+	body      []byte
+}
+
+func newResponseCache(config CacheConfig) *responseCache {
+	return &responseCache{
+		config:  config,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// cacheKey identifies a call by namespace, service type, provider, caller,
+// caller origin (and whether it was asserted), procedure and request bytes. Connect GET requests carry
+// the message in the query.
+func cacheKey(namespace, serviceType, providerID string, caller *CallerInfo, method string, req *http.Request, body []byte) [sha256.Size]byte {
+	var origin string
+	if caller.Origin != nil {
+		origin = encodeOrigin(caller.Origin)
+		if caller.OriginAsserted {
+			origin = "asserted:" + origin
+		}
+	}
+
+	h := sha256.New()
+	for _, part := range []string{
+		serviceKey(namespace, serviceType),
+		providerID,
+		caller.RuntimeID,
+		origin,
+		method,
+		req.URL.RawQuery,
+		req.Header.Get("Content-Type"),
+		req.Header.Get("Accept-Encoding"),
+	} {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// get returns a fresh entry cached at the given provider set revision.
+func (c *responseCache) get(key [sha256.Size]byte, revision uint64) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if entry.revision != revision || time.Now().After(entry.expiresAt) {
+		c.removeLocked(elem)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return entry
+}
+
+// put stores an entry, evicting the least recently used ones over the limits.
+func (c *responseCache) put(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.removeLocked(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.bytes += int64(len(entry.body))
+
+	for c.lru.Len() > c.config.MaxEntries || c.bytes > c.config.MaxBytes {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+// removeLocked drops an entry.
+// Caller must hold lock.
+func (c *responseCache) removeLocked(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.body))
+}
+
+// ttl returns how long a response may be cached. Cache-Control on the
+// response takes precedence over configured TTLs; no-store, no-cache and
+// private responses are never cached.
+func (c *responseCache) ttl(serviceType string, header http.Header) time.Duration {
+	var maxAge, sharedMaxAge time.Duration = -1, -1
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+			switch name {
+			case "no-store", "no-cache", "private":
+				return 0
+			case "max-age", "s-maxage":
+				seconds, err := strconv.Atoi(strings.Trim(arg, `"`))
+				if err != nil || seconds < 0 {
+					return 0
+				}
+				if name == "max-age" {
+					maxAge = time.Duration(seconds) * time.Second
+				} else {
+					sharedMaxAge = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+	}
+
+	switch {
+	case sharedMaxAge >= 0:
+		return sharedMaxAge
+	case maxAge >= 0:
+		return maxAge
+	}
+	if ttl, ok := c.config.ServiceTTLs[serviceType]; ok {
+		return ttl
+	}
+	return c.config.DefaultTTL
+}
+
+// cachedCall tracks a cacheable call that missed the cache.
+type cachedCall struct {
+	cache       *responseCache
+	key         [sha256.Size]byte
+	providerID  string
+	revision    uint64
+	serviceType string
+	capture     *captureWriter
+}
+
+// lookupCache serves a cacheable call from the cache. On a miss it returns
+// a cachedCall whose writer must be used for the call and whose store
+// method records the response. Returns (nil, false, nil) if the call is not
+// cacheable, and an error if the request body could not be read.
+func (r *ServiceRouter) lookupCache(w http.ResponseWriter, req *http.Request, namespace, serviceType, providerID string, caller *CallerInfo, method string) (*cachedCall, bool, error) {
+	r.mu.RLock()
+	cache := r.cache
+	r.mu.RUnlock()
+	if cache == nil || !cache.config.IsCacheable(serviceType, method, req) {
+		return nil, false, nil
+	}
+
+	// Only bodies of known, bounded size can be part of the key
+	body, replayable, err := bufferBody(req, cache.config.MaxEntrySize)
+	if err != nil {
+		return nil, false, err
+	}
+	if !replayable {
+		return nil, false, nil
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	key := cacheKey(namespace, serviceType, providerID, caller, method, req, body)
+	revision := r.registry.serviceRevision(namespace, serviceType)
+
+	if entry := cache.get(key, revision); entry != nil {
+		for name, values := range entry.header {
+			w.Header()[name] = append([]string(nil), values...)
+		}
+		w.Header().Set("Age", strconv.Itoa(int(time.Since(entry.storedAt).Seconds())))
+		w.WriteHeader(entry.status)
+		w.Write(entry.body)
+		return nil, true, nil
+	}
+
+	return &cachedCall{
+		cache:       cache,
+		key:         key,
+		providerID:  providerID,
+		revision:    revision,
+		serviceType: serviceType,
+		// One byte over the limit marks responses too large to cache
+		capture: newCaptureWriter(w, cache.config.MaxEntrySize+1),
+	}, false, nil
+}
+
+// store caches the completed response if it succeeded, fit the size limit
+// and carried no HTTP trailers. A response served by another provider than
+// the one in the key (after failover) is not cached.
+func (c *cachedCall) store(served *ServiceProvider, err error) {
+	capture := c.capture
+	if err != nil || served == nil || served.RuntimeID != c.providerID {
+		return
+	}
+	if capture.status != http.StatusOK || int64(capture.body.Len()) > c.cache.config.MaxEntrySize {
+		return
+	}
+
+	header := capture.Header().Clone()
+	for name := range header {
+		if strings.HasPrefix(name, http.TrailerPrefix) {
+			return
+		}
+	}
+
+	ttl := c.cache.ttl(c.serviceType, header)
+	if ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	c.cache.put(&cacheEntry{
+		key:       c.key,
+		revision:  c.revision,
+		storedAt:  now,
+		expiresAt: now.Add(ttl),
+		status:    capture.status,
+		header:    header,
+		body:      bytes.Clone(capture.body.Bytes()),
+	})
+}
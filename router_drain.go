@@ -0,0 +1,150 @@
+package connectplugin
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errProviderDraining is returned for new calls to a provider being drained.
+var errProviderDraining = errors.New("provider draining")
+
+// DrainResult reports how draining a provider ended.
+type DrainResult struct {
+	// Drained is true if all in-flight calls finished before the deadline.
+	Drained bool
+
+	// InFlight is the number of calls still in flight when draining ended.
+	InFlight int64
+
+	// Duration is how long draining took.
+	Duration time.Duration
+}
+
+// providerCalls tracks a provider's in-flight calls.
+type providerCalls struct {
+	inFlight int64
+	draining bool
+
+	// idle is closed when inFlight drops to zero (nil if nobody waits)
+	idle chan struct{}
+}
+
+// callsLocked returns the provider's call tracking, creating it if needed.
+// Caller must hold lock.
+func (r *ServiceRouter) callsLocked(runtimeID string) *providerCalls {
+	calls, ok := r.calls[runtimeID]
+	if !ok {
+		calls = &providerCalls{}
+		r.calls[runtimeID] = calls
+	}
+	return calls
+}
+
+// beginCall counts a call to the provider as in flight. The returned func
+// must be called when the call completes, including its response stream.
+// Fails with errProviderDraining while the provider is draining.
+func (r *ServiceRouter) beginCall(runtimeID string) (func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := r.callsLocked(runtimeID)
+	if calls.draining {
+		return nil, errProviderDraining
+	}
+	calls.inFlight++
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		calls.inFlight--
+		if calls.inFlight == 0 && calls.idle != nil {
+			close(calls.idle)
+			calls.idle = nil
+		}
+	}, nil
+}
+
+// InFlight returns the number of calls in flight to a provider.
+func (r *ServiceRouter) InFlight(runtimeID string) int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if calls, ok := r.calls[runtimeID]; ok {
+		return calls.inFlight
+	}
+	return 0
+}
+
+// IsDraining reports whether a provider is being drained.
+func (r *ServiceRouter) IsDraining(runtimeID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	calls, ok := r.calls[runtimeID]
+	return ok && calls.draining
+}
+
+// drainingProviders returns the runtime IDs of providers being drained.
+func (r *ServiceRouter) drainingProviders() map[string]bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var draining map[string]bool
+	for runtimeID, calls := range r.calls {
+		if calls.draining {
+			if draining == nil {
+				draining = make(map[string]bool)
+			}
+			draining[runtimeID] = true
+		}
+	}
+	return draining
+}
+
+// DrainProvider stops routing new calls to a provider and waits until its
+// in-flight calls, including streams, finish or ctx ends. New calls are
+// sent to other providers (AnyProvider, failover) or rejected with 503.
+//
+// The provider stays drained until ResumeProvider or
+// UnregisterPluginEndpoint.
+func (r *ServiceRouter) DrainProvider(ctx context.Context, runtimeID string) DrainResult {
+	start := time.Now()
+
+	r.mu.Lock()
+	calls := r.callsLocked(runtimeID)
+	calls.draining = true
+	var idle chan struct{}
+	if calls.inFlight > 0 {
+		if calls.idle == nil {
+			calls.idle = make(chan struct{})
+		}
+		idle = calls.idle
+	}
+	r.mu.Unlock()
+
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+		}
+	}
+
+	inFlight := r.InFlight(runtimeID)
+	return DrainResult{
+		Drained:  inFlight == 0,
+		InFlight: inFlight,
+		Duration: time.Since(start),
+	}
+}
+
+// ResumeProvider routes calls to a drained provider again.
+func (r *ServiceRouter) ResumeProvider(runtimeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if calls, ok := r.calls[runtimeID]; ok {
+		calls.draining = false
+	}
+}
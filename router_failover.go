@@ -0,0 +1,267 @@
+package connectplugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// FailoverConfig configures retries of proxied calls on other providers.
+type FailoverConfig struct {
+	// RetryPolicy controls the number of attempts and the backoff between them.
+	// Each retry is sent to a different provider of the same service type.
+	// Failed attempts are passed to IsRetryable as connect errors: connection
+	// errors and 502/503/504 responses map to CodeUnavailable.
+	// Zero fields use the RetryInterceptor defaults.
+	RetryPolicy RetryPolicy
+
+	// CircuitBreaker configures the per-provider circuit breakers.
+	// Providers with an open circuit are skipped.
+	// Zero fields use the NewCircuitBreaker defaults.
+	CircuitBreaker CircuitBreakerConfig
+
+	// MaxReplayBodySize is the largest request body buffered for replay.
+	// Larger bodies and bodies of unknown length (streams) are sent once.
+	// Default: 64 KiB
+	MaxReplayBodySize int64
+
+	// IsIdempotent reports whether a procedure may be retried.
+	// Default: GET requests only (Connect's encoding for side-effect-free procedures).
+	IsIdempotent func(serviceType, method string, req *http.Request) bool
+}
+
+// SetFailover enables retrying idempotent calls on other providers when a
+// provider is unreachable or unavailable. Pass nil to disable failover.
+func (r *ServiceRouter) SetFailover(config *FailoverConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if config == nil {
+		r.failover = nil
+		r.breakers = make(map[string]*CircuitBreaker)
+		return
+	}
+
+	cfg := *config
+	cfg.RetryPolicy = cfg.RetryPolicy.withDefaults()
+	if cfg.MaxReplayBodySize <= 0 {
+		cfg.MaxReplayBodySize = 64 * 1024
+	}
+	if cfg.IsIdempotent == nil {
+		cfg.IsIdempotent = defaultIsIdempotent
+	}
+	r.failover = &cfg
+	r.breakers = make(map[string]*CircuitBreaker)
+}
+
+// defaultIsIdempotent treats GET requests as idempotent.
+func defaultIsIdempotent(serviceType, method string, req *http.Request) bool {
+	return req.Method == http.MethodGet
+}
+
+// breaker returns the circuit breaker for a provider, creating it on first use.
+func (r *ServiceRouter) breaker(runtimeID string, config CircuitBreakerConfig) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cb, ok := r.breakers[runtimeID]
+	if !ok {
+		cb = NewCircuitBreaker(config)
+		r.breakers[runtimeID] = cb
+	}
+	return cb
+}
+
+// providerBaseURL returns the base URL used to reach a provider.
+func (r *ServiceRouter) providerBaseURL(provider *ServiceProvider) (string, bool) {
+	// First try registered endpoint (Model A via Platform.AddPlugin)
+	r.mu.RLock()
+	baseURL, ok := r.pluginEndpoints[provider.RuntimeID]
+	r.mu.RUnlock()
+	if ok {
+		return baseURL, true
+	}
+
+	// Fall back to metadata base_url (Model B self-registration)
+	baseURL, ok = provider.Metadata["base_url"]
+	return baseURL, ok
+}
+
+// proxyWithFailover proxies a call, retrying on other providers of the same
+// service type when the attempt fails with a connection error or an
+// unavailable response. Only responses that will be returned to the caller
+// are written, so retries are invisible to the caller.
+// Returns status code, the provider whose response was returned (nil if
+// none) and any error.
+func (r *ServiceRouter) proxyWithFailover(w http.ResponseWriter, req *http.Request, config *FailoverConfig, caller *CallerInfo, first *ServiceProvider, method, minVersion string) (int, *ServiceProvider, error) {
+	ctx, cancel := callerDeadline(req)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+
+	policy := config.RetryPolicy
+	maxAttempts := 1
+	var body []byte
+	if config.IsIdempotent(first.ServiceType, method, req) {
+		var replayable bool
+		var err error
+		body, replayable, err = bufferBody(req, config.MaxReplayBodySize)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return http.StatusBadRequest, nil, err
+		}
+		if replayable {
+			maxAttempts = policy.MaxAttempts
+		}
+	}
+
+	tried := make(map[string]bool)
+	provider := first
+	var lastErr error
+
+	for attempt := 1; ; {
+		tried[provider.RuntimeID] = true
+
+		cb := r.breaker(provider.RuntimeID, config.CircuitBreaker)
+		baseURL, ok := r.providerBaseURL(provider)
+		var release func()
+		var slotErr error
+		if !cb.allowRequest() {
+			lastErr = connect.NewError(connect.CodeUnavailable,
+				fmt.Errorf("circuit open for provider %s", provider.RuntimeID))
+		} else if !ok {
+			lastErr = connect.NewError(connect.CodeUnavailable,
+				fmt.Errorf("provider endpoint not registered: %s", provider.RuntimeID))
+		} else if release, slotErr = r.acquireSlot(ctx, provider.RuntimeID, true); slotErr != nil {
+			lastErr = connect.NewError(connect.CodeUnavailable,
+				fmt.Errorf("%w: %s", slotErr, provider.RuntimeID))
+		} else {
+			targetURL := baseURL + provider.EndpointPath + strings.TrimPrefix(method, "/")
+			reqBody, contentLength := io.Reader(req.Body), req.ContentLength
+			if body != nil {
+				reqBody, contentLength = bytes.NewReader(body), int64(len(body))
+			}
+
+			resp, err := r.roundTrip(ctx, r.transportFor(provider.RuntimeID, req), req, caller, provider, targetURL, reqBody, contentLength)
+			if ctx.Err() != nil {
+				// Caller went away or its deadline passed; not the provider's fault
+				if resp != nil {
+					resp.Body.Close()
+				}
+				release()
+				status, err := writeProxyError(w, ctx.Err())
+				return status, nil, err
+			}
+			if err == nil {
+				if sizeErr := r.checkResponseSize(resp); sizeErr != nil {
+					resp.Body.Close()
+					release()
+					status, err := writeProxyError(w, sizeErr)
+					return status, nil, err
+				}
+			}
+
+			attemptErr := attemptError(resp, err)
+			cb.recordResult(attemptErr)
+			lastErr = attemptErr
+
+			if attemptErr == nil || attempt >= maxAttempts || !policy.IsRetryable(attemptErr) {
+				defer release()
+				if err != nil {
+					status, err := writeProxyError(w, err)
+					return status, nil, err
+				}
+				return resp.StatusCode, provider, writeProxyResponse(w, rc, resp)
+			}
+
+			// Return this response if there is nowhere else to send the call
+			next, selectErr := r.nextProvider(provider, minVersion, tried)
+			if selectErr != nil {
+				defer release()
+				if err != nil {
+					status, err := writeProxyError(w, err)
+					return status, nil, err
+				}
+				return resp.StatusCode, provider, writeProxyResponse(w, rc, resp)
+			}
+			if resp != nil {
+				resp.Body.Close()
+			}
+			release()
+
+			log.Printf("[ROUTER] %s %s failed (attempt %d/%d): %v; retrying on %s",
+				provider.RuntimeID, method, attempt, maxAttempts, attemptErr, next.RuntimeID)
+
+			// Wait before retry (unless context cancelled)
+			select {
+			case <-ctx.Done():
+				http.Error(w, "failed to proxy request", http.StatusBadGateway)
+				return http.StatusBadGateway, nil, lastErr
+			case <-time.After(policy.calculateBackoff(attempt)):
+			}
+
+			attempt++
+			provider = next
+			continue
+		}
+
+		// The request was not sent, so another provider may be tried
+		// even for procedures that are not idempotent
+		next, err := r.nextProvider(provider, minVersion, tried)
+		if err != nil {
+			break
+		}
+		provider = next
+	}
+
+	http.Error(w, "service unavailable: "+lastErr.Error(), http.StatusServiceUnavailable)
+	return http.StatusServiceUnavailable, nil, lastErr
+}
+
+// nextProvider selects an untried provider of the same service type in the
+// same namespace through the registry.
+func (r *ServiceRouter) nextProvider(failed *ServiceProvider, minVersion string, tried map[string]bool) (*ServiceProvider, error) {
+	draining := r.drainingProviders()
+	return r.registry.selectProvider(failed.Namespace, failed.ServiceType, minVersion, func(p *ServiceProvider) bool {
+		return tried[p.RuntimeID] || draining[p.RuntimeID]
+	})
+}
+
+// attemptError classifies the outcome of a proxied attempt.
+// Connection errors and 502/503/504 responses map to CodeUnavailable, as in
+// Connect's HTTP-to-code mapping. Other responses are passed through.
+func attemptError(resp *http.Response, err error) error {
+	if err != nil {
+		return connect.NewError(connect.CodeUnavailable, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return connect.NewError(connect.CodeUnavailable, fmt.Errorf("provider returned %s", resp.Status))
+	}
+	return nil
+}
+
+// bufferBody reads a request body of known length up to limit so it can be
+// replayed. If the body is larger or of unknown length, it is left unread
+// and replayable is false.
+func bufferBody(req *http.Request, limit int64) (body []byte, replayable bool, err error) {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return []byte{}, true, nil
+	}
+	if req.ContentLength < 0 || req.ContentLength > limit {
+		return nil, false, nil
+	}
+
+	body, err = io.ReadAll(io.LimitReader(req.Body, req.ContentLength))
+	if err != nil {
+		return nil, false, err
+	}
+	return body, true, nil
+}
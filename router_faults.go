@@ -0,0 +1,394 @@
+package connectplugin
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// FaultRule injects failures into routed calls for resilience testing.
+// Empty scope fields match any value. Delay is applied first, then at most
+// one of Abort, HTTPStatus, ConnectCode or TruncateAfter.
+type FaultRule struct {
+	// ServiceType limits the rule to a service type.
+	ServiceType string `json:"service_type,omitempty"`
+
+	// ProviderID limits the rule to a provider runtime ID.
+	ProviderID string `json:"provider_id,omitempty"`
+
+	// Method limits the rule to a procedure (e.g. "/Log").
+	Method string `json:"method,omitempty"`
+
+	// CallerID limits the rule to a caller runtime ID.
+	CallerID string `json:"caller_id,omitempty"`
+
+	// Percent of matching calls (1-100) the fault is injected into.
+	// Default: 100
+	Percent int `json:"percent,omitempty"`
+
+	// Delay is added before the call is proxied.
+	Delay time.Duration `json:"-"`
+
+	// DelayJitter adds a random extra delay up to this duration.
+	DelayJitter time.Duration `json:"-"`
+
+	// Abort closes the caller's connection without a response.
+	Abort bool `json:"abort,omitempty"`
+
+	// HTTPStatus responds with this status without calling the provider.
+	HTTPStatus int `json:"http_status,omitempty"`
+
+	// ConnectCode responds with a Connect (or gRPC) error with this code
+	// without calling the provider.
+	ConnectCode connect.Code `json:"connect_code,omitempty"`
+
+	// TruncateAfter aborts the provider's response after this many body bytes.
+	TruncateAfter int64 `json:"truncate_after,omitempty"`
+}
+
+// faultRuleJSON is the admin API form of FaultRule with readable durations.
+type faultRuleJSON struct {
+	FaultRule
+	Delay       string `json:"delay,omitempty"`
+	DelayJitter string `json:"delay_jitter,omitempty"`
+}
+
+// validate checks the rule's percentage and fault settings.
+func (f *FaultRule) validate() error {
+	if f.Percent < 0 || f.Percent > 100 {
+		return fmt.Errorf("fault percent must be 0-100, got %d", f.Percent)
+	}
+	if f.Delay < 0 || f.DelayJitter < 0 {
+		return fmt.Errorf("fault delay must not be negative")
+	}
+	if f.HTTPStatus != 0 && (f.HTTPStatus < 100 || f.HTTPStatus > 599) {
+		return fmt.Errorf("invalid fault HTTP status %d", f.HTTPStatus)
+	}
+	if f.TruncateAfter < 0 {
+		return fmt.Errorf("fault truncate_after must not be negative")
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to a call.
+func (f *FaultRule) matches(callerID, serviceType, providerID, method string) bool {
+	return (f.ServiceType == "" || f.ServiceType == serviceType) &&
+		(f.ProviderID == "" || f.ProviderID == providerID) &&
+		(f.Method == "" || f.Method == method) &&
+		(f.CallerID == "" || f.CallerID == callerID)
+}
+
+// SetFault adds or replaces a named fault rule.
+func (r *ServiceRouter) SetFault(name string, rule FaultRule) error {
+	if name == "" {
+		return fmt.Errorf("fault name is required")
+	}
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	if rule.Percent == 0 {
+		rule.Percent = 100
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults[name] = rule
+	return nil
+}
+
+// ClearFault removes a named fault rule.
+func (r *ServiceRouter) ClearFault(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.faults, name)
+}
+
+// ClearFaults removes all fault rules.
+func (r *ServiceRouter) ClearFaults() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults = make(map[string]FaultRule)
+}
+
+// Faults returns the active fault rules by name.
+func (r *ServiceRouter) Faults() map[string]FaultRule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	faults := make(map[string]FaultRule, len(r.faults))
+	for name, rule := range r.faults {
+		faults[name] = rule
+	}
+	return faults
+}
+
+// selectFault returns the first matching rule (by name) whose percentage
+// roll selects this call.
+func (r *ServiceRouter) selectFault(callerID, serviceType, providerID, method string) (string, *FaultRule) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.faults) == 0 {
+		return "", nil
+	}
+
+	names := make([]string, 0, len(r.faults))
+	for name := range r.faults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rule := r.faults[name]
+		if rule.matches(callerID, serviceType, providerID, method) && rand.Intn(100) < rule.Percent {
+			return name, &rule
+		}
+	}
+	return "", nil
+}
+
+// injectFault applies a fault rule to a call. Returns the writer to proxy
+// through and whether the call was answered by the fault.
+func injectFault(w http.ResponseWriter, req *http.Request, rule *FaultRule) (http.ResponseWriter, bool) {
+	delay := rule.Delay
+	if rule.DelayJitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(rule.DelayJitter)))
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return w, true
+		}
+	}
+
+	switch {
+	case rule.Abort:
+		panic(http.ErrAbortHandler)
+	case rule.HTTPStatus != 0:
+		http.Error(w, "injected fault", rule.HTTPStatus)
+		return w, true
+	case rule.ConnectCode != 0:
+		writeConnectError(w, req, connect.NewError(rule.ConnectCode, fmt.Errorf("injected fault")))
+		return w, true
+	case rule.TruncateAfter > 0:
+		return &truncatingWriter{ResponseWriter: w, remaining: rule.TruncateAfter}, false
+	}
+	return w, false
+}
+
+// writeConnectError writes err in the protocol of the caller's request:
+// gRPC (trailers-only), Connect streaming (end-stream message) or Connect unary.
+func writeConnectError(w http.ResponseWriter, req *http.Request, err *connect.Error) {
+	contentType := req.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc"):
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Grpc-Status", fmt.Sprint(uint32(err.Code())))
+		w.Header().Set("Grpc-Message", err.Message())
+		w.WriteHeader(http.StatusOK)
+
+	case strings.HasPrefix(contentType, "application/connect+"):
+		payload, _ := json.Marshal(map[string]any{
+			"error": map[string]string{"code": err.Code().String(), "message": err.Message()},
+		})
+		prefix := make([]byte, 5)
+		prefix[0] = 0x02 // end-stream flag
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(payload)))
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(prefix)
+		w.Write(payload)
+
+	default:
+		payload, _ := json.Marshal(map[string]string{"code": err.Code().String(), "message": err.Message()})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(connectHTTPStatus(err.Code()))
+		w.Write(payload)
+	}
+}
+
+// connectHTTPStatus maps a Connect code to the HTTP status used by the
+// Connect protocol for unary errors.
+func connectHTTPStatus(code connect.Code) int {
+	switch code {
+	case connect.CodeCanceled:
+		return 499
+	case connect.CodeInvalidArgument, connect.CodeFailedPrecondition, connect.CodeOutOfRange:
+		return http.StatusBadRequest
+	case connect.CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case connect.CodeNotFound:
+		return http.StatusNotFound
+	case connect.CodeAlreadyExists, connect.CodeAborted:
+		return http.StatusConflict
+	case connect.CodePermissionDenied:
+		return http.StatusForbidden
+	case connect.CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case connect.CodeUnimplemented:
+		return http.StatusNotImplemented
+	case connect.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case connect.CodeUnauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// truncatingWriter aborts the response after a number of body bytes.
+type truncatingWriter struct {
+	http.ResponseWriter
+	remaining int64
+}
+
+func (t *truncatingWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) <= t.remaining {
+		t.remaining -= int64(len(p))
+		return t.ResponseWriter.Write(p)
+	}
+
+	// Send what fits, then cut the stream
+	t.ResponseWriter.Write(p[:t.remaining])
+	http.NewResponseController(t.ResponseWriter).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (t *truncatingWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// FaultAdminOptions configures FaultAdminHandlerWithOptions.
+type FaultAdminOptions struct {
+	// AdminToken is a credential operators present as
+	// "Authorization: Bearer <token>", e.g. generated with GenerateHostSecret
+	// and kept in a secret store. Admin identities can still authenticate
+	// with their runtime token.
+	// Default: "" (runtime tokens only)
+	AdminToken string
+}
+
+// FaultAdminHandler returns an HTTP handler for managing fault rules at runtime:
+//
+//	GET    /faults         list rules
+//	PUT    /faults/{name}  add or replace a rule (JSON FaultRule; delays as "250ms")
+//	DELETE /faults/{name}  remove a rule
+//	DELETE /faults         remove all rules
+//
+// Callers authenticate with their runtime token and must be registry admin
+// identities (see ServiceRegistry.SetAdminIdentities). Use
+// FaultAdminHandlerWithOptions to accept an operator credential.
+func (r *ServiceRouter) FaultAdminHandler() http.Handler {
+	return r.FaultAdminHandlerWithOptions(FaultAdminOptions{})
+}
+
+// FaultAdminHandlerWithOptions is FaultAdminHandler that also accepts the
+// configured admin token.
+func (r *ServiceRouter) FaultAdminHandlerWithOptions(opts FaultAdminOptions) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /faults", func(w http.ResponseWriter, req *http.Request) {
+		faults := make(map[string]faultRuleJSON)
+		for name, rule := range r.Faults() {
+			faults[name] = faultRuleJSON{
+				FaultRule:   rule,
+				Delay:       formatDuration(rule.Delay),
+				DelayJitter: formatDuration(rule.DelayJitter),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(faults)
+	})
+
+	mux.HandleFunc("PUT /faults/{name}", func(w http.ResponseWriter, req *http.Request) {
+		var body faultRuleJSON
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid fault rule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rule := body.FaultRule
+		var err error
+		if rule.Delay, err = parseDuration(body.Delay); err != nil {
+			http.Error(w, "invalid delay: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if rule.DelayJitter, err = parseDuration(body.DelayJitter); err != nil {
+			http.Error(w, "invalid delay_jitter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		name := req.PathValue("name")
+		if err := r.SetFault(name, rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ROUTER] Fault %q enabled", name)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("DELETE /faults/{name}", func(w http.ResponseWriter, req *http.Request) {
+		name := req.PathValue("name")
+		r.ClearFault(name)
+		log.Printf("[ROUTER] Fault %q cleared", name)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("DELETE /faults", func(w http.ResponseWriter, req *http.Request) {
+		r.ClearFaults()
+		log.Printf("[ROUTER] All faults cleared")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	adminToken := []byte(opts.AdminToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if len(adminToken) > 0 {
+			bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			// Use constant-time comparison to prevent timing attacks
+			if ok && subtle.ConstantTimeCompare(adminToken, []byte(bearer)) == 1 {
+				mux.ServeHTTP(w, req)
+				return
+			}
+		}
+
+		auth, err := r.handshakeServer.authenticateRuntime(req.Header)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !r.registry.isAdmin(auth.Identity) {
+			http.Error(w, "forbidden: not an admin identity", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, req)
+	})
+}
+
+// parseDuration parses an optional duration string.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// formatDuration formats an optional duration.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
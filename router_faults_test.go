@@ -0,0 +1,77 @@
+package connectplugin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	connectplugin "github.com/masegraye/connect-plugin-go"
+)
+
+func TestFaultAdminHandler_AdminToken(t *testing.T) {
+	handshake := connectplugin.NewHandshakeServer(&connectplugin.ServeConfig{})
+	lifecycle := connectplugin.NewLifecycleServer()
+	registry := connectplugin.NewServiceRegistry(lifecycle)
+	router := connectplugin.NewServiceRouter(handshake, registry, lifecycle)
+
+	adminToken, err := connectplugin.GenerateHostSecret()
+	if err != nil {
+		t.Fatalf("GenerateHostSecret failed: %v", err)
+	}
+
+	// Mounted the way a host would serve it to operators
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", http.StripPrefix("/admin", router.FaultAdminHandlerWithOptions(connectplugin.FaultAdminOptions{
+		AdminToken: adminToken,
+	})))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	do := func(method, path, token, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// Without the admin token operators are rejected
+	if resp := do("GET", "/admin/faults", "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a credential, got %d", resp.StatusCode)
+	}
+	if resp := do("PUT", "/admin/faults/slow-kv", "guess", `{"service_type": "kv"}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", resp.StatusCode)
+	}
+	if len(router.Faults()) != 0 {
+		t.Fatalf("Expected no faults, got %v", router.Faults())
+	}
+
+	resp := do("PUT", "/admin/faults/slow-kv", adminToken, `{"service_type": "kv", "percent": 10, "delay": "250ms"}`)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected 204 from PUT, got %d", resp.StatusCode)
+	}
+	if rule := router.Faults()["slow-kv"]; rule.ServiceType != "kv" || rule.Delay != 250*time.Millisecond {
+		t.Errorf("Unexpected rule: %+v", rule)
+	}
+
+	var listed map[string]map[string]any
+	if err := json.NewDecoder(do("GET", "/admin/faults", adminToken, "").Body).Decode(&listed); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if listed["slow-kv"]["delay"] != "250ms" {
+		t.Errorf("Expected listed fault with delay, got %v", listed)
+	}
+
+	if resp := do("DELETE", "/admin/faults", adminToken, ""); resp.StatusCode != http.StatusNoContent || len(router.Faults()) != 0 {
+		t.Errorf("Expected faults cleared, got %d with %v", resp.StatusCode, router.Faults())
+	}
+}
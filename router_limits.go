@@ -0,0 +1,222 @@
+package connectplugin
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RouterConfig tunes the ServiceRouter's transport, limits and header handling.
+// Zero values use the defaults noted on each field.
+type RouterConfig struct {
+	// MaxIdleConnsPerHost is the number of idle keep-alive connections kept
+	// per provider.
+	// Default: 32
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost caps connections per provider, including active ones.
+	// Default: 0 (unlimited)
+	MaxConnsPerHost int
+
+	// IdleConnTimeout closes idle connections after this duration.
+	// Default: 90s
+	IdleConnTimeout time.Duration
+
+	// DialTimeout bounds establishing a connection to a provider.
+	// Default: 30s
+	DialTimeout time.Duration
+
+	// MaxConcurrentPerProvider caps in-flight calls per provider.
+	// Default: 0 (unlimited)
+	MaxConcurrentPerProvider int
+
+	// QueueTimeout is how long a call waits for a slot when its provider is at
+	// MaxConcurrentPerProvider. Zero rejects immediately with 503.
+	// Default: 0
+	QueueTimeout time.Duration
+
+	// MaxRequestBodySize rejects request bodies larger than this with 413.
+	// Default: 0 (unlimited)
+	MaxRequestBodySize int64
+
+	// MaxResponseBodySize aborts responses larger than this. Responses with a
+	// known larger size are rejected with 502 before anything is written.
+	// Default: 0 (unlimited)
+	MaxResponseBodySize int64
+
+	// StripHeaders lists additional request headers removed before proxying.
+	StripHeaders []string
+
+	// TrustForwardedHeaders keeps X-Forwarded-* headers sent by callers and
+	// appends to them. By default they are replaced, since callers could
+	// otherwise spoof them.
+	TrustForwardedHeaders bool
+
+	// CallerSigningKey signs the caller context attached to proxied calls.
+	// Providers verify it with CallerVerificationKey.
+	// Default: generated at startup
+	CallerSigningKey ed25519.PrivateKey
+}
+
+// withDefaults returns the config with unset fields filled in.
+func (c RouterConfig) withDefaults() RouterConfig {
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = 32
+	}
+	if c.IdleConnTimeout <= 0 {
+		c.IdleConnTimeout = 90 * time.Second
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = 30 * time.Second
+	}
+	if c.CallerSigningKey == nil {
+		// crypto/rand never fails on supported platforms
+		_, c.CallerSigningKey, _ = ed25519.GenerateKey(nil)
+	}
+	return c
+}
+
+// forwardedHeaders are set by the router to describe the original call.
+var forwardedHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+}
+
+// errResponseTooLarge is returned when a provider response exceeds
+// MaxResponseBodySize.
+var errResponseTooLarge = errors.New("response body too large")
+
+// errProviderAtCapacity is returned when a provider has no free slot.
+var errProviderAtCapacity = errors.New("provider at capacity")
+
+// acquireSlot counts a call to the provider as in flight and reserves one
+// of its concurrent call slots, waiting up to QueueTimeout. The returned
+// release func must be called when the call completes. Fails while the
+// provider is draining; without a concurrency limit it otherwise succeeds.
+func (r *ServiceRouter) acquireSlot(ctx context.Context, runtimeID string, wait bool) (func(), error) {
+	done, err := r.beginCall(runtimeID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := r.config.MaxConcurrentPerProvider
+	if limit <= 0 {
+		return done, nil
+	}
+
+	r.mu.Lock()
+	slots, ok := r.slots[runtimeID]
+	if !ok {
+		slots = make(chan struct{}, limit)
+		r.slots[runtimeID] = slots
+	}
+	r.mu.Unlock()
+
+	release := func() {
+		<-slots
+		done()
+	}
+
+	// Fast path: a slot is free
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if !wait || r.config.QueueTimeout <= 0 {
+		done()
+		return nil, errProviderAtCapacity
+	}
+
+	timer := time.NewTimer(r.config.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		done()
+		return nil, errProviderAtCapacity
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+}
+
+// sanitizeRequestHeaders removes configured headers and sets forwarded
+// headers describing the original call.
+func (r *ServiceRouter) sanitizeRequestHeaders(h http.Header, req *http.Request) {
+	for _, name := range r.config.StripHeaders {
+		h.Del(name)
+	}
+
+	prior := h.Values("X-Forwarded-For")
+	if !r.config.TrustForwardedHeaders {
+		prior = nil
+		for _, name := range forwardedHeaders {
+			h.Del(name)
+		}
+	}
+
+	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if len(prior) > 0 {
+			clientIP = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		h.Set("X-Forwarded-For", clientIP)
+	}
+	if h.Get("X-Forwarded-Host") == "" {
+		h.Set("X-Forwarded-Host", req.Host)
+	}
+	if h.Get("X-Forwarded-Proto") == "" {
+		proto := "http"
+		if req.TLS != nil {
+			proto = "https"
+		}
+		h.Set("X-Forwarded-Proto", proto)
+	}
+}
+
+// limitedBody fails with errResponseTooLarge once more than limit bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		// Drop the byte past the limit
+		return n - 1, errResponseTooLarge
+	}
+	return n, err
+}
+
+// checkResponseSize rejects responses whose declared size exceeds the limit
+// and bounds the rest while they stream.
+func (r *ServiceRouter) checkResponseSize(resp *http.Response) error {
+	limit := r.config.MaxResponseBodySize
+	if limit <= 0 {
+		return nil
+	}
+	if resp.ContentLength > limit {
+		return fmt.Errorf("%w: %d bytes (max %d)", errResponseTooLarge, resp.ContentLength, limit)
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: limit}
+	return nil
+}
@@ -0,0 +1,293 @@
+package connectplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// hopByHopHeaders are connection-scoped headers that must not be forwarded
+// by a proxy (RFC 9110 section 7.6.1).
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// proxyTransports holds the shared transports used to reach providers.
+// Connections are reused across requests.
+type proxyTransports struct {
+	// http1 is used for HTTP/1.x callers (unary and server-streaming RPCs).
+	http1 *http.Transport
+
+	// http2 is used for streaming and gRPC calls from HTTP/2 callers so client
+	// and bidi streams work end-to-end. Plaintext providers are reached with
+	// HTTP/2 prior knowledge (h2c).
+	http2 *http.Transport
+}
+
+// newProxyTransports creates the router's shared transports.
+func newProxyTransports(config RouterConfig) *proxyTransports {
+	newTransport := func() *http.Transport {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		t.MaxIdleConns = 0 // bounded per host
+		t.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+		t.MaxConnsPerHost = config.MaxConnsPerHost
+		t.IdleConnTimeout = config.IdleConnTimeout
+		return t
+	}
+
+	http1 := newTransport()
+	http1.ForceAttemptHTTP2 = false
+
+	http2 := newTransport()
+	http2.Protocols = new(http.Protocols)
+	http2.Protocols.SetHTTP2(true)
+	http2.Protocols.SetUnencryptedHTTP2(true)
+
+	return &proxyTransports{http1: http1, http2: http2}
+}
+
+// forRequest returns the transport for a call. Only streaming and gRPC
+// calls from HTTP/2 callers need HTTP/2 to the provider; everything else
+// uses HTTP/1.1, which every provider speaks, so providers without h2c
+// support keep working for unary calls.
+func (t *proxyTransports) forRequest(req *http.Request) http.RoundTripper {
+	if req.ProtoMajor == 2 && isStreamingCall(req) {
+		return t.http2
+	}
+	return t.http1
+}
+
+// isStreamingCall reports whether a request is a Connect streaming or gRPC
+// call, judging by its content type.
+func isStreamingCall(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, "application/connect+") ||
+		strings.HasPrefix(contentType, "application/grpc")
+}
+
+// clientTransport adapts a connect.HTTPClient for use as a proxy transport.
+// The client's own transport is used directly when available so redirects
+// and cookies are left to the caller.
+func clientTransport(client connect.HTTPClient) http.RoundTripper {
+	if c, ok := client.(*http.Client); ok && c.Transport != nil {
+		return c.Transport
+	}
+	return httpClientTransport{client}
+}
+
+// httpClientTransport sends requests through a connect.HTTPClient.
+type httpClientTransport struct {
+	client connect.HTTPClient
+}
+
+func (t httpClientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.client.Do(req)
+}
+
+// proxyRequest proxies an HTTP request to the target URL.
+// The response body is streamed to the caller and flushed as it arrives, and
+// trailers are propagated, so Connect and gRPC streaming RPCs work through the
+// router. The call is bound to the caller's context and Connect-Timeout-Ms
+// deadline rather than a fixed timeout.
+// Returns status code and any error.
+func (r *ServiceRouter) proxyRequest(w http.ResponseWriter, req *http.Request, caller *CallerInfo, provider *ServiceProvider, targetURL string) (int, error) {
+	ctx, cancel := callerDeadline(req)
+	defer cancel()
+
+	// Allow reading the request body while writing the response (HTTP/1 streams).
+	// Not all ResponseWriters support this; HTTP/2 is always full duplex.
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+
+	release, err := r.acquireSlot(ctx, provider.RuntimeID, true)
+	if err != nil {
+		http.Error(w, "service unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return http.StatusServiceUnavailable, err
+	}
+	defer release()
+
+	resp, err := r.roundTrip(ctx, r.transportFor(provider.RuntimeID, req), req, caller, provider, targetURL, req.Body, req.ContentLength)
+	if err != nil {
+		return writeProxyError(w, err)
+	}
+	if err := r.checkResponseSize(resp); err != nil {
+		resp.Body.Close()
+		return writeProxyError(w, err)
+	}
+	return resp.StatusCode, writeProxyResponse(w, rc, resp)
+}
+
+// writeProxyError reports a failed proxy attempt to the caller.
+// Returns status code and the error.
+func writeProxyError(w http.ResponseWriter, err error) (int, error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, fmt.Sprintf("request body too large (max %d bytes)", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		return http.StatusRequestEntityTooLarge, err
+	}
+	if errors.Is(err, errResponseTooLarge) {
+		http.Error(w, "provider response too large", http.StatusBadGateway)
+		return http.StatusBadGateway, err
+	}
+	http.Error(w, "failed to proxy request", http.StatusBadGateway)
+	return http.StatusBadGateway, err
+}
+
+// roundTrip sends one proxied request to the target URL with the given body
+// and the caller context signed for the provider.
+// The caller must close the response body.
+func (r *ServiceRouter) roundTrip(ctx context.Context, transport http.RoundTripper, req *http.Request, caller *CallerInfo, provider *ServiceProvider, targetURL string, body io.Reader, contentLength int64) (*http.Response, error) {
+	callerContext, err := r.signCaller(caller, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create proxy request with query parameters from original request
+	fullURL := targetURL
+	if req.URL.RawQuery != "" {
+		fullURL = targetURL + "?" + req.URL.RawQuery
+	}
+
+	proxyReq, err := http.NewRequestWithContext(ctx, req.Method, fullURL, body)
+	if err != nil {
+		return nil, err
+	}
+	proxyReq.ContentLength = contentLength
+
+	// Copy headers (except Authorization, X-Plugin-Runtime-ID and hop-by-hop headers)
+	for key, values := range req.Header {
+		// Header keys are canonicalized, so compare with canonical form
+		canonicalKey := http.CanonicalHeaderKey(key)
+		if canonicalKey == "Authorization" || canonicalKey == "X-Plugin-Runtime-Id" {
+			continue
+		}
+		for _, value := range values {
+			proxyReq.Header.Add(key, value)
+		}
+	}
+	removeHopByHopHeaders(proxyReq.Header)
+	r.sanitizeRequestHeaders(proxyReq.Header, req)
+	proxyReq.Header.Set(CallerContextHeader, callerContext)
+
+	// gRPC requires "TE: trailers" to reach the provider
+	if headerHasToken(req.Header, "Te", "trailers") {
+		proxyReq.Header.Set("Te", "trailers")
+	}
+
+	return transport.RoundTrip(proxyReq)
+}
+
+// writeProxyResponse streams a provider response to the caller, including
+// trailers, and closes its body.
+func writeProxyResponse(w http.ResponseWriter, rc *http.ResponseController, resp *http.Response) error {
+	defer resp.Body.Close()
+
+	// Copy response headers
+	removeHopByHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	// Copy status code
+	w.WriteHeader(resp.StatusCode)
+
+	// Stream response body, flushing each chunk
+	copyErr := copyAndFlush(w, rc, resp.Body)
+	if errors.Is(copyErr, errResponseTooLarge) {
+		// Headers are already sent; abort so the caller sees a failed call
+		// rather than a silently truncated response
+		log.Printf("[ROUTER] Aborting response: %v", copyErr)
+		panic(http.ErrAbortHandler)
+	}
+
+	// Copy trailers (available once the body is fully read)
+	for key, values := range resp.Trailer {
+		for _, value := range values {
+			w.Header().Add(http.TrailerPrefix+key, value)
+		}
+	}
+
+	return copyErr
+}
+
+// copyAndFlush copies src to w, flushing after every write so streamed
+// messages reach the caller immediately.
+func copyAndFlush(w io.Writer, rc *http.ResponseController, src io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// callerDeadline derives the proxy context from the caller's request.
+// Honors the Connect-Timeout-Ms header if present.
+func callerDeadline(req *http.Request) (context.Context, context.CancelFunc) {
+	if ms, err := strconv.ParseInt(req.Header.Get("Connect-Timeout-Ms"), 10, 64); err == nil && ms > 0 {
+		return context.WithTimeout(req.Context(), time.Duration(ms)*time.Millisecond)
+	}
+	return context.WithCancel(req.Context())
+}
+
+// removeHopByHopHeaders deletes connection-scoped headers, including any
+// listed in the Connection header.
+func removeHopByHopHeaders(h http.Header) {
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// headerHasToken reports whether a comma-separated header contains token.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -6,11 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"connectrpc.com/connect"
 	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
 )
 
 // registerTestToken is a helper to register a token with expiration for testing.
@@ -43,15 +46,13 @@ func TestServiceRouter_ValidRequest(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/logger.v1.Logger/",
 	})
-	regReq.Header().Set("X-Plugin-Runtime-ID", runtimeID)
-	registry.RegisterService(context.Background(), regReq)
+	registry.RegisterService(runtimeContext(runtimeID), regReq)
 
 	// Mark provider as healthy
 	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
 	})
-	healthReq.Header().Set("X-Plugin-Runtime-ID", runtimeID)
-	lifecycle.ReportHealth(context.Background(), healthReq)
+	lifecycle.ReportHealth(runtimeContext(runtimeID), healthReq)
 
 	// Create mock provider server
 	providerCalled := false
@@ -164,6 +165,160 @@ func TestServiceRouter_ProviderNotFound(t *testing.T) {
 	}
 }
 
+func TestServiceRouter_CrossNamespaceNotFound(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	// Provider in tenant-a
+	providerID, _ := generateRuntimeID("logger-plugin")
+	registry.SetNamespace(providerID, "tenant-a")
+	regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+		ServiceType:  "logger",
+		Version:      "1.0.0",
+		EndpointPath: "/logger.v1.Logger/",
+		Metadata:     map[string]string{"base_url": "http://127.0.0.1:1"},
+	})
+	registry.RegisterService(runtimeContext(providerID), regReq)
+
+	// Caller in tenant-b
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+	registry.SetNamespace(callerID, "tenant-b")
+
+	req := httptest.NewRequest("POST", "/services/logger/"+providerID+"/Log", nil)
+	req.Header.Set("X-Plugin-Runtime-ID", callerID)
+	req.Header.Set("Authorization", "Bearer "+callerToken)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 Not Found across namespaces, got %d", w.Code)
+	}
+}
+
+func TestServiceRouter_DependencyEnforcement(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	router.SetEnforceDependencies(true)
+
+	providerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer providerServer.Close()
+
+	// Provider offers logger and secrets
+	providerID, _ := generateRuntimeID("multi-plugin")
+	for _, serviceType := range []string{"logger", "secrets"} {
+		regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  serviceType,
+			Version:      "1.0.0",
+			EndpointPath: "/" + serviceType + ".v1.Service/",
+		})
+		registry.RegisterService(runtimeContext(providerID), regReq)
+	}
+	router.RegisterPluginEndpoint(providerID, providerServer.URL)
+
+	// Caller declares only logger
+	callerID, _ := generateRuntimeID("app")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+	platform.AddToDependencyGraph(callerID, "app", nil, []ServiceDependency{{Type: "logger"}})
+
+	call := func(serviceType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/services/"+serviceType+"/"+providerID+"/Call", nil)
+		req.Header.Set("X-Plugin-Runtime-ID", callerID)
+		req.Header.Set("Authorization", "Bearer "+callerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call("logger"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for declared dependency, got %d", w.Code)
+	}
+
+	w := call("secrets")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 for undeclared dependency, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "did not declare a dependency on service type secrets") {
+		t.Errorf("Expected clear reason, got %q", w.Body.String())
+	}
+
+	// Explicit allow overrides enforcement
+	router.SetAccessRule(callerID, "secrets", AccessAllow)
+	if w := call("secrets"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 with explicit allow, got %d", w.Code)
+	}
+
+	// Explicit deny overrides declared dependency
+	router.SetAccessRule(callerID, "logger", AccessDeny)
+	if w := call("logger"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 with explicit deny, got %d", w.Code)
+	}
+}
+
+func TestServiceRouter_DependencyEnforcementHandshakeCaller(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	router.SetEnforceDependencies(true)
+
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "logger",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// A self-registering plugin gets its identity from the handshake and
+	// declares no requirements to the host
+	hsResp, err := handshake.Handshake(context.Background(), connect.NewRequest(&connectpluginv1.HandshakeRequest{
+		CoreProtocolVersion: 1,
+		AppProtocolVersion:  1,
+		MagicCookieKey:      DefaultMagicCookieKey,
+		MagicCookieValue:    DefaultMagicCookieValue,
+		SelfId:              "app",
+	}))
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+
+	call := func(callerID, token string) int {
+		req := httptest.NewRequest("POST", "/services/logger/"+providerID+"/Log", nil)
+		req.Header.Set(RuntimeIDHeader, callerID)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	callerID, token := hsResp.Msg.RuntimeId, hsResp.Msg.RuntimeToken
+	if code := call(callerID, token); code != http.StatusOK {
+		t.Errorf("Expected handshake caller without a declaration to be allowed, got %d", code)
+	}
+
+	// Access rules still restrict it
+	router.SetAccessRule(callerID, "logger", AccessDeny)
+	if code := call(callerID, token); code != http.StatusForbidden {
+		t.Errorf("Expected 403 with explicit deny, got %d", code)
+	}
+
+	// A plugin that declared no requirements is restricted
+	managedID, _ := generateRuntimeID("worker")
+	managedToken, _ := generateToken()
+	registerTestToken(handshake, managedID, managedToken)
+	platform.AddToDependencyGraph(managedID, "worker", nil, nil)
+	if code := call(managedID, managedToken); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for plugin declaring no dependencies, got %d", code)
+	}
+}
+
 func TestServiceRouter_UnhealthyProvider(t *testing.T) {
 	handshake := NewHandshakeServer(&ServeConfig{})
 	lifecycle := NewLifecycleServer()
@@ -180,15 +335,13 @@ func TestServiceRouter_UnhealthyProvider(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/logger.v1.Logger/",
 	})
-	regReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	registry.RegisterService(context.Background(), regReq)
+	registry.RegisterService(runtimeContext(providerID), regReq)
 
 	// Mark provider as UNHEALTHY
 	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_UNHEALTHY,
 	})
-	healthReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	lifecycle.ReportHealth(context.Background(), healthReq)
+	lifecycle.ReportHealth(runtimeContext(providerID), healthReq)
 
 	// Register caller
 	callerID, _ := generateRuntimeID("caller")
@@ -223,16 +376,14 @@ func TestServiceRouter_DegradedProviderStillRoutes(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/cache.v1.Cache/",
 	})
-	regReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	registry.RegisterService(context.Background(), regReq)
+	registry.RegisterService(runtimeContext(providerID), regReq)
 
 	// Mark provider as DEGRADED
 	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State:  connectpluginv1.HealthState_HEALTH_STATE_DEGRADED,
 		Reason: "using in-memory fallback",
 	})
-	healthReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	lifecycle.ReportHealth(context.Background(), healthReq)
+	lifecycle.ReportHealth(runtimeContext(providerID), healthReq)
 
 	// Create mock provider server
 	providerCalled := false
@@ -311,14 +462,12 @@ func TestServiceRouter_ProxiesHeaders(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/api.v1.API/",
 	})
-	regReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	registry.RegisterService(context.Background(), regReq)
+	registry.RegisterService(runtimeContext(providerID), regReq)
 
 	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
 	})
-	healthReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	lifecycle.ReportHealth(context.Background(), healthReq)
+	lifecycle.ReportHealth(runtimeContext(providerID), healthReq)
 
 	// Create mock provider that checks headers
 	receivedHeaders := make(http.Header)
@@ -382,14 +531,12 @@ func TestServiceRouter_ProxiesBody(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/echo.v1.Echo/",
 	})
-	regReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	registry.RegisterService(context.Background(), regReq)
+	registry.RegisterService(runtimeContext(providerID), regReq)
 
 	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
 	})
-	healthReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	lifecycle.ReportHealth(context.Background(), healthReq)
+	lifecycle.ReportHealth(runtimeContext(providerID), healthReq)
 
 	// Create echo server
 	providerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -438,14 +585,12 @@ func TestServiceRouter_ProviderEndpointNotRegistered(t *testing.T) {
 		Version:      "1.0.0",
 		EndpointPath: "/logger.v1.Logger/",
 	})
-	regReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	registry.RegisterService(context.Background(), regReq)
+	registry.RegisterService(runtimeContext(providerID), regReq)
 
 	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
 		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
 	})
-	healthReq.Header().Set("X-Plugin-Runtime-ID", providerID)
-	lifecycle.ReportHealth(context.Background(), healthReq)
+	lifecycle.ReportHealth(runtimeContext(providerID), healthReq)
 
 	// Note: NOT calling router.RegisterPluginEndpoint()
 
@@ -469,3 +614,1202 @@ func TestServiceRouter_ProviderEndpointNotRegistered(t *testing.T) {
 		t.Errorf("Expected error message about endpoint not registered, got: %s", w.Body.String())
 	}
 }
+
+// newStreamingTestRouter registers a provider backed by handler and serves the
+// router over HTTP/1 and h2c. Returns the router URL, provider ID and caller headers.
+func newStreamingTestRouter(t *testing.T, serviceType string, handler http.Handler) (string, string, http.Header) {
+	t.Helper()
+
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	h2c := new(http.Protocols)
+	h2c.SetHTTP1(true)
+	h2c.SetUnencryptedHTTP2(true)
+
+	provider := httptest.NewUnstartedServer(handler)
+	provider.Config.Protocols = h2c
+	provider.Start()
+	t.Cleanup(provider.Close)
+
+	providerID, _ := generateRuntimeID(serviceType + "-plugin")
+	regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+		ServiceType:  serviceType,
+		Version:      "1.0.0",
+		EndpointPath: "/",
+	})
+	if _, err := registry.RegisterService(runtimeContext(providerID), regReq); err != nil {
+		t.Fatalf("RegisterService failed: %v", err)
+	}
+	router.RegisterPluginEndpoint(providerID, provider.URL)
+
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+
+	host := httptest.NewUnstartedServer(router)
+	host.Config.Protocols = h2c
+	host.Start()
+	t.Cleanup(host.Close)
+
+	header := http.Header{}
+	header.Set("X-Plugin-Runtime-ID", callerID)
+	header.Set("Authorization", "Bearer "+callerToken)
+	return host.URL, providerID, header
+}
+
+func TestServiceRouter_ServerStreaming(t *testing.T) {
+	// Provider is itself a registry, so WatchService is a real Connect server stream
+	inner := NewServiceRegistry(nil)
+	_, handler := ServiceRegistryHandler(inner)
+	hostURL, providerID, header := newStreamingTestRouter(t, "registry", handler)
+
+	client := connectpluginv1connect.NewServiceRegistryClient(http.DefaultClient, hostURL+"/services/registry/"+providerID)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := connect.NewRequest(&connectpluginv1.WatchServiceRequest{ServiceType: "logger"})
+	for key, values := range header {
+		req.Header()[key] = values
+	}
+	stream, err := client.WatchService(ctx, req)
+	if err != nil {
+		t.Fatalf("WatchService failed: %v", err)
+	}
+
+	if !stream.Receive() {
+		t.Fatalf("Expected initial event: %v", stream.Err())
+	}
+	if stream.Msg().State != connectpluginv1.ServiceState_SERVICE_STATE_UNAVAILABLE {
+		t.Errorf("Expected UNAVAILABLE initial event, got %v", stream.Msg().State)
+	}
+
+	// A later event must arrive while the stream is still open
+	regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+		ServiceType:  "logger",
+		Version:      "1.0.0",
+		EndpointPath: "/logger.v1.Logger/",
+	})
+	inner.RegisterService(runtimeContext("logger-a"), regReq)
+
+	if !stream.Receive() {
+		t.Fatalf("Expected streamed event: %v", stream.Err())
+	}
+	if stream.Msg().State != connectpluginv1.ServiceState_SERVICE_STATE_AVAILABLE {
+		t.Errorf("Expected AVAILABLE event, got %v", stream.Msg().State)
+	}
+}
+
+func TestServiceRouter_HTTP2FullDuplexAndTrailers(t *testing.T) {
+	// Echo each request chunk as soon as it is read, then send a trailer
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("Expected HTTP/2 to provider, got %s", r.Proto)
+		}
+		w.Header().Set("Trailer", "X-Echo-Status")
+		w.WriteHeader(http.StatusOK)
+		rc := http.NewResponseController(w)
+		rc.Flush()
+
+		buf := make([]byte, 64)
+		for {
+			n, err := r.Body.Read(buf)
+			if n > 0 {
+				w.Write(buf[:n])
+				rc.Flush()
+			}
+			if err != nil {
+				break
+			}
+		}
+		w.Header().Set("X-Echo-Status", "done")
+	})
+	hostURL, providerID, header := newStreamingTestRouter(t, "echo", echo)
+
+	h2c := new(http.Protocols)
+	h2c.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: h2c}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bodyReader, bodyWriter := io.Pipe()
+	req, _ := http.NewRequestWithContext(ctx, "POST", hostURL+"/services/echo/"+providerID+"/Echo", bodyReader)
+	req.Header = header
+	req.Header.Set("Content-Type", "application/connect+proto")
+
+	respCh := make(chan *http.Response, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := client.Do(req)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		respCh <- resp
+	}()
+
+	// Each chunk is echoed before the next is sent (bidi)
+	bodyWriter.Write([]byte("ping"))
+	var resp *http.Response
+	select {
+	case resp = <-respCh:
+	case err := <-errCh:
+		t.Fatalf("Request failed: %v", err)
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for response headers")
+	}
+	defer resp.Body.Close()
+
+	buf := make([]byte, 4)
+	for _, chunk := range []string{"ping", "pong"} {
+		if chunk != "ping" {
+			bodyWriter.Write([]byte(chunk))
+		}
+		if _, err := io.ReadFull(resp.Body, buf); err != nil {
+			t.Fatalf("Failed to read echoed chunk: %v", err)
+		}
+		if string(buf) != chunk {
+			t.Errorf("Expected %q, got %q", chunk, buf)
+		}
+	}
+	bodyWriter.Close()
+
+	io.Copy(io.Discard, resp.Body)
+	if got := resp.Trailer.Get("X-Echo-Status"); got != "done" {
+		t.Errorf("Expected trailer X-Echo-Status=done, got %q", got)
+	}
+}
+
+func TestServiceRouter_HTTP2UnaryToHTTP1Provider(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	// The provider only speaks HTTP/1.1
+	protos := make(chan string, 1)
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			protos <- r.Proto
+			w.Write([]byte("ok"))
+		}))
+
+	h2c := new(http.Protocols)
+	h2c.SetHTTP1(true)
+	h2c.SetUnencryptedHTTP2(true)
+	host := httptest.NewUnstartedServer(router)
+	host.Config.Protocols = h2c
+	host.Start()
+	t.Cleanup(host.Close)
+
+	clientProtos := new(http.Protocols)
+	clientProtos.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: clientProtos}}
+
+	req, _ := http.NewRequest("POST", host.URL+"/services/kv/"+providerID+"/Get", strings.NewReader("{}"))
+	req.Header = newTestCaller(t, handshake)("POST", "/", nil).Header
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Expected HTTP/2 caller, got %s", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Fatalf("Expected unary call to reach the HTTP/1.1 provider, got %d: %s", resp.StatusCode, body)
+	}
+	if proto := <-protos; proto != "HTTP/1.1" {
+		t.Errorf("Expected HTTP/1.1 to provider, got %s", proto)
+	}
+}
+
+func TestServiceRouter_HonorsCallerDeadline(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	hostURL, providerID, header := newStreamingTestRouter(t, "slow", slow)
+
+	req, _ := http.NewRequest("POST", hostURL+"/services/slow/"+providerID+"/Call", nil)
+	req.Header = header
+	req.Header.Set("Connect-Timeout-Ms", "100")
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected call to end at caller deadline, took %s", elapsed)
+	}
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected 502 after deadline, got %d", resp.StatusCode)
+	}
+}
+
+// registerTestProvider registers a healthy provider backed by handler.
+func registerTestProvider(t *testing.T, handshake *HandshakeServer, registry *ServiceRegistry, lifecycle *LifecycleServer, router *ServiceRouter, serviceType string, handler http.Handler) string {
+	t.Helper()
+
+	providerID, _ := generateRuntimeID(serviceType)
+	token, _ := generateToken()
+	registerTestToken(handshake, providerID, token)
+
+	regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+		ServiceType:  serviceType,
+		Version:      "1.0.0",
+		EndpointPath: "/" + serviceType + ".v1.Service/",
+	})
+	if _, err := registry.RegisterService(runtimeContext(providerID), regReq); err != nil {
+		t.Fatalf("RegisterService failed: %v", err)
+	}
+
+	healthReq := connect.NewRequest(&connectpluginv1.ReportHealthRequest{
+		State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY,
+	})
+	lifecycle.ReportHealth(runtimeContext(providerID), healthReq)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	router.RegisterPluginEndpoint(providerID, server.URL)
+	return providerID
+}
+
+func TestServiceRouter_FailoverReplaysIdempotentCall(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	router.SetFailover(&FailoverConfig{
+		RetryPolicy: RetryPolicy{InitialBackoff: time.Millisecond},
+		IsIdempotent: func(serviceType, method string, req *http.Request) bool {
+			return method == "/Get"
+		},
+	})
+
+	failingID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}))
+	registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+		}))
+
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+
+	testBody := `{"key": "a"}`
+	req := httptest.NewRequest("POST", "/services/kv/"+failingID+"/Get", strings.NewReader(testBody))
+	req.Header.Set("X-Plugin-Runtime-ID", callerID)
+	req.Header.Set("Authorization", "Bearer "+callerToken)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from failover provider, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != testBody {
+		t.Errorf("Expected replayed body %q, got %q", testBody, w.Body.String())
+	}
+}
+
+func TestServiceRouter_FailoverSkipsNonIdempotentCall(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	router.SetFailover(&FailoverConfig{RetryPolicy: RetryPolicy{InitialBackoff: time.Millisecond}})
+
+	failingID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}))
+	var otherCalls int
+	registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			otherCalls++
+		}))
+
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+
+	// POST is not idempotent by default, so the provider's 503 is returned as-is
+	req := httptest.NewRequest("POST", "/services/kv/"+failingID+"/Put", strings.NewReader(`{}`))
+	req.Header.Set("X-Plugin-Runtime-ID", callerID)
+	req.Header.Set("Authorization", "Bearer "+callerToken)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", w.Code)
+	}
+	if otherCalls != 0 {
+		t.Errorf("Expected no retry of non-idempotent call, got %d", otherCalls)
+	}
+}
+
+func TestServiceRouter_FailoverCircuitBreaker(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	router.SetFailover(&FailoverConfig{
+		RetryPolicy:    RetryPolicy{InitialBackoff: time.Millisecond},
+		CircuitBreaker: CircuitBreakerConfig{FailureThreshold: 1, Timeout: time.Minute},
+	})
+
+	var deadCalls int
+	deadID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadCalls++
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}))
+	registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/services/kv/"+deadID+"/Get", nil)
+		req.Header.Set("X-Plugin-Runtime-ID", callerID)
+		req.Header.Set("Authorization", "Bearer "+callerToken)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Fatalf("Request %d: expected failover response, got %d: %s", i, w.Code, w.Body.String())
+		}
+	}
+
+	// The circuit opened after the first failure, so later calls skip the provider
+	if deadCalls != 1 {
+		t.Errorf("Expected failing provider to be called once, got %d", deadCalls)
+	}
+}
+
+func TestServiceRouter_AnyProvider(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	registry.SetSelectionStrategy("kv", SelectionRoundRobin)
+
+	for _, name := range []string{"a", "b"} {
+		name := name
+		registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(name))
+			}))
+	}
+
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("POST", "/services/kv/"+AnyProvider+"/Get", nil)
+		req.Header.Set("X-Plugin-Runtime-ID", callerID)
+		req.Header.Set("Authorization", "Bearer "+callerToken)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		seen[w.Body.String()]++
+	}
+
+	// Round-robin spreads calls across both providers
+	if seen["a"] != 2 || seen["b"] != 2 {
+		t.Errorf("Expected calls split across providers, got %v", seen)
+	}
+}
+
+func TestServiceRouter_AnyProviderMinVersion(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		version := version
+		providerID, _ := generateRuntimeID("kv")
+		regReq := connect.NewRequest(&connectpluginv1.RegisterServiceRequest{
+			ServiceType:  "kv",
+			Version:      version,
+			EndpointPath: "/kv.v1.KV/",
+		})
+		registry.RegisterService(runtimeContext(providerID), regReq)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(version))
+		}))
+		defer server.Close()
+		router.RegisterPluginEndpoint(providerID, server.URL)
+	}
+
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+
+	tests := []struct {
+		minVersion string
+		wantCode   int
+		wantBody   string
+	}{
+		{"2.0.0", http.StatusOK, "2.0.0"},
+		{"3.0.0", http.StatusServiceUnavailable, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.minVersion, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/services/kv/"+AnyProvider+"/Get", nil)
+			req.Header.Set("X-Plugin-Runtime-ID", callerID)
+			req.Header.Set("Authorization", "Bearer "+callerToken)
+			req.Header.Set(MinVersionHeader, tt.minVersion)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("Expected provider %s, got %s", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServiceRouter_TrafficShadow(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	primaryID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("v1"))
+		}))
+	shadowBodies := make(chan string, 1)
+	shadowID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			shadowBodies <- string(body)
+			w.Write([]byte("v2"))
+		}))
+
+	results := make(chan ShadowResult, 1)
+	if err := registry.SetTrafficPolicy("kv", &TrafficPolicy{
+		Shadow: &TrafficShadow{
+			RuntimeID: shadowID,
+			Percent:   100,
+			OnResult:  func(result ShadowResult) { results <- result },
+			IsIdempotent: func(serviceType, method string, req *http.Request) bool {
+				return method == "/Get"
+			},
+		},
+	}); err != nil {
+		t.Fatalf("SetTrafficPolicy failed: %v", err)
+	}
+
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+
+	req := httptest.NewRequest("POST", "/services/kv/"+primaryID+"/Get", strings.NewReader(`{"key": "a"}`))
+	req.Header.Set("X-Plugin-Runtime-ID", callerID)
+	req.Header.Set("Authorization", "Bearer "+callerToken)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// The caller only sees the primary response
+	if w.Code != http.StatusOK || w.Body.String() != "v1" {
+		t.Fatalf("Expected primary response, got %d: %s", w.Code, w.Body.String())
+	}
+
+	select {
+	case body := <-shadowBodies:
+		if body != `{"key": "a"}` {
+			t.Errorf("Expected mirrored body, got %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shadow provider was not called")
+	}
+
+	select {
+	case result := <-results:
+		if result.PrimaryID != primaryID || result.ShadowID != shadowID {
+			t.Errorf("Unexpected providers in result: %+v", result)
+		}
+		if result.PrimaryStatus != http.StatusOK || result.ShadowStatus != http.StatusOK {
+			t.Errorf("Expected both statuses 200, got %+v", result)
+		}
+		if result.BodiesMatch || result.Matches() {
+			t.Error("Expected differing bodies to be reported")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected shadow result")
+	}
+
+	// Non-idempotent calls are not mirrored
+	req = httptest.NewRequest("POST", "/services/kv/"+primaryID+"/Put", strings.NewReader(`{"key": "a"}`))
+	req.Header.Set("X-Plugin-Runtime-ID", callerID)
+	req.Header.Set("Authorization", "Bearer "+callerToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected primary response, got %d: %s", w.Code, w.Body.String())
+	}
+	select {
+	case body := <-shadowBodies:
+		t.Errorf("Expected Put not to be mirrored, shadow got %q", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// newTestCaller registers a caller token and returns a request builder for it.
+func newTestCaller(t *testing.T, handshake *HandshakeServer) func(method, path string, body io.Reader) *http.Request {
+	t.Helper()
+
+	callerID, _ := generateRuntimeID("caller")
+	callerToken, _ := generateToken()
+	registerTestToken(handshake, callerID, callerToken)
+
+	return func(method, path string, body io.Reader) *http.Request {
+		req := httptest.NewRequest(method, path, body)
+		req.Header.Set("X-Plugin-Runtime-ID", callerID)
+		req.Header.Set("Authorization", "Bearer "+callerToken)
+		return req
+	}
+}
+
+func TestServiceRouter_ConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		queueTimeout time.Duration
+		wantCode     int
+	}{
+		{"fast rejection", 0, http.StatusServiceUnavailable},
+		{"queued", 5 * time.Second, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handshake := NewHandshakeServer(&ServeConfig{})
+			lifecycle := NewLifecycleServer()
+			registry := NewServiceRegistry(lifecycle)
+			router := NewServiceRouterWithConfig(handshake, registry, lifecycle, RouterConfig{
+				MaxConcurrentPerProvider: 1,
+				QueueTimeout:             tt.queueTimeout,
+			})
+
+			started := make(chan struct{}, 2)
+			unblock := make(chan struct{})
+			providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					started <- struct{}{}
+					<-unblock
+				}))
+			newRequest := newTestCaller(t, handshake)
+
+			// First call occupies the only slot
+			first := make(chan int, 1)
+			go func() {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, newRequest("POST", "/services/kv/"+providerID+"/Get", nil))
+				first <- w.Code
+			}()
+			<-started
+
+			second := make(chan int, 1)
+			go func() {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, newRequest("POST", "/services/kv/"+providerID+"/Get", nil))
+				second <- w.Code
+			}()
+
+			if tt.queueTimeout == 0 {
+				if code := <-second; code != tt.wantCode {
+					t.Errorf("Expected %d for call over the limit, got %d", tt.wantCode, code)
+				}
+				close(unblock)
+			} else {
+				// Let the second call queue, then free the slot
+				time.Sleep(50 * time.Millisecond)
+				close(unblock)
+				if code := <-second; code != tt.wantCode {
+					t.Errorf("Expected %d for queued call, got %d", tt.wantCode, code)
+				}
+			}
+
+			if code := <-first; code != http.StatusOK {
+				t.Errorf("Expected first call to succeed, got %d", code)
+			}
+		})
+	}
+}
+
+func TestServiceRouter_BodySizeLimits(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouterWithConfig(handshake, registry, lifecycle, RouterConfig{
+		MaxRequestBodySize:  16,
+		MaxResponseBodySize: 8,
+	})
+
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "echo",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+		}))
+	newRequest := newTestCaller(t, handshake)
+	path := "/services/echo/" + providerID + "/Echo"
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"within limits", "small", http.StatusOK},
+		{"request too large", strings.Repeat("x", 17), http.StatusRequestEntityTooLarge},
+		{"response too large", strings.Repeat("x", 12), http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newRequest("POST", path, strings.NewReader(tt.body)))
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServiceRouter_SanitizesHeaders(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouterWithConfig(handshake, registry, lifecycle, RouterConfig{
+		StripHeaders: []string{"X-Internal-Debug"},
+	})
+
+	received := make(chan http.Header, 1)
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- r.Header.Clone()
+		}))
+	newRequest := newTestCaller(t, handshake)
+
+	req := newRequest("POST", "/services/kv/"+providerID+"/Get", nil)
+	req.RemoteAddr = "192.0.2.10:5555"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	req.Header.Set("X-Internal-Debug", "1")
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "secret")
+	req.Header.Set("X-Custom", "kept")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	header := <-received
+	if got := header.Get("X-Forwarded-For"); got != "192.0.2.10" {
+		t.Errorf("Expected spoofed X-Forwarded-For replaced, got %q", got)
+	}
+	if header.Get("X-Forwarded-Proto") != "http" {
+		t.Errorf("Expected X-Forwarded-Proto http, got %q", header.Get("X-Forwarded-Proto"))
+	}
+	for _, name := range []string{"X-Internal-Debug", "X-Hop", "Authorization"} {
+		if header.Get(name) != "" {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+	if header.Get("X-Custom") != "kept" {
+		t.Error("Expected other headers to be forwarded")
+	}
+}
+
+func TestServiceRouter_FaultConnectCodeScopedToCaller(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+	faultyCaller := newTestCaller(t, handshake)
+	otherCaller := newTestCaller(t, handshake)
+
+	probe := faultyCaller("POST", "/", nil)
+	if err := router.SetFault("kv-unavailable", FaultRule{
+		ServiceType: "kv",
+		Method:      "/Get",
+		CallerID:    probe.Header.Get("X-Plugin-Runtime-ID"),
+		ConnectCode: connect.CodeUnavailable,
+	}); err != nil {
+		t.Fatalf("SetFault failed: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, faultyCaller("POST", "/services/kv/"+providerID+"/Get", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from injected fault, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"code":"unavailable"`) {
+		t.Errorf("Expected Connect error body, got %q", w.Body.String())
+	}
+
+	// Other callers and procedures are unaffected
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, otherCaller("POST", "/services/kv/"+providerID+"/Get", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for other caller, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, faultyCaller("POST", "/services/kv/"+providerID+"/Put", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for other method, got %d", w.Code)
+	}
+
+	// Clearing the fault restores normal routing
+	router.ClearFault("kv-unavailable")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, faultyCaller("POST", "/services/kv/"+providerID+"/Get", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 after clearing fault, got %d", w.Code)
+	}
+}
+
+func TestServiceRouter_FaultDelay(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	newRequest := newTestCaller(t, handshake)
+
+	router.SetFault("slow", FaultRule{ProviderID: providerID, Delay: 50 * time.Millisecond})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newRequest("POST", "/services/kv/"+providerID+"/Get", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 after delay, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected call delayed by at least 50ms, took %s", elapsed)
+	}
+}
+
+func TestServiceRouter_FaultAbortAndTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FaultRule
+	}{
+		{"abort", FaultRule{Abort: true}},
+		{"truncate", FaultRule{TruncateAfter: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handshake := NewHandshakeServer(&ServeConfig{})
+			lifecycle := NewLifecycleServer()
+			registry := NewServiceRegistry(lifecycle)
+			router := NewServiceRouter(handshake, registry, lifecycle)
+
+			providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(strings.Repeat("x", 64)))
+				}))
+			newRequest := newTestCaller(t, handshake)
+			router.SetFault(tt.name, tt.rule)
+
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			req := newRequest("POST", server.URL+"/services/kv/"+providerID+"/Get", nil)
+			req.RequestURI = ""
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				_, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			if err == nil {
+				t.Fatal("Expected the connection to be cut")
+			}
+		})
+	}
+}
+
+func TestServiceRouter_FaultAdminHandler(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	admin := router.FaultAdminHandler()
+
+	newAdminRequest := newTestCaller(t, handshake)
+	registry.SetAdminIdentities(newAdminRequest("GET", "/", nil).Header.Get("X-Plugin-Runtime-ID"))
+	newOtherRequest := newTestCaller(t, handshake)
+
+	// Non-admin callers are rejected
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, newOtherRequest("GET", "/faults", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-admin, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, newAdminRequest("PUT", "/faults/slow-kv",
+		strings.NewReader(`{"service_type": "kv", "percent": 10, "delay": "250ms", "connect_code": "unavailable"}`)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 from PUT, got %d: %s", w.Code, w.Body.String())
+	}
+
+	rule, ok := router.Faults()["slow-kv"]
+	if !ok {
+		t.Fatal("Expected fault to be set")
+	}
+	if rule.ServiceType != "kv" || rule.Percent != 10 || rule.Delay != 250*time.Millisecond || rule.ConnectCode != connect.CodeUnavailable {
+		t.Errorf("Unexpected rule: %+v", rule)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, newAdminRequest("GET", "/faults", nil))
+	if !strings.Contains(w.Body.String(), `"delay":"250ms"`) {
+		t.Errorf("Expected listed fault with delay, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, newAdminRequest("PUT", "/faults/bad", strings.NewReader(`{"percent": 150}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid rule, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, newAdminRequest("DELETE", "/faults/slow-kv", nil))
+	if w.Code != http.StatusNoContent || len(router.Faults()) != 0 {
+		t.Errorf("Expected fault removed, got %d with %d faults", w.Code, len(router.Faults()))
+	}
+}
+
+func TestServiceRouter_ResponseCache(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	router.SetCache(&CacheConfig{ServiceTTLs: map[string]time.Duration{"kv": time.Minute}})
+
+	var calls int
+	var mu sync.Mutex
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			if r.URL.Query().Get("message") == "nostore" {
+				w.Header().Set("Cache-Control", "no-store")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"value":"` + r.URL.Query().Get("message") + `"}`))
+		}))
+	newRequest := newTestCaller(t, handshake)
+
+	call := func(method, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newRequest(method, "/services/kv/"+providerID+"/Get?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w
+	}
+	callCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	call("GET", "message=a")
+	w := call("GET", "message=a")
+	if callCount() != 1 {
+		t.Errorf("Expected second GET served from cache, provider called %d times", callCount())
+	}
+	if w.Body.String() != `{"value":"a"}` || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected cached response: %q %v", w.Body.String(), w.Header())
+	}
+
+	// Different request bytes miss
+	call("GET", "message=b")
+	if callCount() != 2 {
+		t.Errorf("Expected different request to miss, provider called %d times", callCount())
+	}
+
+	// Responses are not shared across callers or caller origins
+	otherCaller := newTestCaller(t, handshake)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, otherCaller("GET", "/services/kv/"+providerID+"/Get?message=a", nil))
+	if w.Code != http.StatusOK || callCount() != 3 {
+		t.Errorf("Expected another caller to miss, got %d with %d calls", w.Code, callCount())
+	}
+	w = httptest.NewRecorder()
+	req := newRequest("GET", "/services/kv/"+providerID+"/Get?message=a", nil)
+	req.Header.Set(OriginHeader, encodeOrigin(&AuthContext{Identity: "alice"}))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || callCount() != 4 {
+		t.Errorf("Expected another origin to miss, got %d with %d calls", w.Code, callCount())
+	}
+
+	// no-store responses and POST calls are not cached
+	call("GET", "message=nostore")
+	call("GET", "message=nostore")
+	call("POST", "message=a")
+	if callCount() != 7 {
+		t.Errorf("Expected uncacheable calls to reach provider, got %d calls", callCount())
+	}
+
+	// A change in the kv provider set invalidates cached responses
+	registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	call("GET", "message=a")
+	if callCount() != 8 {
+		t.Errorf("Expected cache invalidated by provider change, got %d calls", callCount())
+	}
+}
+
+func TestServiceRouter_ResponseCacheFailover(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	router.SetCache(&CacheConfig{ServiceTTLs: map[string]time.Duration{"kv": time.Minute}})
+	router.SetFailover(&FailoverConfig{
+		RetryPolicy: RetryPolicy{InitialBackoff: time.Millisecond},
+	})
+
+	var failed int
+	var mu sync.Mutex
+	failingID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			failed++
+			mu.Unlock()
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}))
+	registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"value":"a"}`))
+		}))
+	newRequest := newTestCaller(t, handshake)
+
+	// A response from the failover provider is not cached under the
+	// failing provider's key
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newRequest("GET", "/services/kv/"+failingID+"/Get", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200 from failover provider, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if failed != 2 {
+		t.Errorf("Expected failed-over response not cached, failing provider called %d times", failed)
+	}
+
+	// A body that cannot be read is rejected rather than proxied
+	req := newRequest("GET", "/services/kv/"+failingID+"/Get", iotest.ErrReader(io.ErrUnexpectedEOF))
+	req.ContentLength = 16
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unreadable body, got %d", w.Code)
+	}
+}
+
+func TestResponseCache_TTLAndEviction(t *testing.T) {
+	cache := newResponseCache(CacheConfig{MaxEntries: 2, DefaultTTL: time.Second}.withDefaults())
+
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{"", time.Second},
+		{"max-age=30", 30 * time.Second},
+		{"max-age=30, s-maxage=10", 10 * time.Second},
+		{"private, max-age=30", 0},
+		{"no-cache", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.cacheControl != "" {
+			header.Set("Cache-Control", tt.cacheControl)
+		}
+		if got := cache.ttl("kv", header); got != tt.want {
+			t.Errorf("ttl(%q) = %s, want %s", tt.cacheControl, got, tt.want)
+		}
+	}
+
+	entry := func(b byte) *cacheEntry {
+		return &cacheEntry{key: [32]byte{b}, expiresAt: time.Now().Add(time.Minute), body: []byte{b}}
+	}
+	cache.put(entry(1))
+	cache.put(entry(2))
+	cache.get([32]byte{1}, 0) // 1 is now most recently used
+	cache.put(entry(3))
+
+	if cache.get([32]byte{2}, 0) != nil {
+		t.Error("Expected least recently used entry evicted")
+	}
+	if cache.get([32]byte{1}, 0) == nil || cache.get([32]byte{3}, 0) == nil {
+		t.Error("Expected recent entries kept")
+	}
+	if cache.get([32]byte{1}, 1) != nil {
+		t.Error("Expected entry from an older revision dropped")
+	}
+}
+
+func TestServiceRouter_DrainProvider(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	started := make(chan struct{}, 2)
+	unblock := make(chan struct{})
+	oldID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-unblock
+			w.Write([]byte("old"))
+		}))
+	registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("new"))
+		}))
+	newRequest := newTestCaller(t, handshake)
+
+	// A call to the old provider is in flight
+	first := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newRequest("POST", "/services/kv/"+oldID+"/Get", nil))
+		first <- w
+	}()
+	<-started
+	if n := router.InFlight(oldID); n != 1 {
+		t.Fatalf("Expected 1 call in flight, got %d", n)
+	}
+
+	drained := make(chan DrainResult, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		drained <- router.DrainProvider(ctx, oldID)
+	}()
+	for !router.IsDraining(oldID) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// New calls go elsewhere or are rejected
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newRequest("POST", "/services/kv/"+AnyProvider+"/Get", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "new" {
+			t.Fatalf("Expected call routed to the other provider, got %d %q", w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newRequest("POST", "/services/kv/"+oldID+"/Get", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for draining provider, got %d", w.Code)
+	}
+
+	select {
+	case <-drained:
+		t.Fatal("Expected drain to wait for the in-flight call")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Draining completes as soon as the call finishes
+	close(unblock)
+	if w := <-first; w.Code != http.StatusOK || w.Body.String() != "old" {
+		t.Errorf("Expected in-flight call to complete, got %d %q", w.Code, w.Body.String())
+	}
+	select {
+	case result := <-drained:
+		if !result.Drained || result.InFlight != 0 {
+			t.Errorf("Expected drained, got %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected drain to complete")
+	}
+}
+
+func TestServiceRouter_DrainProviderTimeout(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+
+	started := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+	defer close(unblock)
+	providerID := registerTestProvider(t, handshake, registry, lifecycle, router, "kv",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-unblock
+		}))
+	newRequest := newTestCaller(t, handshake)
+
+	go router.ServeHTTP(httptest.NewRecorder(), newRequest("POST", "/services/kv/"+providerID+"/Get", nil))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result := router.DrainProvider(ctx, providerID)
+	if result.Drained || result.InFlight != 1 {
+		t.Errorf("Expected drain to time out with 1 call in flight, got %+v", result)
+	}
+
+	// Resumed providers are routed again
+	router.ResumeProvider(providerID)
+	if router.IsDraining(providerID) {
+		t.Error("Expected provider resumed")
+	}
+}
@@ -0,0 +1,464 @@
+package connectplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"connectrpc.com/connect"
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+	"github.com/masegraye/connect-plugin-go/gen/plugin/v1/connectpluginv1connect"
+)
+
+// PluginServeConfig configures ServePlugin.
+type PluginServeConfig struct {
+	// Client connects the plugin to the host.
+	// If nil, a client is created from ClientConfig.
+	Client *Client
+
+	// ClientConfig creates the client when Client is nil. SelfID, SelfVersion
+	// and Metadata describe the plugin to the host.
+	// HostURL default: $HOST_URL, or "http://localhost:8080"
+	ClientConfig ClientConfig
+
+	// Handlers maps path prefixes to the plugin's service handlers, as
+	// returned by generated NewXxxHandler functions.
+	Handlers map[string]http.Handler
+
+	// Unmanaged makes the plugin handshake with the host at startup and
+	// register itself. Otherwise the plugin waits for the host to assign
+	// its identity through PluginIdentity.SetRuntimeIdentity (managed).
+	// Default: true if HOST_URL is set
+	Unmanaged *bool
+
+	// Addr is the address to listen on.
+	// Default: ":" + $PORT, or ":8080"
+	Addr string
+
+	// Listener is used instead of listening on Addr if set.
+	Listener net.Listener
+
+	// BaseURL is where the host reaches the plugin. It is registered with
+	// the plugin's services as "base_url" so the ServiceRouter can route
+	// to self-registered plugins.
+	// Default: "http://" + $HOSTNAME + ":" + port ("localhost" if HOSTNAME is unset)
+	BaseURL string
+
+	// HostSecret must be presented by the host on SetRuntimeIdentity and on
+	// the PluginControl calls that change the plugin (Shutdown, Drain, Pause,
+	// Resume, Reconfigure).
+	// Default: HostSecretFromEnv(); without one, these calls are rejected
+	// unless InsecureSkipHostAuth is set
+	HostSecret string
+
+	// InsecureSkipHostAuth accepts host-only calls without authentication
+	// when no host secret is provisioned. Anyone who can reach the plugin
+	// can then assign its identity or shut it down; only use it for local
+	// development.
+	// Default: false
+	InsecureSkipHostAuth bool
+
+	// Health reports the plugin's health. It answers PluginControl.GetHealth
+	// and is reported to the host once services are registered.
+	// Default: HEALTHY
+	Health func(ctx context.Context) *connectpluginv1.GetHealthResponse
+
+	// HealthInterval re-reports health on this interval, e.g. to satisfy a
+	// host HeartbeatConfig.
+	// Default: 0 (report once)
+	HealthInterval time.Duration
+
+	// Reconfigure applies configuration sent with PluginControl.Reconfigure.
+	// Default: nil (unsupported)
+	Reconfigure func(ctx context.Context, config []byte, contentType string) error
+
+	// OnReady is called once the plugin has its runtime identity and has
+	// registered its services, e.g. to start watching dependencies.
+	// Its context is canceled on shutdown.
+	OnReady func(ctx context.Context, client *Client)
+
+	// GracefulShutdownTimeout bounds shutdown on signal or StopCh, and on
+	// Shutdown requests without a grace period.
+	// Default: 30 seconds
+	GracefulShutdownTimeout time.Duration
+
+	// StopCh signals shutdown.
+	// If nil, the plugin runs until SIGTERM/SIGINT or a Shutdown request.
+	StopCh <-chan struct{}
+}
+
+// ServePlugin runs a Phase 2 plugin: it serves the plugin's handlers along
+// with PluginIdentity and PluginControl, registers the plugin's services and
+// reports its health once it has a runtime identity, and shuts down
+// gracefully on signal, StopCh or a host Shutdown request.
+//
+// This function blocks until the plugin is shut down.
+func ServePlugin(cfg *PluginServeConfig) error {
+	// Apply defaults
+	if cfg.Addr == "" {
+		cfg.Addr = ":8080"
+		if port := os.Getenv("PORT"); port != "" {
+			cfg.Addr = ":" + port
+		}
+	}
+	if cfg.GracefulShutdownTimeout == 0 {
+		cfg.GracefulShutdownTimeout = 30 * time.Second
+	}
+	if cfg.Unmanaged == nil {
+		unmanaged := os.Getenv("HOST_URL") != ""
+		cfg.Unmanaged = &unmanaged
+	}
+
+	client := cfg.Client
+	if client == nil {
+		clientConfig := cfg.ClientConfig
+		if clientConfig.HostURL == "" && clientConfig.Endpoint == "" && clientConfig.Discovery == nil {
+			clientConfig.HostURL = os.Getenv("HOST_URL")
+			if clientConfig.HostURL == "" {
+				clientConfig.HostURL = "http://localhost:8080"
+			}
+		}
+
+		var err error
+		if client, err = NewClient(clientConfig); err != nil {
+			return err
+		}
+	}
+
+	callerKey, err := CallerKeyFromEnv()
+	if err != nil {
+		return err
+	}
+
+	if cfg.HostSecret == "" {
+		if cfg.HostSecret, err = HostSecretFromEnv(); err != nil {
+			return err
+		}
+	}
+	var hostAuth []connect.HandlerOption
+	switch {
+	case cfg.HostSecret != "":
+		hostAuth = append(hostAuth, connect.WithInterceptors(NewHostAuthInterceptor(cfg.HostSecret)))
+	case cfg.InsecureSkipHostAuth:
+		log.Printf("[PLUGIN] No host secret provisioned: SetRuntimeIdentity and control calls are not authenticated")
+	default:
+		hostAuth = append(hostAuth, connect.WithInterceptors(NewHostAuthInterceptor("")))
+		log.Printf("[PLUGIN] No host secret provisioned: SetRuntimeIdentity and control calls are rejected")
+	}
+
+	ln := cfg.Listener
+	if ln == nil {
+		if ln, err = net.Listen("tcp", cfg.Addr); err != nil {
+			return fmt.Errorf("listen on %s: %w", cfg.Addr, err)
+		}
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL(ln.Addr())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runtime := &pluginRuntime{
+		cfg:        cfg,
+		client:     client,
+		ctx:        ctx,
+		shutdownCh: make(chan time.Duration, 1),
+	}
+	runtime.control = NewPluginControlServer(ControlConfig{
+		Health:      cfg.Health,
+		Reconfigure: cfg.Reconfigure,
+		Shutdown:    runtime.requestShutdown,
+	})
+
+	// Build the HTTP mux
+	mux := http.NewServeMux()
+	mux.Handle(runtime.control.Handler(hostAuth...))
+	mux.Handle(connectpluginv1connect.NewPluginIdentityHandler(&pluginIdentityServer{runtime: runtime}, hostAuth...))
+
+	for path, handler := range cfg.Handlers {
+		if callerKey != nil {
+			audience := CallerAudience{
+				RuntimeID:    client.RuntimeID,
+				ServiceTypes: handlerServiceTypes(client.Config().Metadata, path),
+			}
+			handler = CallerContextMiddleware(callerKey, audience, handler)
+		}
+		mux.Handle(path, runtime.control.Middleware(handler))
+	}
+
+	// Unencrypted HTTP/2 (h2c) so streaming calls routed by the host work
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Handler:   mux,
+		Protocols: protocols,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+	log.Printf("[PLUGIN] %s listening on %s", client.Config().SelfID, ln.Addr())
+
+	// Unmanaged: handshake and register now, once the host can reach us
+	if *cfg.Unmanaged {
+		if err := client.Connect(ctx); err != nil {
+			srv.Close()
+			return fmt.Errorf("failed to connect to host: %w", err)
+		}
+		runtime.start()
+	}
+
+	// Set up shutdown handling
+	stopCh := cfg.StopCh
+	if stopCh == nil {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigCh)
+
+		shutdownCh := make(chan struct{})
+		go func() {
+			select {
+			case <-sigCh:
+				close(shutdownCh)
+			case <-ctx.Done():
+			}
+		}()
+		stopCh = shutdownCh
+	}
+
+	grace := cfg.GracefulShutdownTimeout
+	select {
+	case err := <-errCh:
+		return err
+	case <-stopCh:
+	case requested := <-runtime.shutdownCh:
+		if requested > 0 {
+			grace = requested
+		}
+	}
+
+	// Stop health reporting and background work, then drain connections
+	cancel()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), grace)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown: %w", err)
+	}
+	return nil
+}
+
+// handlerServiceTypes returns the service types declared for a handler
+// path, or every provided service type if no declaration matches the path.
+func handlerServiceTypes(metadata PluginMetadata, path string) []string {
+	normalize := func(p string) string { return "/" + strings.Trim(p, "/") + "/" }
+
+	var matched, all []string
+	for _, svc := range metadata.Provides {
+		all = append(all, svc.Type)
+		if normalize(svc.Path) == normalize(path) {
+			matched = append(matched, svc.Type)
+		}
+	}
+	if len(matched) > 0 {
+		return matched
+	}
+	return all
+}
+
+// defaultBaseURL derives the plugin's base URL from its listen address.
+func defaultBaseURL(addr net.Addr) string {
+	host := os.Getenv("HOSTNAME")
+	if host == "" {
+		host = "localhost"
+	}
+	port := ""
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		port = fmt.Sprint(tcpAddr.Port)
+	} else if _, p, err := net.SplitHostPort(addr.String()); err == nil {
+		port = p
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// pluginRuntime is the state of a plugin run by ServePlugin.
+type pluginRuntime struct {
+	cfg     *PluginServeConfig
+	client  *Client
+	control *PluginControlServer
+
+	// ctx is canceled on shutdown
+	ctx context.Context
+
+	// shutdownCh receives the grace period of a host Shutdown request
+	shutdownCh chan time.Duration
+
+	mu         sync.Mutex
+	stopReport context.CancelFunc // stops the current registration and health loop
+}
+
+// requestShutdown is the PluginControlServer Shutdown callback.
+func (p *pluginRuntime) requestShutdown(gracePeriod time.Duration, reason string) {
+	log.Printf("[PLUGIN] Shutdown requested (grace: %s, reason: %s)", gracePeriod, reason)
+	select {
+	case p.shutdownCh <- gracePeriod:
+	default:
+		// Shutdown already requested
+	}
+}
+
+// start registers the plugin's services and reports its health in the
+// background. A new runtime identity restarts it.
+func (p *pluginRuntime) start() {
+	ctx, cancel := context.WithCancel(p.ctx)
+
+	p.mu.Lock()
+	if p.stopReport != nil {
+		p.stopReport()
+	}
+	p.stopReport = cancel
+	p.mu.Unlock()
+
+	go p.run(ctx)
+}
+
+// run registers services, reports health and calls OnReady, then keeps
+// reporting health on HealthInterval.
+func (p *pluginRuntime) run(ctx context.Context) {
+	if !p.registerServices(ctx) {
+		return
+	}
+	p.reportHealth(ctx)
+
+	if p.cfg.OnReady != nil {
+		p.cfg.OnReady(ctx, p.client)
+	}
+
+	if p.cfg.HealthInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.reportHealth(ctx)
+		}
+	}
+}
+
+// registerServices registers the plugin's services, retrying with backoff
+// until they are all registered. Returns false if ctx was canceled first.
+func (p *pluginRuntime) registerServices(ctx context.Context) bool {
+	metadata := map[string]string{"base_url": p.cfg.BaseURL}
+	policy := DefaultRetryPolicy()
+
+	registered := 0
+	for attempt := 1; ; attempt++ {
+		var err error
+		if registered, err = p.client.registerServicesFrom(ctx, metadata, registered); err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+
+		backoff := policy.calculateBackoff(attempt)
+		log.Printf("[PLUGIN] Failed to register services (attempt %d), retrying in %s: %v", attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// reportHealth reports the plugin's current health to the host.
+func (p *pluginRuntime) reportHealth(ctx context.Context) {
+	health := &connectpluginv1.GetHealthResponse{State: connectpluginv1.HealthState_HEALTH_STATE_HEALTHY}
+	if p.cfg.Health != nil {
+		health = p.cfg.Health(ctx)
+	}
+
+	err := p.client.ReportHealth(ctx, health.State, health.Reason, health.UnavailableDependencies)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("[PLUGIN] Failed to report health: %v", err)
+	}
+}
+
+// pluginIdentityServer implements PluginIdentity for ServePlugin (managed mode).
+type pluginIdentityServer struct {
+	runtime *pluginRuntime
+}
+
+// GetPluginInfo returns the plugin's metadata from its ClientConfig.
+func (s *pluginIdentityServer) GetPluginInfo(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.GetPluginInfoRequest],
+) (*connect.Response[connectpluginv1.GetPluginInfoResponse], error) {
+	cfg := s.runtime.client.Config()
+
+	provides := make([]*connectpluginv1.ServiceDeclaration, len(cfg.Metadata.Provides))
+	for i, svc := range cfg.Metadata.Provides {
+		provides[i] = &connectpluginv1.ServiceDeclaration{
+			Type:    svc.Type,
+			Version: svc.Version,
+			Path:    svc.Path,
+		}
+	}
+
+	requires := make([]*connectpluginv1.ServiceDependency, len(cfg.Metadata.Requires))
+	for i, dep := range cfg.Metadata.Requires {
+		requires[i] = &connectpluginv1.ServiceDependency{
+			Type:               dep.Type,
+			MinVersion:         dep.MinVersion,
+			RequiredForStartup: dep.RequiredForStartup,
+			WatchForChanges:    dep.WatchForChanges,
+		}
+	}
+
+	return connect.NewResponse(&connectpluginv1.GetPluginInfoResponse{
+		SelfId:      cfg.SelfID,
+		SelfVersion: cfg.SelfVersion,
+		Provides:    provides,
+		Requires:    requires,
+		Metadata: map[string]string{
+			"name":    cfg.Metadata.Name,
+			"version": cfg.Metadata.Version,
+		},
+	}), nil
+}
+
+// SetRuntimeIdentity stores the identity assigned by the host, then
+// registers services and reports health.
+func (s *pluginIdentityServer) SetRuntimeIdentity(
+	ctx context.Context,
+	req *connect.Request[connectpluginv1.SetRuntimeIdentityRequest],
+) (*connect.Response[connectpluginv1.SetRuntimeIdentityResponse], error) {
+	if req.Msg.RuntimeId == "" || req.Msg.RuntimeToken == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("runtime_id and runtime_token are required"))
+	}
+
+	s.runtime.client.SetRuntimeIdentity(req.Msg.RuntimeId, req.Msg.RuntimeToken, req.Msg.HostUrl)
+	log.Printf("[PLUGIN] Assigned runtime identity: %s", req.Msg.RuntimeId)
+	s.runtime.start()
+
+	return connect.NewResponse(&connectpluginv1.SetRuntimeIdentityResponse{
+		Acknowledged: true,
+	}), nil
+}
@@ -0,0 +1,217 @@
+package connectplugin
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
+)
+
+// startServePlugin runs ServePlugin on a local listener. The returned
+// channel receives ServePlugin's result.
+func startServePlugin(t *testing.T, cfg *PluginServeConfig) (string, <-chan error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	cfg.Listener = ln
+	cfg.BaseURL = "http://" + ln.Addr().String()
+	// Tests that don't provision a host secret don't cover host auth
+	if cfg.HostSecret == "" {
+		cfg.InsecureSkipHostAuth = true
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- ServePlugin(cfg) }()
+	return cfg.BaseURL, done
+}
+
+func servePluginMetadata() PluginMetadata {
+	return PluginMetadata{
+		Name:    "logger",
+		Version: "1.0.0",
+		Provides: []ServiceDeclaration{
+			{Type: "logger", Version: "1.0.0", Path: "/logger.v1.Logger/"},
+		},
+	}
+}
+
+func TestServePlugin_Managed(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	router := NewServiceRouter(handshake, registry, lifecycle)
+	platform := NewPlatform(registry, lifecycle, router)
+	host := startRuntimeAuthHost(t, handshake, lifecycle, registry)
+
+	ready := make(chan string, 1)
+	endpoint, done := startServePlugin(t, &PluginServeConfig{
+		ClientConfig: ClientConfig{
+			HostURL:     host.URL,
+			SelfID:      "logger-plugin",
+			SelfVersion: "1.0.0",
+			Metadata:    servePluginMetadata(),
+		},
+		Handlers: map[string]http.Handler{
+			"/logger.v1.Logger/": http.NotFoundHandler(),
+		},
+		OnReady: func(ctx context.Context, client *Client) {
+			ready <- client.RuntimeID()
+		},
+		StopCh: make(chan struct{}),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := platform.AddPlugin(ctx, PluginConfig{Endpoint: endpoint}); err != nil {
+		t.Fatalf("AddPlugin failed: %v", err)
+	}
+
+	runtimeID := <-ready
+	provider, err := registry.GetProviderByRuntimeID(runtimeID)
+	if err != nil {
+		t.Fatalf("Expected services registered: %v", err)
+	}
+	if provider.Metadata["base_url"] != endpoint {
+		t.Errorf("Expected base_url %q, got %q", endpoint, provider.Metadata["base_url"])
+	}
+	if state := lifecycle.GetHealthState(runtimeID); state == nil || state.State != connectpluginv1.HealthState_HEALTH_STATE_HEALTHY {
+		t.Errorf("Expected plugin healthy, got %+v", state)
+	}
+
+	// A host Shutdown request stops the plugin
+	if _, err := NewPluginControlClient(endpoint, nil).Shutdown(ctx, 1, "test"); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ServePlugin returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected ServePlugin to return after Shutdown")
+	}
+}
+
+func TestServePlugin_Unmanaged(t *testing.T) {
+	handshake := NewHandshakeServer(&ServeConfig{})
+	lifecycle := NewLifecycleServer()
+	registry := NewServiceRegistry(lifecycle)
+	NewServiceRouter(handshake, registry, lifecycle)
+
+	// The host fails the first registration; the plugin retries it
+	var failed atomic.Bool
+	runtimeAuth := connect.WithInterceptors(NewRuntimeAuthInterceptor(handshake))
+	mux := http.NewServeMux()
+	mux.Handle(HandshakeServerHandler(handshake))
+	mux.Handle(LifecycleServerHandler(lifecycle, runtimeAuth))
+	registryPath, registryHandler := ServiceRegistryHandler(registry, runtimeAuth)
+	mux.Handle(registryPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/RegisterService") && failed.CompareAndSwap(false, true) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		registryHandler.ServeHTTP(w, r)
+	}))
+	host := httptest.NewServer(mux)
+	t.Cleanup(host.Close)
+
+	unmanaged := true
+	stop := make(chan struct{})
+	ready := make(chan string, 1)
+	endpoint, done := startServePlugin(t, &PluginServeConfig{
+		ClientConfig: ClientConfig{
+			HostURL:     host.URL,
+			SelfID:      "logger-plugin",
+			SelfVersion: "1.0.0",
+			Metadata:    servePluginMetadata(),
+		},
+		Unmanaged: &unmanaged,
+		Handlers: map[string]http.Handler{
+			"/logger.v1.Logger/": http.NotFoundHandler(),
+		},
+		OnReady: func(ctx context.Context, client *Client) {
+			ready <- client.RuntimeID()
+		},
+		StopCh: stop,
+	})
+
+	var runtimeID string
+	select {
+	case runtimeID = <-ready:
+	case err := <-done:
+		t.Fatalf("ServePlugin returned early: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected plugin to register itself")
+	}
+
+	if !failed.Load() {
+		t.Error("Expected the first registration to fail")
+	}
+	if _, err := registry.GetProviderByRuntimeID(runtimeID); err != nil {
+		t.Fatalf("Expected services registered: %v", err)
+	}
+	if !lifecycle.ShouldRouteTraffic(runtimeID) {
+		t.Error("Expected plugin to report healthy")
+	}
+
+	// The plugin serves PluginControl alongside its services
+	health, err := NewPluginControlClient(endpoint, nil).GetHealth(context.Background())
+	if err != nil {
+		t.Fatalf("GetHealth failed: %v", err)
+	}
+	if health.State != connectpluginv1.HealthState_HEALTH_STATE_HEALTHY {
+		t.Errorf("Expected HEALTHY, got %v", health.State)
+	}
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ServePlugin returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected ServePlugin to return after StopCh")
+	}
+}
+
+func TestServePlugin_ExplicitlyManaged(t *testing.T) {
+	// HOST_URL does not override an explicit Unmanaged: false
+	t.Setenv("HOST_URL", "http://127.0.0.1:1")
+
+	managed := false
+	stop := make(chan struct{})
+	endpoint, done := startServePlugin(t, &PluginServeConfig{
+		ClientConfig: ClientConfig{
+			SelfID:      "logger-plugin",
+			SelfVersion: "1.0.0",
+			Metadata:    servePluginMetadata(),
+		},
+		Unmanaged: &managed,
+		StopCh:    stop,
+	})
+
+	// The plugin serves instead of failing to connect to HOST_URL
+	if _, err := NewPluginControlClient(endpoint, nil).GetHealth(context.Background()); err != nil {
+		t.Fatalf("GetHealth failed: %v", err)
+	}
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ServePlugin returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected ServePlugin to return after StopCh")
+	}
+}
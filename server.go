@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"connectrpc.com/connect"
 	connectpluginv1 "github.com/masegraye/connect-plugin-go/gen/plugin/v1"
 )
 
@@ -117,6 +118,12 @@ type ServeConfig struct {
 	// If set, /services/* routes are handled for mediated communication.
 	// Set to nil to disable Phase 2 service routing.
 	ServiceRouter *ServiceRouter
+
+	// StateStore persists registrations, runtime tokens and health so a
+	// restarted host can restore them without plugins re-handshaking.
+	// State is restored on Serve and the store is closed on shutdown.
+	// Set to nil to keep all state in memory.
+	StateStore StateStore
 }
 
 // Validate checks ServeConfig for errors.
@@ -201,6 +208,13 @@ func Serve(cfg *ServeConfig) error {
 	handshakePath, handshakeHandler := HandshakeServerHandler(handshakeServer)
 	mux.Handle(handshakePath, handshakeHandler)
 
+	// Restore persisted state before accepting plugin traffic
+	if cfg.StateStore != nil {
+		if err := RestoreState(cfg.StateStore, handshakeServer, cfg.ServiceRegistry, cfg.LifecycleService); err != nil {
+			return err
+		}
+	}
+
 	// Register health service (if enabled)
 	if cfg.HealthService != nil {
 		// Set overall health to SERVING
@@ -216,15 +230,18 @@ func Serve(cfg *ServeConfig) error {
 		mux.Handle("/readyz", httpHealthHandler)
 	}
 
+	// Phase 2 host RPCs are authenticated with the runtime token issued at handshake
+	runtimeAuth := connect.WithInterceptors(NewRuntimeAuthInterceptor(handshakeServer))
+
 	// Phase 2: Register lifecycle service (if enabled)
 	if cfg.LifecycleService != nil {
-		lifecyclePath, lifecycleHandler := LifecycleServerHandler(cfg.LifecycleService)
+		lifecyclePath, lifecycleHandler := LifecycleServerHandler(cfg.LifecycleService, runtimeAuth)
 		mux.Handle(lifecyclePath, lifecycleHandler)
 	}
 
 	// Phase 2: Register service registry (if enabled)
 	if cfg.ServiceRegistry != nil {
-		registryPath, registryHandler := ServiceRegistryHandler(cfg.ServiceRegistry)
+		registryPath, registryHandler := ServiceRegistryHandler(cfg.ServiceRegistry, runtimeAuth)
 		mux.Handle(registryPath, registryHandler)
 	}
 
@@ -254,9 +271,14 @@ func Serve(cfg *ServeConfig) error {
 	}
 
 	// Create HTTP server
+	// Unencrypted HTTP/2 (h2c) lets client and bidi streams reach the service router
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
 	srv := &http.Server{
-		Addr:    cfg.Addr,
-		Handler: mux,
+		Addr:      cfg.Addr,
+		Handler:   mux,
+		Protocols: protocols,
 	}
 
 	// Set up shutdown handling
@@ -314,5 +336,12 @@ func gracefulShutdown(srv *http.Server, cfg *ServeConfig) error {
 		return fmt.Errorf("server shutdown: %w", err)
 	}
 
+	// Close state store after the server stops mutating state
+	if cfg.StateStore != nil {
+		if err := cfg.StateStore.Close(); err != nil {
+			return fmt.Errorf("state store close: %w", err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,377 @@
+package connectplugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TrafficPolicy splits and mirrors calls to a service type, e.g. to canary a
+// new plugin version. Splits apply wherever the registry selects a provider
+// (DiscoverService, _any routing, failover); calls addressed to a specific
+// provider ID are not split. Shadowing applies to idempotent calls routed
+// by the ServiceRouter. Policies are set per namespace.
+type TrafficPolicy struct {
+	// Splits send a percentage of calls to matching providers.
+	// Remaining traffic goes to providers matching no split.
+	// If no available provider matches the chosen split, any available
+	// provider is used.
+	Splits []TrafficSplit
+
+	// Shadow mirrors a copy of calls to matching providers (optional).
+	Shadow *TrafficShadow
+}
+
+// TrafficSplit routes a percentage of calls to providers matching a version
+// or runtime ID.
+type TrafficSplit struct {
+	// Version matches providers with this exact version.
+	Version string
+
+	// RuntimeID matches a single provider.
+	RuntimeID string
+
+	// Percent of calls (0-100) sent to matching providers.
+	Percent int
+}
+
+// TrafficShadow mirrors calls to providers matching a version or runtime ID.
+// Shadow requests are fire-and-forget: their responses are compared with the
+// primary response and discarded. Only calls IsIdempotent accepts are
+// mirrored, so a shadow never repeats a call's side effects.
+type TrafficShadow struct {
+	// Version matches providers with this exact version.
+	Version string
+
+	// RuntimeID matches a single provider.
+	RuntimeID string
+
+	// Percent of calls (0-100) mirrored.
+	Percent int
+
+	// MaxBodySize is the largest request or response body mirrored and compared.
+	// Calls with larger or streaming request bodies are not mirrored.
+	// Default: 64 KiB
+	MaxBodySize int64
+
+	// Timeout bounds each shadow request.
+	// Default: 10s
+	Timeout time.Duration
+
+	// OnResult is called with the outcome of each shadow request.
+	// Default: log differences from the primary response.
+	OnResult func(ShadowResult)
+
+	// IsIdempotent reports whether a call may be mirrored.
+	// Default: GET requests, as for FailoverConfig
+	IsIdempotent func(serviceType, method string, req *http.Request) bool
+}
+
+// ShadowResult compares a shadow response with the primary response.
+type ShadowResult struct {
+	ServiceType string
+	Method      string
+
+	// PrimaryID and ShadowID are the runtime IDs of the two providers.
+	PrimaryID string
+	ShadowID  string
+
+	PrimaryStatus int
+	ShadowStatus  int
+
+	// BodiesMatch reports whether the response bodies were identical.
+	// Bodies larger than MaxBodySize are compared up to that size.
+	BodiesMatch bool
+
+	// Err is set if the shadow request failed.
+	Err error
+}
+
+// Matches reports whether the shadow response matched the primary response.
+func (r ShadowResult) Matches() bool {
+	return r.Err == nil && r.PrimaryStatus == r.ShadowStatus && r.BodiesMatch
+}
+
+// matchesTarget reports whether a provider matches a version or runtime ID.
+func matchesTarget(p *ServiceProvider, version, runtimeID string) bool {
+	if runtimeID != "" && p.RuntimeID != runtimeID {
+		return false
+	}
+	if version != "" && p.Version != version {
+		return false
+	}
+	return true
+}
+
+// validate checks percentages and targets.
+func (p *TrafficPolicy) validate() error {
+	total := 0
+	for _, split := range p.Splits {
+		if split.Version == "" && split.RuntimeID == "" {
+			return fmt.Errorf("traffic split requires a version or runtime ID")
+		}
+		if split.Percent < 0 || split.Percent > 100 {
+			return fmt.Errorf("traffic split percent must be 0-100, got %d", split.Percent)
+		}
+		total += split.Percent
+	}
+	if total > 100 {
+		return fmt.Errorf("traffic split percentages total %d (max 100)", total)
+	}
+
+	if s := p.Shadow; s != nil {
+		if s.Version == "" && s.RuntimeID == "" {
+			return fmt.Errorf("traffic shadow requires a version or runtime ID")
+		}
+		if s.Percent < 0 || s.Percent > 100 {
+			return fmt.Errorf("traffic shadow percent must be 0-100, got %d", s.Percent)
+		}
+	}
+	return nil
+}
+
+// splitProviders narrows available providers to the group chosen by the
+// policy's weighted split.
+func (p *TrafficPolicy) splitProviders(available []*ServiceProvider) []*ServiceProvider {
+	if len(p.Splits) == 0 {
+		return available
+	}
+
+	roll := rand.Intn(100)
+	chosen := -1 // -1 = providers matching no split
+	for i, split := range p.Splits {
+		if roll < split.Percent {
+			chosen = i
+			break
+		}
+		roll -= split.Percent
+	}
+
+	group := make([]*ServiceProvider, 0, len(available))
+	for _, provider := range available {
+		matched := -1
+		for i, split := range p.Splits {
+			if matchesTarget(provider, split.Version, split.RuntimeID) {
+				matched = i
+				break
+			}
+		}
+		if matched == chosen {
+			group = append(group, provider)
+		}
+	}
+
+	if len(group) == 0 {
+		return available
+	}
+	return group
+}
+
+// SetTrafficPolicy configures traffic splitting and shadowing for a service
+// type in the default namespace (host config). Pass nil to remove the policy.
+func (r *ServiceRegistry) SetTrafficPolicy(serviceType string, policy *TrafficPolicy) error {
+	return r.SetTrafficPolicyInNamespace("", serviceType, policy)
+}
+
+// SetTrafficPolicyInNamespace configures traffic splitting and shadowing for
+// a service type in the given namespace. Pass nil to remove the policy.
+func (r *ServiceRegistry) SetTrafficPolicyInNamespace(namespace, serviceType string, policy *TrafficPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := serviceKey(namespace, serviceType)
+	if policy == nil {
+		delete(r.traffic, key)
+		return nil
+	}
+	if err := policy.validate(); err != nil {
+		return err
+	}
+
+	copied := &TrafficPolicy{Splits: append([]TrafficSplit(nil), policy.Splits...)}
+	if policy.Shadow != nil {
+		shadow := *policy.Shadow
+		if shadow.MaxBodySize <= 0 {
+			shadow.MaxBodySize = 64 * 1024
+		}
+		if shadow.Timeout <= 0 {
+			shadow.Timeout = 10 * time.Second
+		}
+		if shadow.OnResult == nil {
+			shadow.OnResult = logShadowResult
+		}
+		if shadow.IsIdempotent == nil {
+			shadow.IsIdempotent = defaultIsIdempotent
+		}
+		copied.Shadow = &shadow
+	}
+	r.traffic[key] = copied
+	return nil
+}
+
+// TrafficPolicy returns the traffic policy for a service type in the default
+// namespace, or nil. The returned policy must not be modified.
+func (r *ServiceRegistry) TrafficPolicy(serviceType string) *TrafficPolicy {
+	return r.TrafficPolicyInNamespace("", serviceType)
+}
+
+// TrafficPolicyInNamespace returns the traffic policy for a service type in
+// the given namespace, or nil. The returned policy must not be modified.
+func (r *ServiceRegistry) TrafficPolicyInNamespace(namespace, serviceType string) *TrafficPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.traffic[serviceKey(namespace, serviceType)]
+}
+
+// logShadowResult logs shadow responses that differ from the primary.
+func logShadowResult(result ShadowResult) {
+	switch {
+	case result.Err != nil:
+		log.Printf("[ROUTER] Shadow %s → %s %s FAILED: %v",
+			result.PrimaryID, result.ShadowID, result.Method, result.Err)
+	case result.PrimaryStatus != result.ShadowStatus:
+		log.Printf("[ROUTER] Shadow %s %s status differs: primary %s=%d, shadow %s=%d",
+			result.ServiceType, result.Method, result.PrimaryID, result.PrimaryStatus,
+			result.ShadowID, result.ShadowStatus)
+	case !result.BodiesMatch:
+		log.Printf("[ROUTER] Shadow %s %s body differs: primary %s, shadow %s",
+			result.ServiceType, result.Method, result.PrimaryID, result.ShadowID)
+	}
+}
+
+// shadowCall is an in-flight shadow request.
+type shadowCall struct {
+	result  ShadowResult
+	maxBody int64
+	primary chan *captureWriter
+}
+
+// startShadow mirrors the call to a shadow provider if the service type's
+// policy selects one and the call is idempotent. The request body is
+// buffered and restored so the primary call is unaffected. Returns nil if
+// the call is not mirrored.
+func (r *ServiceRouter) startShadow(req *http.Request, namespace string, caller *CallerInfo, primary *ServiceProvider, method string) *shadowCall {
+	policy := r.registry.TrafficPolicyInNamespace(namespace, primary.ServiceType)
+	if policy == nil || policy.Shadow == nil {
+		return nil
+	}
+	shadow := policy.Shadow
+	if !shadow.IsIdempotent(primary.ServiceType, method, req) || rand.Intn(100) >= shadow.Percent {
+		return nil
+	}
+
+	target, err := r.registry.selectProvider(namespace, primary.ServiceType, "", func(p *ServiceProvider) bool {
+		return p.RuntimeID == primary.RuntimeID || !matchesTarget(p, shadow.Version, shadow.RuntimeID)
+	})
+	if err != nil {
+		return nil
+	}
+	baseURL, ok := r.providerBaseURL(target)
+	if !ok {
+		return nil
+	}
+
+	// Only bodies of known, bounded size can be mirrored
+	body, replayable, err := bufferBody(req, shadow.MaxBodySize)
+	if err != nil || !replayable {
+		return nil
+	}
+
+	// Shadow traffic never queues for a busy provider
+	release, err := r.acquireSlot(req.Context(), target.RuntimeID, false)
+	if err != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return nil
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), shadow.Timeout)
+	shadowReq := req.Clone(ctx)
+	targetURL := baseURL + target.EndpointPath + strings.TrimPrefix(method, "/")
+	transport := r.transportFor(target.RuntimeID, req)
+
+	call := &shadowCall{
+		result: ShadowResult{
+			ServiceType: primary.ServiceType,
+			Method:      method,
+			PrimaryID:   primary.RuntimeID,
+			ShadowID:    target.RuntimeID,
+		},
+		maxBody: shadow.MaxBodySize,
+		primary: make(chan *captureWriter, 1),
+	}
+
+	go func() {
+		defer cancel()
+		defer release()
+
+		result := call.result
+		var shadowBody []byte
+		resp, err := r.roundTrip(ctx, transport, shadowReq, caller, target, targetURL, bytes.NewReader(body), int64(len(body)))
+		if err == nil {
+			shadowBody, err = io.ReadAll(io.LimitReader(resp.Body, shadow.MaxBodySize))
+			resp.Body.Close()
+			result.ShadowStatus = resp.StatusCode
+		}
+		result.Err = err
+
+		// Compare with the primary response once it completes
+		select {
+		case capture := <-call.primary:
+			result.PrimaryStatus = capture.status
+			result.BodiesMatch = err == nil && bytes.Equal(capture.body.Bytes(), shadowBody)
+		case <-ctx.Done():
+			if result.Err == nil {
+				result.Err = ctx.Err()
+			}
+		}
+		shadow.OnResult(result)
+	}()
+
+	return call
+}
+
+// finish hands the primary response to the shadow comparison.
+func (c *shadowCall) finish(capture *captureWriter) {
+	c.primary <- capture
+}
+
+// captureWriter records the status and the first bytes of a response while
+// passing it through to the caller.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	limit  int64
+}
+
+func newCaptureWriter(w http.ResponseWriter, limit int64) *captureWriter {
+	return &captureWriter{ResponseWriter: w, status: http.StatusOK, limit: limit}
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	if remaining := c.limit - int64(c.body.Len()); remaining > 0 {
+		if int64(len(p)) > remaining {
+			c.body.Write(p[:remaining])
+		} else {
+			c.body.Write(p)
+		}
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
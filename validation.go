@@ -103,6 +103,15 @@ func ValidateServiceType(serviceType string) error {
 	return nil
 }
 
+// ValidateNamespace validates a registry namespace (tenant) name.
+// Namespaces follow the same rules as service types.
+func ValidateNamespace(namespace string) error {
+	if err := ValidateServiceType(namespace); err != nil {
+		return fmt.Errorf("invalid namespace: %w", err)
+	}
+	return nil
+}
+
 // ValidateSelfID validates a plugin's self-declared ID.
 // Returns an error if:
 // - Empty or too long
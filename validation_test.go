@@ -1,7 +1,6 @@
 package connectplugin
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"testing"
@@ -352,9 +351,8 @@ func TestInputValidation_Integration(t *testing.T) {
 				EndpointPath: tt.endpoint,
 				Metadata:     tt.metadata,
 			})
-			req.Header().Set("X-Plugin-Runtime-ID", "test-plugin")
 
-			_, err := registry.RegisterService(context.Background(), req)
+			_, err := registry.RegisterService(runtimeContext("test-plugin"), req)
 
 			if tt.wantError && err == nil {
 				t.Error("Expected validation error")